@@ -0,0 +1,164 @@
+package apierr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Code classifies an error.
+type Code string
+
+// Supported error codes.
+const (
+	CodeInvalidArgument  Code = "invalid_argument"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeConflict         Code = "conflict"
+	CodeUnauthenticated  Code = "unauthenticated"
+	CodePermissionDenied Code = "permission_denied"
+	CodeUnavailable      Code = "unavailable"
+	CodeDeadlineExceeded Code = "deadline_exceeded"
+	CodeCanceled         Code = "canceled"
+	CodeInternal         Code = "internal"
+)
+
+// StatusClientClosedRequest is the non-standard status of requests canceled by the client.
+const StatusClientClosedRequest = 499
+
+var httpStatuses = map[Code]int{
+	CodeInvalidArgument:  http.StatusBadRequest,
+	CodeNotFound:         http.StatusNotFound,
+	CodeMethodNotAllowed: http.StatusMethodNotAllowed,
+	CodeConflict:         http.StatusConflict,
+	CodeUnauthenticated:  http.StatusUnauthorized,
+	CodePermissionDenied: http.StatusForbidden,
+	CodeUnavailable:      http.StatusServiceUnavailable,
+	CodeDeadlineExceeded: http.StatusGatewayTimeout,
+	CodeCanceled:         StatusClientClosedRequest,
+	CodeInternal:         http.StatusInternalServerError,
+}
+
+// HTTPStatus returns the HTTP status code matching the error code.
+func (c Code) HTTPStatus() int {
+	if status, ok := httpStatuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FromHTTPStatus returns the error code matching a HTTP status code.
+func FromHTTPStatus(status int) Code {
+	for code, codeStatus := range httpStatuses {
+		if codeStatus == status {
+			return code
+		}
+	}
+	return CodeInternal
+}
+
+// Error is a classified error returned by modules.
+type Error struct {
+	Code    Code                   `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithDetail attaches additional information to the error.
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// New returns a new error with the specified code.
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// InvalidArgument returns an error caused by a bad request payload.
+func InvalidArgument(format string, args ...interface{}) *Error {
+	return New(CodeInvalidArgument, format, args...)
+}
+
+// NotFound returns an error caused by a missing module, action or resource.
+func NotFound(format string, args ...interface{}) *Error {
+	return New(CodeNotFound, format, args...)
+}
+
+// MethodNotAllowed returns an error caused by a HTTP method not supported by a route.
+func MethodNotAllowed(format string, args ...interface{}) *Error {
+	return New(CodeMethodNotAllowed, format, args...)
+}
+
+// Conflict returns an error caused by the current state of a resource.
+func Conflict(format string, args ...interface{}) *Error {
+	return New(CodeConflict, format, args...)
+}
+
+// Unavailable returns an error caused by a dependency that cannot be reached.
+func Unavailable(format string, args ...interface{}) *Error {
+	return New(CodeUnavailable, format, args...)
+}
+
+// DeadlineExceeded returns an error caused by an action running past its deadline.
+func DeadlineExceeded(format string, args ...interface{}) *Error {
+	return New(CodeDeadlineExceeded, format, args...)
+}
+
+// Canceled returns an error caused by the caller abandoning the request.
+func Canceled(format string, args ...interface{}) *Error {
+	return New(CodeCanceled, format, args...)
+}
+
+// Internal returns an unexpected error.
+func Internal(format string, args ...interface{}) *Error {
+	return New(CodeInternal, format, args...)
+}
+
+// From converts any error to a classified error.
+// Unclassified errors are considered internal.
+func From(err error) *Error {
+	if apiErr, ok := err.(*Error); ok {
+		return apiErr
+	}
+	return Internal("%s", err.Error())
+}
+
+// Envelope is the JSON body of error responses.
+type Envelope struct {
+	Error *Error `json:"error"`
+}
+
+// Write writes the error to a HTTP response.
+func Write(w http.ResponseWriter, err error) {
+	apiErr := From(err)
+
+	outBytes, _ := json.Marshal(Envelope{Error: apiErr})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Code.HTTPStatus())
+	w.Write(outBytes)
+}
+
+// Parse decodes an error response.
+// Bodies that are not error envelopes are wrapped using the HTTP status code.
+func Parse(status int, body []byte) *Error {
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error != nil && envelope.Error.Code != "" {
+		return envelope.Error
+	}
+
+	message := http.StatusText(status)
+	if len(body) > 0 {
+		message = string(body)
+	}
+
+	return New(FromHTTPStatus(status), "%s", message)
+}
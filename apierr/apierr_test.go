@@ -0,0 +1,110 @@
+package apierr_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dalloriam/orc/apierr"
+)
+
+func TestCode_HTTPStatus(t *testing.T) {
+	type testCase struct {
+		code     apierr.Code
+		expected int
+	}
+
+	cases := []testCase{
+		{apierr.CodeInvalidArgument, http.StatusBadRequest},
+		{apierr.CodeNotFound, http.StatusNotFound},
+		{apierr.CodeConflict, http.StatusConflict},
+		{apierr.CodeUnauthenticated, http.StatusUnauthorized},
+		{apierr.CodePermissionDenied, http.StatusForbidden},
+		{apierr.CodeUnavailable, http.StatusServiceUnavailable},
+		{apierr.CodeDeadlineExceeded, http.StatusGatewayTimeout},
+		{apierr.CodeCanceled, apierr.StatusClientClosedRequest},
+		{apierr.CodeInternal, http.StatusInternalServerError},
+		{apierr.Code("unknown"), http.StatusInternalServerError},
+	}
+
+	for _, tCase := range cases {
+		t.Run(string(tCase.code), func(t *testing.T) {
+			if actual := tCase.code.HTTPStatus(); actual != tCase.expected {
+				t.Errorf("expected status %d, got %d", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFrom(t *testing.T) {
+	notFound := apierr.NotFound("unknown key: %s", "hello")
+	if apierr.From(notFound) != notFound {
+		t.Errorf("expected classified error to be returned as-is")
+	}
+
+	converted := apierr.From(errors.New("something terrible happened"))
+	if converted.Code != apierr.CodeInternal || converted.Message != "something terrible happened" {
+		t.Errorf("expected unclassified error to be internal, got %v", converted)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	apierr.Write(rec, apierr.NotFound("unknown key: %s", "hello").WithDetail("key", "hello"))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("expected JSON content type, got %s", contentType)
+	}
+
+	var envelope apierr.Envelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("invalid JSON envelope: %s", rec.Body.String())
+	}
+
+	if envelope.Error.Code != apierr.CodeNotFound || envelope.Error.Message != "unknown key: hello" {
+		t.Errorf("unexpected envelope: %v", envelope.Error)
+	}
+
+	if envelope.Error.Details["key"] != "hello" {
+		t.Errorf("expected details to be preserved, got %v", envelope.Error.Details)
+	}
+}
+
+func TestParse(t *testing.T) {
+	type testCase struct {
+		name   string
+		status int
+		body   string
+
+		expectedCode    apierr.Code
+		expectedMessage string
+	}
+
+	cases := []testCase{
+		{"envelope", http.StatusConflict, `{"error": {"code": "conflict", "message": "already running"}}`, apierr.CodeConflict, "already running"},
+		{"plain text", http.StatusBadRequest, "bad request", apierr.CodeInvalidArgument, "bad request"},
+		{"empty body", http.StatusServiceUnavailable, "", apierr.CodeUnavailable, "Service Unavailable"},
+		{"legacy error", http.StatusInternalServerError, `{"error": "boom"}`, apierr.CodeInternal, `{"error": "boom"}`},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			apiErr := apierr.Parse(tCase.status, []byte(tCase.body))
+
+			if apiErr.Code != tCase.expectedCode {
+				t.Errorf("expected code %s, got %s", tCase.expectedCode, apiErr.Code)
+			}
+
+			if apiErr.Message != tCase.expectedMessage {
+				t.Errorf("expected message %q, got %q", tCase.expectedMessage, apiErr.Message)
+			}
+		})
+	}
+}
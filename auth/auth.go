@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	bearerPrefix = "Bearer "
+
+	// WildcardScope grants access to every action of every module.
+	WildcardScope = "*"
+)
+
+// Authentication errors.
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid bearer token")
+	ErrForbidden    = errors.New("token is not allowed to perform this action")
+)
+
+// Token represents a static bearer token along with the scopes it grants.
+// Scopes are patterns of the form "module/action" (e.g. "local/notify", "task/*").
+type Token struct {
+	Name   string   `json:"name"`
+	Token  string   `json:"token"`
+	Scopes []string `json:"scopes"`
+}
+
+// Allows returns whether the token grants access to the specified action.
+func (t *Token) Allows(moduleName, actionName string) bool {
+	target := moduleName + "/" + actionName
+
+	for _, scope := range t.Scopes {
+		if scope == WildcardScope {
+			return true
+		}
+
+		if matched, err := path.Match(scope, target); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsModule returns whether the token grants access to at least one action of the module.
+func (t *Token) AllowsModule(moduleName string) bool {
+	for _, scope := range t.Scopes {
+		if scope == WildcardScope {
+			return true
+		}
+
+		scopeModule := scope
+		if i := strings.Index(scope, "/"); i >= 0 {
+			scopeModule = scope[:i]
+		}
+		if matched, err := path.Match(scopeModule, moduleName); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+type tokenFile struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// Authenticator validates bearer tokens against a set of known tokens.
+type Authenticator struct {
+	mu     sync.RWMutex
+	tokens []Token
+}
+
+// NewAuthenticator returns an authenticator accepting the provided tokens.
+func NewAuthenticator(tokens []Token) *Authenticator {
+	return &Authenticator{tokens: tokens}
+}
+
+// SetTokens replaces the tokens accepted by the authenticator.
+func (a *Authenticator) SetTokens(tokens []Token) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokens = tokens
+}
+
+// Enabled returns whether the authenticator has any token configured.
+// Authentication is disabled when no tokens are configured.
+func (a *Authenticator) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.tokens) > 0
+}
+
+// ReadFile reads the token definitions from a JSON file.
+func ReadFile(filePath string) ([]Token, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		logrus.Warnf("token file %s is accessible by other users, consider running 'chmod 600 %s'", filePath, filePath)
+	}
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var file tokenFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	for _, tok := range file.Tokens {
+		if tok.Token == "" {
+			return nil, errors.New("token file contains an empty token")
+		}
+	}
+
+	return file.Tokens, nil
+}
+
+// LoadFile returns an authenticator accepting the tokens defined in a JSON file.
+func LoadFile(filePath string) (*Authenticator, error) {
+	tokens, err := ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAuthenticator(tokens), nil
+}
+
+// Authenticate returns the token matching the value of an Authorization header.
+func (a *Authenticator) Authenticate(header string) (*Token, error) {
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return nil, ErrMissingToken
+	}
+
+	provided := []byte(strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix)))
+	if len(provided) == 0 {
+		return nil, ErrMissingToken
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare(provided, []byte(a.tokens[i].Token)) == 1 {
+			tok := a.tokens[i]
+			return &tok, nil
+		}
+	}
+
+	return nil, ErrInvalidToken
+}
+
+// Authorize authenticates the header and ensures the token grants access to the action.
+func (a *Authenticator) Authorize(header, moduleName, actionName string) (*Token, error) {
+	tok, err := a.Authenticate(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if !tok.Allows(moduleName, actionName) {
+		return tok, ErrForbidden
+	}
+
+	return tok, nil
+}
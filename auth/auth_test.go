@@ -0,0 +1,166 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dalloriam/orc/auth"
+)
+
+func TestToken_Allows(t *testing.T) {
+	type testCase struct {
+		name   string
+		scopes []string
+
+		module string
+		action string
+
+		expected bool
+	}
+
+	cases := []testCase{
+		{"no scopes", nil, "local", "notify", false},
+		{"wildcard", []string{"*"}, "task", "start", true},
+		{"exact match", []string{"local/notify"}, "local", "notify", true},
+		{"exact mismatch", []string{"local/notify"}, "local", "other", false},
+		{"module wildcard", []string{"task/*"}, "task", "stop", true},
+		{"module wildcard mismatch", []string{"task/*"}, "keyval", "get", false},
+		{"action wildcard", []string{"*/list"}, "keyval", "list", true},
+		{"multiple scopes", []string{"local/notify", "keyval/get"}, "keyval", "get", true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			tok := &auth.Token{Scopes: tCase.scopes}
+
+			if actual := tok.Allows(tCase.module, tCase.action); actual != tCase.expected {
+				t.Errorf("expected Allows(%s, %s)=%v, got %v", tCase.module, tCase.action, tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestToken_AllowsModule(t *testing.T) {
+	type testCase struct {
+		name   string
+		scopes []string
+		module string
+
+		expected bool
+	}
+
+	cases := []testCase{
+		{"no scopes", nil, "task", false},
+		{"wildcard", []string{"*"}, "task", true},
+		{"single action", []string{"task/status"}, "task", true},
+		{"module wildcard", []string{"task/*"}, "task", true},
+		{"other module", []string{"task/*"}, "keyval", false},
+		{"action wildcard", []string{"*/list"}, "keyval", true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			tok := &auth.Token{Scopes: tCase.scopes}
+
+			if actual := tok.AllowsModule(tCase.module); actual != tCase.expected {
+				t.Errorf("expected AllowsModule(%s)=%v, got %v", tCase.module, tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_Authorize(t *testing.T) {
+	type testCase struct {
+		name   string
+		header string
+
+		module string
+		action string
+
+		expectedToken string
+		expectedErr   error
+	}
+
+	a := auth.NewAuthenticator([]auth.Token{
+		{Name: "admin", Token: "admin-secret", Scopes: []string{"*"}},
+		{Name: "notifier", Token: "notify-secret", Scopes: []string{"local/notify"}},
+	})
+
+	cases := []testCase{
+		{"missing header", "", "local", "notify", "", auth.ErrMissingToken},
+		{"wrong scheme", "Basic abc", "local", "notify", "", auth.ErrMissingToken},
+		{"empty token", "Bearer ", "local", "notify", "", auth.ErrMissingToken},
+		{"unknown token", "Bearer nope", "local", "notify", "", auth.ErrInvalidToken},
+		{"admin token", "Bearer admin-secret", "task", "start", "admin", nil},
+		{"scoped token allowed", "Bearer notify-secret", "local", "notify", "notifier", nil},
+		{"scoped token forbidden", "Bearer notify-secret", "task", "start", "notifier", auth.ErrForbidden},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			tok, err := a.Authorize(tCase.header, tCase.module, tCase.action)
+
+			if err != tCase.expectedErr {
+				t.Errorf("expected err=%v, got err=%v", tCase.expectedErr, err)
+				return
+			}
+
+			if tCase.expectedToken == "" {
+				if tok != nil {
+					t.Errorf("expected no token, got %s", tok.Name)
+				}
+				return
+			}
+
+			if tok == nil || tok.Name != tCase.expectedToken {
+				t.Errorf("expected token %s, got %v", tCase.expectedToken, tok)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	type testCase struct {
+		name    string
+		content string
+
+		wantErr bool
+	}
+
+	cases := []testCase{
+		{"valid file", `{"tokens": [{"name": "a", "token": "b", "scopes": ["*"]}]}`, false},
+		{"invalid json", `{"tokens": [`, true},
+		{"empty token", `{"tokens": [{"name": "a", "token": "", "scopes": ["*"]}]}`, true},
+	}
+
+	dir, err := ioutil.TempDir("", "orc_auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			filePath := path.Join(dir, "auth.json")
+			if err := ioutil.WriteFile(filePath, []byte(tCase.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := auth.LoadFile(filePath)
+			if (err != nil) != tCase.wantErr {
+				t.Errorf("expected err: %v, got err=%v", tCase.wantErr, err)
+				return
+			}
+
+			if !tCase.wantErr && a == nil {
+				t.Errorf("returned nil authenticator")
+			}
+		})
+	}
+
+	if _, err := auth.LoadFile(path.Join(dir, "doesnt_exist.json")); err == nil {
+		t.Errorf("expected error when loading missing file")
+	}
+}
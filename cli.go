@@ -1,23 +1,122 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net/http"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/client"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/output"
+	"github.com/dalloriam/orc/schema"
 )
 
 const (
 	cliCommandName = "cli"
 	cliCommandHelp = "Interact with the ORC server."
-	cliCommandArgs = "MODULE ACTION [OPTIONS]"
+	cliCommandArgs = "MODULE ACTION [OPTIONS] | events [-t TOPIC]..."
+
+	cliCommandLongHelp = cliCommandHelp + `
+
+Exit codes:
+  0  success
+  1  internal error
+  3  invalid argument
+  4  module, action or resource not found
+  5  conflict
+  6  server or dependency unavailable
+  7  authentication required
+  8  permission denied
+  9  action timed out
+  10 action canceled
+  11 server unreachable
+
+Arguments are passed with -a NAME=VALUE, values being converted to the types
+declared by the action, or with -a NAME:=JSON for JSON literals such as numbers,
+booleans, arrays and objects. Dotted names set nested keys (-a a.b=c), names
+ending with [] append to an array (-a tags[]=a -a tags[]=b), and values starting with @ are read from a file (-a key=@id_rsa.pub, -a cfg:=@cfg.json);
+a leading \@ is a literal @. The whole body can be read as a JSON object from a
+file with -d FILE, or from stdin with -d -, the -a arguments taking precedence.
+
+Results are printed with -o json, pretty, table or yaml. -q prints the values at
+a jq-like path of the result instead, one per line and strings unquoted, e.g.
+-q .value or -q '.tasks[].name'.
+
+Long-running actions can run as background jobs on the server: --async prints
+the job, which can then be tracked with the job module (job status -a id=...),
+while --wait polls the job until it finishes and prints its result, giving up
+after --wait-timeout if set. Jobs are only visible to the token that started them,
+or to tokens granted the "*" scope.
+
+Servers are selected with -context NAME, see "orc context", or -H. The action
+runs against several servers at once with -context repeated, or -all-contexts:
+the results are printed together by context name, failures being reported as
+errors of their context, and the exit code is that of the first failing context.
+
+"orc cli events" tails the events of the server, one JSON object per line.
+Events are filtered with -t, e.g. -t task/* -t keyval/set; a module name
+matches all the events of the module. The stream reconnects when the server
+restarts or the connection drops.`
+
+	eventsCommand = "events"
+
+	defaultCLIConfigSuffix = ".config/dalloriam/orc/cli.json"
+
+	hostEnvVar       = "ORC_HOST"
+	tokenEnvVar      = "ORC_TOKEN"
+	tlsCAEnvVar      = "ORC_TLS_CA"
+	tlsCertEnvVar    = "ORC_TLS_CERT"
+	tlsCertKeyEnvVar = "ORC_TLS_KEY"
 )
 
+// loadCLIConfig reads the client settings of the context from
+// ~/.config/dalloriam/orc/cli.json, see contextCommand.
+func loadCLIConfig(contextName string) (client.Config, error) {
+	filePath, err := cliConfigPath()
+	if err != nil {
+		return client.Config{}, err
+	}
+
+	cfg, err := readCLIConfig(filePath)
+	if err != nil {
+		return client.Config{}, err
+	}
+	return cfg.resolve(contextName)
+}
+
+// exitCodes maps error classes to the exit code of the cli command, for use in scripts.
+var exitCodes = map[apierr.Code]int{
+	apierr.CodeInternal:         1,
+	apierr.CodeInvalidArgument:  3,
+	apierr.CodeNotFound:         4,
+	apierr.CodeConflict:         5,
+	apierr.CodeUnavailable:      6,
+	apierr.CodeUnauthenticated:  7,
+	apierr.CodePermissionDenied: 8,
+	apierr.CodeDeadlineExceeded: 9,
+	apierr.CodeCanceled:         10,
+}
+
+// unreachableExitCode is the exit code of the cli command when the server can't be
+// reached, unlike the unavailable errors returned by the server itself.
+const unreachableExitCode = 11
+
+// jobPollInterval is the interval at which --wait polls the status of the job.
+const jobPollInterval = 500 * time.Millisecond
+
+// eventsRetryInterval is the interval at which the event stream reconnects.
+const eventsRetryInterval = time.Second
+
 type stringSlice []string
 
 func (s *stringSlice) String() string {
@@ -31,67 +130,380 @@ func (s *stringSlice) Set(value string) error {
 
 type cliCommand struct {
 	arguments stringSlice
+	topics    stringSlice
+	contexts  stringSlice
+
+	host string
+	data string
+
+	allContexts bool
+
+	stdinOnce sync.Once
+	stdin     []byte
+	stdinErr  error
+
+	format string
+	query  string
+
+	async       bool
+	wait        bool
+	waitTimeout time.Duration
+}
+
+// argument is an argument of the action, passed as NAME=VALUE or NAME:=JSON.
+type argument struct {
+	path []string
+	raw  string
+
+	isJSON bool
+	value  interface{}
+
+	// appends is set for the NAME[]= arguments, appending their value to an array.
+	appends bool
 }
 
 func (cmd *cliCommand) Name() string      { return cliCommandName }
 func (cmd *cliCommand) Args() string      { return cliCommandArgs }
 func (cmd *cliCommand) ShortHelp() string { return cliCommandHelp }
-func (cmd *cliCommand) LongHelp() string  { return cliCommandHelp }
+func (cmd *cliCommand) LongHelp() string  { return cliCommandLongHelp }
 func (cmd *cliCommand) Hidden() bool      { return false }
 func (cmd *cliCommand) Register(fs *flag.FlagSet) {
+	cmd.registerCallFlags(fs)
+	fs.StringVar(&cmd.host, "H", "", fmt.Sprintf("Server to connect to, e.g. unix:///path/to/orc.sock (overrides $%s)", hostEnvVar))
+	fs.StringVar(&cmd.host, "host", "", fmt.Sprintf("Server to connect to, e.g. unix:///path/to/orc.sock (overrides $%s)", hostEnvVar))
+	fs.Var(&cmd.contexts, "context", "Context of the server to connect to, repeated to run the action against several servers")
+	fs.BoolVar(&cmd.allContexts, "all-contexts", false, "Run the action against the servers of all the contexts")
+	fs.BoolVar(&cmd.async, "async", false, "Run the action as a background job and print the job")
+	fs.BoolVar(&cmd.wait, "wait", false, "Run the action as a background job and wait for its result")
+	fs.DurationVar(&cmd.waitTimeout, "wait-timeout", 0, "Stop waiting for the job after this duration, e.g. 10m (defaults to no timeout)")
+	fs.Var(&cmd.topics, "t", "Only tail the events matching the topic pattern")
+	fs.Var(&cmd.topics, "topic", "Only tail the events matching the topic pattern")
+}
+
+// registerCallFlags registers the flags passing the arguments of the action and
+// printing its result, which "orc run" shares.
+func (cmd *cliCommand) registerCallFlags(fs *flag.FlagSet) {
 	fs.Var(&cmd.arguments, "a", "Pass argument to the action")
 	fs.Var(&cmd.arguments, "argument", "Pass argument to the action")
+	fs.StringVar(&cmd.data, "d", "", "Read the arguments from a JSON file, or from stdin with -")
+	fs.StringVar(&cmd.data, "data", "", "Read the arguments from a JSON file, or from stdin with -")
+	fs.StringVar(&cmd.format, "o", output.FormatPretty, "Output format of the result: json, pretty, table or yaml")
+	fs.StringVar(&cmd.format, "output", output.FormatPretty, "Output format of the result: json, pretty, table or yaml")
+	fs.StringVar(&cmd.query, "q", "", "Print the values at a path of the result, e.g. .tasks[].name")
+	fs.StringVar(&cmd.query, "query", "", "Print the values at a path of the result, e.g. .tasks[].name")
 }
 
-func (cmd *cliCommand) parseArgumentPairs(args []string) (map[string]string, error) {
-	argumentPairs := make(map[string]string)
+// parseArguments parses the -a arguments. Dotted names set nested keys, NAME:=JSON
+// passes JSON literals, NAME[]= appends to an array, and values starting with @ are
+// read from a file.
+func (cmd *cliCommand) parseArguments(args []string) ([]argument, error) {
+	parsed := make([]argument, 0, len(args))
+
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid argument syntax: %s, expected NAME=VALUE or NAME:=JSON", arg)
+		}
+		name, raw := arg[:i], arg[i+1:]
+
+		a := argument{isJSON: strings.HasSuffix(name, ":")}
+		name = strings.TrimSuffix(name, ":")
+		a.appends = strings.HasSuffix(name, "[]")
+		a.path = strings.Split(strings.TrimSuffix(name, "[]"), ".")
+		for _, key := range a.path {
+			if key == "" {
+				return nil, fmt.Errorf("invalid argument name: %s", arg)
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(raw, "@"):
+			data, err := ioutil.ReadFile(raw[1:])
+			if err != nil {
+				return nil, err
+			}
+			raw = string(data)
+		case strings.HasPrefix(raw, `\@`):
+			raw = raw[1:]
+		}
+		a.raw = raw
 
-	for i := 0; i < len(args); i++ {
-		splitted := strings.Split(args[i], "=")
-		if len(splitted) != 2 {
-			return nil, fmt.Errorf("invalid argument syntax: expected 1 '=', got %d", len(splitted)-1)
+		if a.isJSON {
+			if err := json.Unmarshal([]byte(raw), &a.value); err != nil {
+				return nil, apierr.InvalidArgument("invalid JSON value: %s", err.Error()).WithDetail("argument", strings.Join(a.path, "."))
+			}
 		}
-		argumentPairs[splitted[0]] = splitted[1]
+
+		parsed = append(parsed, a)
 	}
 
-	return argumentPairs, nil
+	return parsed, nil
 }
 
-func (cmd *cliCommand) formatURL(module, action string) string {
-	return fmt.Sprintf("http://%s:%d/%s/%s", serverHost, serverPort, module, action)
+// readBody reads the arguments from a JSON object in a file, or in stdin for "-".
+func (cmd *cliCommand) readBody(file string) (map[string]interface{}, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if file == "-" {
+		// Stdin is read once, the action possibly running against several servers.
+		cmd.stdinOnce.Do(func() {
+			cmd.stdin, cmd.stdinErr = ioutil.ReadAll(os.Stdin)
+		})
+		data, err = cmd.stdin, cmd.stdinErr
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, apierr.InvalidArgument("invalid JSON body, expected an object: %s", err.Error())
+	}
+	return body, nil
 }
 
-func (cmd *cliCommand) sendCommand(module, action string, body map[string]string) (map[string]interface{}, error) {
+func (cmd *cliCommand) newClient(contextName string) (*client.Client, error) {
+	cfg, err := loadCLIConfig(contextName)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.host != "" {
+		cfg.Host = cmd.host
+	}
 
-	data, err := json.Marshal(body)
+	return client.New(cfg)
+}
+
+// actionSchema fetches the argument schema of the action from the server.
+// Servers that don't expose schemas, or tokens not allowed to read them, yield an empty schema.
+func (cmd *cliCommand) actionSchema(c *client.Client, module, action string) schema.Schema {
+	out, err := c.Call("manage", "actions_available", map[string]interface{}{"detailed": true})
 	if err != nil {
+		return schema.New()
+	}
+
+	var schemas map[string]map[string]schema.Schema
+	if err := json.Unmarshal(out, &schemas); err != nil {
+		return schema.New()
+	}
+
+	return schemas[module][action]
+}
+
+// typedArguments sets the arguments in the body. String values of top-level arguments
+// are converted to the types declared by the action schema, undeclared arguments are
+// sent as strings.
+func (cmd *cliCommand) typedArguments(argSchema schema.Schema, body map[string]interface{}, args []argument) error {
+	for _, arg := range args {
+		name := strings.Join(arg.path, ".")
+
+		val := arg.value
+		if !arg.isJSON {
+			val = arg.raw
+			if field, ok := argSchema.Field(name); ok && len(arg.path) == 1 && !arg.appends {
+				parsed, err := field.Parse(arg.raw)
+				if err != nil {
+					return apierr.InvalidArgument("%s", err.Error()).WithDetail("argument", name)
+				}
+				val = parsed
+			}
+		}
+
+		obj := body
+		for _, key := range arg.path[:len(arg.path)-1] {
+			child, ok := obj[key]
+			if !ok {
+				child = make(map[string]interface{})
+				obj[key] = child
+			}
+			if obj, ok = child.(map[string]interface{}); !ok {
+				return apierr.InvalidArgument("argument %s is not an object", key).WithDetail("argument", name)
+			}
+		}
+
+		key := arg.path[len(arg.path)-1]
+		if arg.appends {
+			items, ok := obj[key].([]interface{})
+			if _, exists := obj[key]; exists && !ok {
+				return apierr.InvalidArgument("argument %s is not an array", name).WithDetail("argument", name)
+			}
+			val = append(items, val)
+		}
+		obj[key] = val
+	}
+
+	return nil
+}
+
+// body returns the arguments of the action: the body read with -d, if any, along
+// with the -a arguments.
+func (cmd *cliCommand) body(argSchema schema.Schema, args []argument) (map[string]interface{}, error) {
+	body := make(map[string]interface{})
+	if cmd.data != "" {
+		var err error
+		if body, err = cmd.readBody(cmd.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cmd.typedArguments(argSchema, body, args); err != nil {
 		return nil, err
 	}
+	return body, nil
+}
 
-	buf := bytes.NewBuffer(data)
+// targets returns the contexts to run the action against, an empty name standing
+// for the current context.
+func (cmd *cliCommand) targets() ([]string, error) {
+	if !cmd.allContexts {
+		if len(cmd.contexts) == 0 {
+			return []string{""}, nil
+		}
+		if len(cmd.contexts) > 1 && cmd.host != "" {
+			return nil, errors.New("-host targets a single server, it can't be used with several contexts")
+		}
+		return cmd.contexts, nil
+	}
 
-	resp, err := http.Post(cmd.formatURL(module, action), "application/json", buf)
+	if len(cmd.contexts) > 0 || cmd.host != "" {
+		return nil, errors.New("-all-contexts can't be used with -context or -host")
+	}
 
+	filePath, err := cliConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := readCLIConfig(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	respData, err := ioutil.ReadAll(resp.Body)
+	names := cfg.contextNames()
+	if len(names) == 0 {
+		return nil, errors.New(`no contexts are defined, see "orc context"`)
+	}
+	return names, nil
+}
+
+// fanOut runs the action against the servers of the contexts, and prints the
+// results keyed by context name. The failures are printed as errors of their
+// context, and the command exits with the code of the first one.
+func (cmd *cliCommand) fanOut(contexts []string, module, action string, args []argument) error {
+	results, failures := cmd.callContexts(contexts, module, action, args)
+	if err := cmd.printResult(results); err != nil {
+		return err
+	}
+
+	var first *apierr.Error
+	sort.Strings(contexts)
+	for _, name := range contexts {
+		if apiErr, ok := failures[name]; ok {
+			fmt.Fprintf(os.Stderr, "context %s: ", name)
+			printAPIError(os.Stderr, apiErr)
+			if first == nil {
+				first = apiErr
+			}
+		}
+	}
+
+	if first != nil {
+		os.Exit(exitCode(first))
+	}
+	return nil
+}
+
+// callContexts runs the action against the servers of the contexts concurrently.
+// The results of the failed calls are their error, as returned by the server.
+func (cmd *cliCommand) callContexts(contexts []string, module, action string, args []argument) (map[string]interface{}, map[string]*apierr.Error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		results  = make(map[string]interface{}, len(contexts))
+		failures = make(map[string]*apierr.Error)
+	)
+
+	for _, name := range contexts {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			result, err := cmd.sendCommand(name, module, action, args)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failures[name] = apierr.From(err)
+				results[name] = errorResult(failures[name])
+				return
+			}
+			results[name] = result
+		}(name)
+	}
+	wg.Wait()
+
+	return results, failures
+}
+
+// errorResult returns the error as decoded from a response of the server, so that
+// -q reaches its fields as those of the other results.
+func errorResult(apiErr *apierr.Error) interface{} {
+	var result interface{}
+	if data, err := json.Marshal(apierr.Envelope{Error: apiErr}); err == nil {
+		json.Unmarshal(data, &result)
+	}
+	return result
+}
+
+func (cmd *cliCommand) sendCommand(contextName, module, action string, args []argument) (interface{}, error) {
+	c, err := cmd.newClient(contextName)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode == 404 {
-		return nil, fmt.Errorf("unknown module or action: %s/%s", module, action)
+	argSchema := schema.New()
+	if len(args) > 0 {
+		argSchema = cmd.actionSchema(c, module, action)
 	}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf(
-			"unexpected error: %s - [%d]",
-			strings.TrimSpace(string(respData)), resp.StatusCode)
+	body, err := cmd.body(argSchema, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var respData []byte
+	if cmd.async || cmd.wait {
+		j, err := c.Submit(module, action, body)
+		if err != nil {
+			return nil, err
+		}
+
+		if cmd.wait {
+			ctx := context.Background()
+			if cmd.waitTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, cmd.waitTimeout)
+				defer cancel()
+			}
+			respData, err = c.Wait(ctx, j.ID, jobPollInterval)
+		} else {
+			respData, err = json.Marshal(map[string]interface{}{"job": j})
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		respData, err = c.Call(module, action, body)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var structured map[string]interface{}
+	var structured interface{}
 	if err := json.Unmarshal(respData, &structured); err != nil {
 		return nil, err
 	}
@@ -99,31 +511,153 @@ func (cmd *cliCommand) sendCommand(module, action string, body map[string]string
 	return structured, nil
 }
 
-func (cmd *cliCommand) pprintResponse(response map[string]interface{}) error {
-	out, err := json.MarshalIndent(response, "", "\t")
+// printResult prints the result in the output format, or the values at the query path.
+func (cmd *cliCommand) printResult(result interface{}) error {
+	if cmd.query == "" {
+		return output.Write(os.Stdout, cmd.format, result)
+	}
+
+	values, err := output.Query(result, cmd.query)
 	if err != nil {
 		return err
 	}
+	return output.WriteRaw(os.Stdout, values)
+}
 
-	fmt.Println(string(out))
-	return nil
+// printAPIError renders a server error, along with its details.
+func printAPIError(w io.Writer, apiErr *apierr.Error) {
+	fmt.Fprintf(w, "error [%s]: %s\n", apiErr.Code, apiErr.Message)
+
+	keys := make([]string, 0, len(apiErr.Details))
+	for k := range apiErr.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields, ok := apiErr.Details[k].(map[string]interface{})
+		if !ok {
+			fmt.Fprintf(w, "  %s: %v\n", k, apiErr.Details[k])
+			continue
+		}
+
+		// Validation errors detail the problem of every invalid argument.
+		fmt.Fprintf(w, "  %s:\n", k)
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "    %s: %v\n", name, fields[name])
+		}
+	}
+
+	if apiErr.Code == apierr.CodeUnauthenticated {
+		fmt.Fprintf(w, "hint: set $%s or the token of the context in ~/%s\n", tokenEnvVar, defaultCLIConfigSuffix)
+	}
+}
+
+// exitWithError renders a server error and exits with the code matching its class.
+func (cmd *cliCommand) exitWithError(apiErr *apierr.Error) {
+	printAPIError(os.Stderr, apiErr)
+	os.Exit(exitCode(apiErr))
+}
+
+// exitCode returns the exit code matching the class of the error.
+func exitCode(apiErr *apierr.Error) int {
+	if client.Unreachable(apiErr) {
+		return unreachableExitCode
+	}
+	if code, ok := exitCodes[apiErr.Code]; ok {
+		return code
+	}
+	return 1
+}
+
+// tailEvents prints the events of the server until the context is canceled,
+// reconnecting and resuming after the last received event when the stream drops.
+func (cmd *cliCommand) tailEvents(ctx context.Context) error {
+	if cmd.allContexts || len(cmd.contexts) > 1 {
+		return errors.New("events are tailed from a single context")
+	}
+
+	contextName := ""
+	if len(cmd.contexts) == 1 {
+		contextName = cmd.contexts[0]
+	}
+
+	c, err := cmd.newClient(contextName)
+	if err != nil {
+		return err
+	}
+
+	var lastID uint64
+	for {
+		err := c.Events(ctx, cmd.topics, lastID, func(evt events.Event) error {
+			lastID = evt.ID
+
+			out, err := json.Marshal(evt)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		})
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Only unreachable servers are retried, other errors are final.
+		if apiErr, ok := err.(*apierr.Error); ok && apiErr.Code != apierr.CodeUnavailable {
+			cmd.exitWithError(apiErr)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "event stream interrupted: %s\n", err.Error())
+		}
+
+		select {
+		case <-time.After(eventsRetryInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
 }
 
 func (cmd *cliCommand) Run(ctx context.Context, args []string) error {
+	if len(args) == 1 && args[0] == eventsCommand {
+		return cmd.tailEvents(ctx)
+	}
+
 	if len(args) != 2 {
 		return errors.New("Invalid syntax")
 	}
 
-	argPairs, err := cmd.parseArgumentPairs(cmd.arguments)
+	if !output.Valid(cmd.format) {
+		return fmt.Errorf("unknown output format: %s", cmd.format)
+	}
+
+	contexts, err := cmd.targets()
 	if err != nil {
 		return err
 	}
 
-	output, err := cmd.sendCommand(args[0], args[1], argPairs)
+	arguments, err := cmd.parseArguments(cmd.arguments)
+	if err == nil && len(contexts) > 1 {
+		return cmd.fanOut(contexts, args[0], args[1], arguments)
+	}
 
+	var result interface{}
+	if err == nil {
+		result, err = cmd.sendCommand(contexts[0], args[0], args[1], arguments)
+	}
+
+	if apiErr, ok := err.(*apierr.Error); ok {
+		cmd.exitWithError(apiErr)
+	}
 	if err != nil {
 		return err
 	}
 
-	return cmd.pprintResponse(output)
+	return cmd.printResult(result)
 }
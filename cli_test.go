@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/dalloriam/orc/schema"
+)
+
+func TestCLICommand_Arguments(t *testing.T) {
+	type testCase struct {
+		name      string
+		arguments []string
+
+		expected map[string]interface{}
+		wantErr  bool
+	}
+
+	dir, err := ioutil.TempDir("", "orc-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := path.Join(dir, "key.pub")
+	jsonFile := path.Join(dir, "cfg.json")
+	if err := ioutil.WriteFile(keyFile, []byte("ssh-rsa AAAA=="), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(jsonFile, []byte(`{"debug": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	argSchema := schema.New(
+		schema.Field{Name: "replicas", Type: schema.TypeInteger},
+		schema.Field{Name: "name", Type: schema.TypeString},
+	)
+
+	cases := []testCase{
+		{"value with =", []string{"url=http://x/?a=b"}, map[string]interface{}{"url": "http://x/?a=b"}, false},
+		{"schema typed", []string{"replicas=3", "name=5"}, map[string]interface{}{"replicas": int64(3), "name": "5"}, false},
+		{"undeclared string", []string{"count=3"}, map[string]interface{}{"count": "3"}, false},
+		{"json literals", []string{"n:=5", "ok:=true", "tags:=[\"a\"]", "obj:={\"a\": null}"}, map[string]interface{}{
+			"n": float64(5), "ok": true, "tags": []interface{}{"a"}, "obj": map[string]interface{}{"a": nil},
+		}, false},
+		{"nested keys", []string{"a.b=c", "a.d:=1", "a.e.f=g"}, map[string]interface{}{
+			"a": map[string]interface{}{"b": "c", "d": float64(1), "e": map[string]interface{}{"f": "g"}},
+		}, false},
+		{"array items", []string{"args[]=-v", "args[]=a b", "args[]:=1", "a.tags[]=x"}, map[string]interface{}{
+			"args": []interface{}{"-v", "a b", float64(1)}, "a": map[string]interface{}{"tags": []interface{}{"x"}},
+		}, false},
+		{"nested key on schema name", []string{"name.first=x"}, map[string]interface{}{"name": map[string]interface{}{"first": "x"}}, false},
+		{"file value", []string{"key=@" + keyFile}, map[string]interface{}{"key": "ssh-rsa AAAA=="}, false},
+		{"json file value", []string{"cfg:=@" + jsonFile}, map[string]interface{}{"cfg": map[string]interface{}{"debug": true}}, false},
+		{"escaped @", []string{`handle=\@orc`}, map[string]interface{}{"handle": "@orc"}, false},
+		{"missing =", []string{"name"}, nil, true},
+		{"empty name", []string{"=x"}, nil, true},
+		{"empty nested key", []string{"a..b=x"}, nil, true},
+		{"invalid json", []string{"n:=five"}, nil, true},
+		{"missing file", []string{"key=@" + path.Join(dir, "missing")}, nil, true},
+		{"invalid typed value", []string{"replicas=many"}, nil, true},
+		{"conflicting keys", []string{"a=b", "a.c=d"}, nil, true},
+		{"item of non-array", []string{"a=b", "a[]=c"}, nil, true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			cmd := &cliCommand{}
+
+			body := make(map[string]interface{})
+			args, err := cmd.parseArguments(tCase.arguments)
+			if err == nil {
+				err = cmd.typedArguments(argSchema, body, args)
+			}
+
+			if (err != nil) != tCase.wantErr {
+				t.Fatalf("expected error=%v, got %v", tCase.wantErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(body, tCase.expected) {
+				t.Errorf("expected %v, got %v", tCase.expected, body)
+			}
+		})
+	}
+}
+
+func TestCLICommand_ReadBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orc-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bodyFile := path.Join(dir, "body.json")
+	if err := ioutil.WriteFile(bodyFile, []byte(`{"key": "a", "value": {"n": 1}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cliCommand{}
+	body, err := cmd.readBody(bodyFile)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	// Arguments take precedence over the body.
+	args, err := cmd.parseArguments([]string{"key=b", "value.m:=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.typedArguments(schema.New(), body, args); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"key": "b", "value": map[string]interface{}{"n": float64(1), "m": float64(2)}}
+	if !reflect.DeepEqual(body, expected) {
+		t.Errorf("expected %v, got %v", expected, body)
+	}
+
+	if err := ioutil.WriteFile(bodyFile, []byte(`[1, 2]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmd.readBody(bodyFile); err == nil {
+		t.Errorf("expected non-object body to be rejected")
+	}
+}
@@ -0,0 +1,292 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/job"
+)
+
+// maxEventSize bounds the size of the lines of the event stream, which carry whole
+// events such as the results of jobs.
+const maxEventSize = 16 << 20
+
+// Config holds the settings used to reach an ORC server.
+type Config struct {
+	// Host is the address of the server, e.g. http://localhost:33000,
+	// https://orc.example.com:33443, https://example.com/orc behind a reverse proxy
+	// or unix:///run/user/1000/orc.sock
+	Host  string `json:"host,omitempty"`
+	Token string `json:"token,omitempty"`
+
+	CACertFile     string `json:"ca_cert_file,omitempty"`
+	ClientCertFile string `json:"client_cert_file,omitempty"`
+	ClientKeyFile  string `json:"client_key_file,omitempty"`
+}
+
+// Client performs calls against an ORC server.
+type Client struct {
+	baseURL string
+	token   string
+
+	http *http.Client
+}
+
+// New initializes a client from the configuration.
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	c := &Client{token: cfg.Token, http: &http.Client{Transport: transport}}
+
+	// Servers behind a reverse proxy are reached under a path prefix.
+	prefix := strings.TrimRight(u.Path, "/")
+
+	switch u.Scheme {
+	case "http":
+		c.baseURL = fmt.Sprintf("http://%s%s", u.Host, prefix)
+	case "https":
+		tlsCfg, err := loadTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsCfg
+		c.baseURL = fmt.Sprintf("https://%s%s", u.Host, prefix)
+	case "unix":
+		socketPath := u.Path
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		}
+		// The host is ignored when dialing the socket.
+		c.baseURL = "http://orc"
+	default:
+		return nil, fmt.Errorf("unsupported host scheme: %s", cfg.Host)
+	}
+
+	return c, nil
+}
+
+func loadTLSConfig(cfg Config) (*tls.Config, error) {
+	tlsCfg := &tls.Config{}
+
+	if cfg.CACertFile != "" {
+		caData, err := ioutil.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no valid certificate found in %s", cfg.CACertFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// Call executes a module action on the server and returns the raw response.
+// Errors reported by the server are returned as *apierr.Error.
+func (c *Client) Call(module, action string, body interface{}) ([]byte, error) {
+	return c.do(context.Background(), c.actionURL(module, action), body)
+}
+
+// actionURL returns the URL of a module action.
+func (c *Client) actionURL(module, action string) string {
+	return fmt.Sprintf("%s/%s/%s", c.baseURL, url.PathEscape(module), url.PathEscape(action))
+}
+
+// Submit starts a module action as a background job on the server.
+func (c *Client) Submit(module, action string, body interface{}) (*job.Job, error) {
+	out, err := c.do(context.Background(), c.actionURL(module, action)+"?async=1", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Job *job.Job `json:"job"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil || resp.Job == nil {
+		return nil, fmt.Errorf("unexpected job response: %s", out)
+	}
+
+	return resp.Job, nil
+}
+
+// Wait polls the status of a job until it finishes, then returns its result.
+// The errors of failed jobs are returned as *apierr.Error, as is the end of ctx,
+// which stops the wait but not the job.
+func (c *Client) Wait(ctx context.Context, jobID string, pollInterval time.Duration) ([]byte, error) {
+	args := map[string]interface{}{"id": jobID}
+	statusURL := c.actionURL(job.ModuleName, "status")
+
+	for {
+		out, err := c.do(ctx, statusURL, args)
+		if err != nil {
+			return nil, waitError(ctx, jobID, err)
+		}
+
+		var j job.Job
+		if err := json.Unmarshal(out, &j); err != nil {
+			return nil, err
+		}
+
+		if j.Status.Finished() {
+			out, err := c.do(ctx, c.actionURL(job.ModuleName, "result"), args)
+			return out, waitError(ctx, jobID, err)
+		}
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, waitError(ctx, jobID, ctx.Err())
+		}
+	}
+}
+
+// waitError classifies the errors caused by the end of the wait.
+func waitError(ctx context.Context, jobID string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return apierr.DeadlineExceeded("timed out waiting for job %s, which is still running", jobID).WithDetail("id", jobID)
+	case context.Canceled:
+		return apierr.Canceled("stopped waiting for job %s, which is still running", jobID).WithDetail("id", jobID)
+	}
+	return err
+}
+
+// unreachable returns the error of a request that didn't reach the server.
+func unreachable(err error) *apierr.Error {
+	return apierr.Unavailable("cannot reach ORC server: %s", err.Error()).WithDetail("reachable", false)
+}
+
+// Unreachable returns whether the error is caused by the server being unreachable,
+// as opposed to the unavailable errors returned by the server, e.g. when a module
+// is disabled.
+func Unreachable(err error) bool {
+	apiErr, ok := err.(*apierr.Error)
+	return ok && apiErr.Code == apierr.CodeUnavailable && apiErr.Details["reachable"] == false
+}
+
+func (c *Client) do(ctx context.Context, url string, body interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, unreachable(err)
+	}
+	defer resp.Body.Close()
+
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apierr.Parse(resp.StatusCode, bytes.TrimSpace(respData))
+	}
+
+	return respData, nil
+}
+
+// Events streams the server events matching the topic patterns, e.g. "task/*",
+// calling fn for each of them until the context is canceled, the stream ends or
+// fn fails. Events published after lastID are replayed first, so that callers can
+// resume a stream; a zero lastID only streams new events.
+func (c *Client) Events(ctx context.Context, topics []string, lastID uint64, fn func(events.Event) error) error {
+	query := url.Values{"topic": topics}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s?%s", c.baseURL, events.Path, query.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if lastID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(lastID, 10))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return unreachable(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respData, _ := ioutil.ReadAll(resp.Body)
+		return apierr.Parse(resp.StatusCode, bytes.TrimSpace(respData))
+	}
+
+	// Only the data lines are needed, the event ID and topic are part of the event.
+	var data bytes.Buffer
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0 && data.Len() > 0:
+			var evt events.Event
+			if err := json.Unmarshal(data.Bytes(), &evt); err != nil {
+				return fmt.Errorf("invalid event: %s", data.String())
+			}
+			data.Reset()
+
+			if err := fn(evt); err != nil {
+				return err
+			}
+		case bytes.HasPrefix(line, []byte("data:")):
+			data.Write(bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:"))))
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
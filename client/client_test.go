@@ -0,0 +1,277 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/client"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/job"
+)
+
+func TestNew(t *testing.T) {
+	type testCase struct {
+		name string
+		host string
+
+		wantErr bool
+	}
+
+	cases := []testCase{
+		{"http host", "http://localhost:33000", false},
+		{"https host", "https://localhost:33443", false},
+		{"unix socket", "unix:///tmp/orc.sock", false},
+		{"unknown scheme", "ftp://localhost", true},
+		{"no scheme", "localhost:33000", true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			c, err := client.New(client.Config{Host: tCase.host})
+
+			if (err != nil) != tCase.wantErr {
+				t.Errorf("expected err: %v, got err=%v", tCase.wantErr, err)
+				return
+			}
+
+			if !tCase.wantErr && c == nil {
+				t.Errorf("returned nil client")
+			}
+		})
+	}
+}
+
+func TestClient_Call(t *testing.T) {
+	type testCase struct {
+		name   string
+		status int
+		token  string
+		body   string
+
+		wantErr apierr.Code
+	}
+
+	cases := []testCase{
+		{"success", http.StatusOK, "secret", `{"message": "OK"}`, ""},
+		{"error envelope", http.StatusNotFound, "secret", `{"error": {"code": "not_found", "message": "unknown key: a"}}`, apierr.CodeNotFound},
+		{"unauthorized", http.StatusUnauthorized, "", `{"error": {"code": "unauthenticated", "message": "missing bearer token"}}`, apierr.CodeUnauthenticated},
+		{"plain text error", http.StatusServiceUnavailable, "secret", "down for maintenance", apierr.CodeUnavailable},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			var receivedAuth, receivedPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedAuth = r.Header.Get("Authorization")
+				receivedPath = r.URL.Path
+				w.WriteHeader(tCase.status)
+				w.Write([]byte(tCase.body))
+			}))
+			defer srv.Close()
+
+			c, err := client.New(client.Config{Host: srv.URL, Token: tCase.token})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := c.Call("keyval", "list", nil)
+			if tCase.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %s", err.Error())
+					return
+				}
+			} else {
+				apiErr, ok := err.(*apierr.Error)
+				if !ok || apiErr.Code != tCase.wantErr {
+					t.Errorf("expected error code %s, got err=%v", tCase.wantErr, err)
+					return
+				}
+				if client.Unreachable(err) {
+					t.Errorf("expected the errors of the server not to be unreachable, got err=%v", err)
+				}
+			}
+
+			if receivedPath != "/keyval/list" {
+				t.Errorf("expected path /keyval/list, got %s", receivedPath)
+			}
+
+			if tCase.token != "" && receivedAuth != "Bearer "+tCase.token {
+				t.Errorf("expected bearer token to be sent, got %q", receivedAuth)
+			}
+
+			if tCase.wantErr == "" && string(out) != tCase.body {
+				t.Errorf("unexpected response: %s", out)
+			}
+		})
+	}
+}
+
+func TestClient_CallPrefix(t *testing.T) {
+	var receivedPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedPath = r.URL.EscapedPath()
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	// The servers behind a reverse proxy are reached under the path of the host.
+	c, err := client.New(client.Config{Host: srv.URL + "/orc/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Call("my plugin", "a/b", nil); err != nil {
+		t.Fatal(err)
+	}
+	if receivedPath != "/orc/my%20plugin/a%2Fb" {
+		t.Errorf("expected path /orc/my%%20plugin/a%%2Fb, got %s", receivedPath)
+	}
+}
+
+func TestClient_CallUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orc_client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := path.Join(dir, "orc.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &httptest.Server{
+		Listener: ln,
+		Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"message": "OK"}`))
+		})},
+	}
+	srv.Start()
+	defer srv.Close()
+
+	c, err := client.New(client.Config{Host: "unix://" + socketPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Call("keyval", "list", nil); err != nil {
+		t.Errorf("expected no error, got %s", err.Error())
+	}
+}
+
+func TestClient_CallUnreachable(t *testing.T) {
+	c, err := client.New(client.Config{Host: "unix:///doesnt/exist.sock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Call("keyval", "list", nil)
+	if apiErr, ok := err.(*apierr.Error); !ok || apiErr.Code != apierr.CodeUnavailable || !client.Unreachable(err) {
+		t.Errorf("expected unreachable error, got err=%v", err)
+	}
+}
+
+func TestClient_SubmitAndWait(t *testing.T) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow/nap":
+			if r.URL.Query().Get("async") != "1" {
+				t.Errorf("expected async query parameter, got %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"job": {"id": "abcd", "module": "slow", "action": "nap", "status": "running"}}`))
+		case "/job/status":
+			polls++
+			status := "running"
+			if polls > 1 {
+				status = "succeeded"
+			}
+			w.Write([]byte(`{"id": "abcd", "status": "` + status + `"}`))
+		case "/job/result":
+			w.Write([]byte(`{"message": "OK"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := client.New(client.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := c.Submit("slow", "nap", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if j.ID != "abcd" || j.Status != job.StatusRunning {
+		t.Errorf("unexpected job: %v", j)
+	}
+
+	out, err := c.Wait(context.Background(), j.ID, time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if string(out) != `{"message": "OK"}` || polls != 2 {
+		t.Errorf("unexpected result after %d polls: %s", polls, out)
+	}
+
+	// The job keeps running past the deadline of the wait.
+	polls = -1000
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Wait(ctx, j.ID, time.Millisecond)
+	if apiErr, ok := err.(*apierr.Error); !ok || apiErr.Code != apierr.CodeDeadlineExceeded {
+		t.Errorf("expected the wait to time out, got err=%v", err)
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	var receivedQuery, receivedLastID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedQuery = r.URL.RawQuery
+		receivedLastID = r.Header.Get("Last-Event-ID")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": heartbeat\n\n"))
+		w.Write([]byte("id: 8\nevent: task/started\ndata: {\"id\": 8, \"topic\": \"task/started\", \"data\": {\"task\": \"web\"}}\n\n"))
+		w.Write([]byte("id: 9\nevent: task/exited\ndata: {\"id\": 9, \"topic\": \"task/exited\"}\n\n"))
+
+		// Events larger than the default buffer of the scanner, e.g. job results.
+		w.Write([]byte("id: 10\nevent: job/finished\ndata: {\"id\": 10, \"topic\": \"job/finished\", \"data\": {\"result\": \"" + strings.Repeat("a", 1<<20) + "\"}}\n\n"))
+	}))
+	defer srv.Close()
+
+	c, err := client.New(client.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []events.Event
+	err = c.Events(context.Background(), []string{"task/*"}, 7, func(evt events.Event) error {
+		received = append(received, evt)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected the stream to end without error, got %s", err.Error())
+	}
+
+	if receivedQuery != "topic=task%2F%2A" || receivedLastID != "7" {
+		t.Errorf("unexpected subscription: %s, last ID %s", receivedQuery, receivedLastID)
+	}
+
+	if len(received) != 3 || received[0].Data["task"] != "web" || received[1].ID != 9 || received[2].Data["result"] != strings.Repeat("a", 1<<20) {
+		t.Errorf("unexpected events: %+v", received)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+)
+
+const (
+	configCommandName = "config"
+	configCommandHelp = "Inspect the ORC configuration."
+	configCommandArgs = "show [OPTIONS]"
+
+	configActionShow = "show"
+)
+
+type configCommand struct {
+	settings *settings
+}
+
+func (cmd *configCommand) Name() string      { return configCommandName }
+func (cmd *configCommand) Args() string      { return configCommandArgs }
+func (cmd *configCommand) ShortHelp() string { return configCommandHelp }
+func (cmd *configCommand) LongHelp() string {
+	return configCommandHelp + " 'show' prints the effective configuration, after environment & flag overrides."
+}
+func (cmd *configCommand) Hidden() bool { return false }
+
+func (cmd *configCommand) Register(fs *flag.FlagSet) {
+	cmd.settings.Register(fs)
+}
+
+func (cmd *configCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 || args[0] != configActionShow {
+		return errors.New("Invalid syntax")
+	}
+
+	cfg, err := cmd.settings.Load()
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(cfg.Redacted(), "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
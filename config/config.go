@@ -0,0 +1,536 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/hook"
+	"github.com/dalloriam/orc/interfaces"
+	"github.com/dalloriam/orc/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+// Default server settings.
+const (
+	DefaultHost     = "127.0.0.1"
+	DefaultPort     = 33000
+	DefaultLogLevel = "info"
+
+	// DefaultShutdownTimeout is the time given to in-flight requests to complete on shutdown.
+	DefaultShutdownTimeout = "30s"
+
+	// DefaultActionTimeout is the time given to actions that don't define their own timeout.
+	DefaultActionTimeout = "60s"
+
+	// DefaultJobTimeout is the time given to background jobs that don't define their
+	// own timeout, "0" lets them run until they finish or are canceled.
+	DefaultJobTimeout = "0"
+
+	// DefaultJobRetention is the time during which the results of finished jobs are kept.
+	DefaultJobRetention = "1h"
+
+	// DefaultMaxPanics is the number of panics within the panic window after which a module is disabled.
+	DefaultMaxPanics = 5
+
+	// DefaultPanicWindow is the period over which the panics of a module are counted.
+	DefaultPanicWindow = "10m"
+
+	// DefaultServiceName is the service name under which spans are exported.
+	DefaultServiceName = "orc"
+
+	defaultConfigSuffix    = ".config/dalloriam/orc/config.json"
+	defaultTaskDirSuffix   = ".config/dalloriam/orc/docker"
+	defaultPluginDirSuffix = ".config/dalloriam/orc/plugins"
+	defaultStateDirSuffix  = ".config/dalloriam/orc/state"
+	defaultTokenFileSuffix = ".config/dalloriam/orc/auth.json"
+
+	redactedValue = "REDACTED"
+)
+
+// Environment variables overriding the configuration file.
+const (
+	EnvConfigFile = "ORC_CONFIG"
+	EnvListen     = "ORC_LISTEN"
+	EnvTaskDir    = "ORC_TASK_DIR"
+	EnvPluginDir  = "ORC_PLUGIN_DIR"
+	EnvStateDir   = "ORC_STATE_DIR"
+	EnvLogLevel   = "ORC_LOG_LEVEL"
+	EnvTokenFile  = "ORC_AUTH_FILE"
+
+	EnvOTLPEndpoint = "ORC_OTLP_ENDPOINT"
+)
+
+// Listener holds the configuration of a server listener.
+type Listener struct {
+	// Listen is the listener specification, e.g. tcp://127.0.0.1:33000 or unix:///run/orc.sock
+	Listen     string                `json:"listen"`
+	SocketMode string                `json:"socket_mode,omitempty"`
+	TLS        *interfaces.TLSConfig `json:"tls,omitempty"`
+}
+
+// Auth holds the API authentication settings.
+// Tokens can be declared inline, in a separate token file, or both.
+type Auth struct {
+	TokenFile string       `json:"token_file,omitempty"`
+	Tokens    []auth.Token `json:"tokens,omitempty"`
+
+	// AllowUnauthenticated serves the API without tokens on listeners reachable
+	// from the network, which is otherwise refused.
+	AllowUnauthenticated bool `json:"allow_unauthenticated,omitempty"`
+}
+
+// Tracing holds the OpenTelemetry settings. Spans are only exported when an endpoint is set.
+type Tracing struct {
+	// OTLPEndpoint is the base URL of the OTLP/HTTP collector, e.g. http://localhost:4318
+	OTLPEndpoint string `json:"otlp_endpoint,omitempty"`
+	ServiceName  string `json:"service_name,omitempty"`
+}
+
+// Supervision holds the settings of the panic supervision of the modules.
+// Modules panicking MaxPanics times within the window are disabled until the next reload.
+type Supervision struct {
+	// MaxPanics is the number of panics disabling a module, 0 never disables modules.
+	MaxPanics   int    `json:"max_panics"`
+	PanicWindow string `json:"panic_window"`
+}
+
+// Config is the server configuration.
+type Config struct {
+	Listeners       []Listener `json:"listeners"`
+	TaskDirectory   string     `json:"task_directory"`
+	PluginDirectory string     `json:"plugin_directory"`
+	StateDirectory  string     `json:"state_directory"`
+	LogLevel        string     `json:"log_level"`
+	ShutdownTimeout string     `json:"shutdown_timeout"`
+
+	// ActionTimeout is the default action timeout, "0" disables it.
+	ActionTimeout string `json:"action_timeout"`
+
+	// ActionTimeouts overrides the timeout of actions matching a "module/action" pattern.
+	ActionTimeouts map[string]string `json:"action_timeouts,omitempty"`
+
+	// JobTimeout is the default timeout of the actions running as background jobs,
+	// in place of ActionTimeout. "0" disables it.
+	JobTimeout string `json:"job_timeout"`
+
+	// JobRetention is the time during which the results of finished jobs are kept.
+	JobRetention string `json:"job_retention"`
+
+	Auth Auth `json:"auth"`
+
+	Tracing Tracing `json:"tracing"`
+
+	Supervision Supervision `json:"supervision"`
+
+	// GRPC serves the gRPC service on the listeners, see interfaces/orcpb/orc.proto.
+	// The tcp and unix listeners then accept HTTP/2 without TLS.
+	GRPC bool `json:"grpc,omitempty"`
+
+	// CORSOrigins are the origins allowed to call the API from a browser, "*" allows any origin.
+	CORSOrigins []string `json:"cors_origins,omitempty"`
+
+	// Webhooks post the events matching their rules to external URLs.
+	Webhooks []webhook.Rule `json:"webhooks,omitempty"`
+
+	// Hooks invoke actions when their URL receives a signed payload, e.g. from a git server.
+	Hooks []hook.Hook `json:"hooks,omitempty"`
+
+	// Modules holds module-specific settings, indexed by module name.
+	Modules map[string]map[string]interface{} `json:"modules,omitempty"`
+
+	homeDir string
+}
+
+// HomeDir returns the home directory of the current user.
+func HomeDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return usr.HomeDir, nil
+}
+
+// DefaultPath returns the path of the configuration file, honoring $ORC_CONFIG.
+func DefaultPath() (string, error) {
+	if cfgPath := os.Getenv(EnvConfigFile); cfgPath != "" {
+		return cfgPath, nil
+	}
+
+	homeDir, err := HomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(homeDir, defaultConfigSuffix), nil
+}
+
+// Default returns the configuration used when no configuration file is present.
+func Default(homeDir string) *Config {
+	return &Config{
+		Listeners: []Listener{
+			{Listen: fmt.Sprintf("%s://%s:%d", interfaces.SchemeTCP, DefaultHost, DefaultPort)},
+		},
+		TaskDirectory:   path.Join(homeDir, defaultTaskDirSuffix),
+		PluginDirectory: path.Join(homeDir, defaultPluginDirSuffix),
+		StateDirectory:  path.Join(homeDir, defaultStateDirSuffix),
+		LogLevel:        DefaultLogLevel,
+		ShutdownTimeout: DefaultShutdownTimeout,
+		ActionTimeout:   DefaultActionTimeout,
+		JobTimeout:      DefaultJobTimeout,
+		JobRetention:    DefaultJobRetention,
+		Auth: Auth{
+			TokenFile: path.Join(homeDir, defaultTokenFileSuffix),
+		},
+		Tracing: Tracing{
+			ServiceName: DefaultServiceName,
+		},
+		Supervision: Supervision{
+			MaxPanics:   DefaultMaxPanics,
+			PanicWindow: DefaultPanicWindow,
+		},
+		Modules: make(map[string]map[string]interface{}),
+
+		homeDir: homeDir,
+	}
+}
+
+// Load reads the configuration file on top of the default configuration.
+// A missing file is only an error when mustExist is set.
+func Load(filePath string, mustExist bool) (*Config, error) {
+	homeDir, err := HomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default(homeDir)
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) && !mustExist {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %s", filePath, err.Error())
+	}
+
+	return cfg, nil
+}
+
+// ApplyEnv overrides the configuration with the values of the ORC_* environment variables.
+func (c *Config) ApplyEnv() {
+	if listen := os.Getenv(EnvListen); listen != "" {
+		c.SetListeners(strings.Split(listen, ","))
+	}
+
+	overrides := map[string]*string{
+		EnvTaskDir:   &c.TaskDirectory,
+		EnvPluginDir: &c.PluginDirectory,
+		EnvStateDir:  &c.StateDirectory,
+		EnvLogLevel:  &c.LogLevel,
+		EnvTokenFile: &c.Auth.TokenFile,
+
+		EnvOTLPEndpoint: &c.Tracing.OTLPEndpoint,
+	}
+	for envVar, field := range overrides {
+		if val := os.Getenv(envVar); val != "" {
+			*field = val
+		}
+	}
+}
+
+// SetListeners replaces the configured listeners.
+func (c *Config) SetListeners(specs []string) {
+	c.Listeners = nil
+	for _, spec := range specs {
+		c.Listeners = append(c.Listeners, Listener{Listen: strings.TrimSpace(spec)})
+	}
+}
+
+// ServerListeners parses the configured listeners.
+func (c *Config) ServerListeners() ([]interfaces.Listener, error) {
+	var listeners []interfaces.Listener
+
+	for _, lCfg := range c.Listeners {
+		l, err := interfaces.ParseListener(lCfg.Listen)
+		if err != nil {
+			return nil, err
+		}
+
+		switch l.Scheme {
+		case interfaces.SchemeTLS:
+			if lCfg.TLS == nil {
+				return nil, fmt.Errorf("missing tls configuration for listener: %s", lCfg.Listen)
+			}
+			l.TLS = lCfg.TLS
+		case interfaces.SchemeUnix:
+			if lCfg.SocketMode != "" {
+				mode, err := interfaces.ParseSocketMode(lCfg.SocketMode)
+				if err != nil {
+					return nil, err
+				}
+				l.SocketMode = mode
+			}
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// Level parses the configured log level.
+func (c *Config) Level() (logrus.Level, error) {
+	return logrus.ParseLevel(c.LogLevel)
+}
+
+// ShutdownGracePeriod parses the configured shutdown timeout.
+func (c *Config) ShutdownGracePeriod() (time.Duration, error) {
+	return time.ParseDuration(c.ShutdownTimeout)
+}
+
+// JobRetentionPeriod parses the job retention period.
+func (c *Config) JobRetentionPeriod() (time.Duration, error) {
+	return time.ParseDuration(c.JobRetention)
+}
+
+// PanicWindowPeriod parses the period over which the panics of a module are counted.
+func (c *Config) PanicWindowPeriod() (time.Duration, error) {
+	return time.ParseDuration(c.Supervision.PanicWindow)
+}
+
+// DefaultTimeout parses the default action timeout.
+func (c *Config) DefaultTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.ActionTimeout)
+}
+
+// DefaultJobTimeout parses the default timeout of the background jobs.
+func (c *Config) DefaultJobTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.JobTimeout)
+}
+
+// TimeoutOverride returns the timeout configured for an action, if any.
+// When several patterns match the action, the most specific one wins.
+func (c *Config) TimeoutOverride(moduleName, actionName string) (time.Duration, bool, error) {
+	name := moduleName + "/" + actionName
+
+	best := ""
+	for pattern := range c.ActionTimeouts {
+		if matched, err := path.Match(pattern, name); err != nil || !matched {
+			continue
+		}
+		if best == "" || len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best) {
+			best = pattern
+		}
+	}
+
+	if best == "" {
+		return 0, false, nil
+	}
+
+	timeout, err := time.ParseDuration(c.ActionTimeouts[best])
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid timeout for %s: %s", best, err.Error())
+	}
+
+	return timeout, true, nil
+}
+
+// Validate ensures the configuration is usable.
+func (c *Config) Validate() error {
+	if _, err := c.Level(); err != nil {
+		return err
+	}
+
+	if _, err := c.ShutdownGracePeriod(); err != nil {
+		return fmt.Errorf("invalid shutdown timeout: %s", err.Error())
+	}
+
+	if _, err := c.DefaultTimeout(); err != nil {
+		return fmt.Errorf("invalid action timeout: %s", err.Error())
+	}
+
+	if _, err := c.DefaultJobTimeout(); err != nil {
+		return fmt.Errorf("invalid job timeout: %s", err.Error())
+	}
+
+	if _, err := c.JobRetentionPeriod(); err != nil {
+		return fmt.Errorf("invalid job retention: %s", err.Error())
+	}
+
+	if c.Supervision.MaxPanics < 0 {
+		return fmt.Errorf("invalid max panics: %d", c.Supervision.MaxPanics)
+	}
+
+	if _, err := c.PanicWindowPeriod(); err != nil {
+		return fmt.Errorf("invalid panic window: %s", err.Error())
+	}
+
+	for pattern, timeout := range c.ActionTimeouts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid action timeout pattern: %s", pattern)
+		}
+		if _, err := time.ParseDuration(timeout); err != nil {
+			return fmt.Errorf("invalid timeout for %s: %s", pattern, err.Error())
+		}
+	}
+
+	listeners, err := c.ServerListeners()
+	if err != nil {
+		return err
+	}
+
+	if len(listeners) == 0 {
+		return fmt.Errorf("no listeners configured")
+	}
+
+	if c.Tracing.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid otlp endpoint: %s", c.Tracing.OTLPEndpoint)
+		}
+	}
+
+	webhooks := make(map[string]bool)
+	for i := range c.Webhooks {
+		rule := &c.Webhooks[i]
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if webhooks[rule.Name] {
+			return fmt.Errorf("duplicate webhook: %s", rule.Name)
+		}
+		webhooks[rule.Name] = true
+	}
+
+	hooks := make(map[string]bool)
+	for i := range c.Hooks {
+		hk := &c.Hooks[i]
+		if err := hk.Validate(); err != nil {
+			return err
+		}
+		if hooks[hk.Name] {
+			return fmt.Errorf("duplicate hook: %s", hk.Name)
+		}
+		hooks[hk.Name] = true
+	}
+
+	if c.TaskDirectory == "" || c.PluginDirectory == "" || c.StateDirectory == "" {
+		return fmt.Errorf("task, plugin and state directories are required")
+	}
+
+	return nil
+}
+
+// Tokens returns the API tokens declared inline and in the token file.
+func (c *Config) Tokens() ([]auth.Token, error) {
+	tokens := append([]auth.Token{}, c.Auth.Tokens...)
+
+	if c.Auth.TokenFile != "" {
+		fileTokens, err := auth.ReadFile(c.Auth.TokenFile)
+		if err != nil {
+			// The default token file is optional.
+			if !os.IsNotExist(err) || c.Auth.TokenFile != path.Join(c.homeDir, defaultTokenFileSuffix) {
+				return nil, err
+			}
+		}
+		tokens = append(tokens, fileTokens...)
+	}
+
+	return tokens, nil
+}
+
+// CheckAuthentication ensures the API isn't served without authentication on
+// listeners reachable from the network, unless explicitly allowed.
+func (c *Config) CheckAuthentication(listeners []interfaces.Listener, tokens []auth.Token) error {
+	if len(tokens) > 0 || c.Auth.AllowUnauthenticated {
+		return nil
+	}
+
+	for _, l := range listeners {
+		if l.Exposed() {
+			return fmt.Errorf("no API tokens configured for listener %s, which is reachable from the network: add tokens or set auth.allow_unauthenticated", l)
+		}
+	}
+
+	return nil
+}
+
+// ModuleSettings returns the settings of a module.
+func (c *Config) ModuleSettings(moduleName string) map[string]interface{} {
+	if settings, ok := c.Modules[moduleName]; ok {
+		return settings
+	}
+	return make(map[string]interface{})
+}
+
+// redactURL only keeps the scheme and host of a URL. Its user info, path and query
+// may hold credentials, e.g. the tokens of Slack and Discord webhooks.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+
+	redacted := u.Scheme + "://" + u.Host
+	if u.User != nil || strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+		redacted += "/" + redactedValue
+	}
+	return redacted
+}
+
+// Redacted returns a copy of the configuration safe for display.
+// The settings of the modules are opaque and may hold credentials, so only their
+// keys are kept.
+func (c *Config) Redacted() Config {
+	redacted := *c
+
+	redacted.Auth.Tokens = nil
+	for _, tok := range c.Auth.Tokens {
+		tok.Token = redactedValue
+		redacted.Auth.Tokens = append(redacted.Auth.Tokens, tok)
+	}
+
+	// Webhook URLs and headers usually carry credentials as well.
+	redacted.Webhooks = nil
+	for _, rule := range c.Webhooks {
+		rule.URL = redactURL(rule.URL)
+		if rule.Secret != "" {
+			rule.Secret = redactedValue
+		}
+		headers := make(map[string]string)
+		for name := range rule.Headers {
+			headers[name] = redactedValue
+		}
+		if len(headers) > 0 {
+			rule.Headers = headers
+		}
+		redacted.Webhooks = append(redacted.Webhooks, rule)
+	}
+
+	redacted.Hooks = nil
+	for _, hk := range c.Hooks {
+		hk.Secret = redactedValue
+		redacted.Hooks = append(redacted.Hooks, hk)
+	}
+
+	redacted.Modules = make(map[string]map[string]interface{})
+	for moduleName, settings := range c.Modules {
+		masked := make(map[string]interface{})
+		for key := range settings {
+			masked[key] = redactedValue
+		}
+		redacted.Modules[moduleName] = masked
+	}
+
+	return redacted
+}
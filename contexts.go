@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/client"
+	"github.com/dalloriam/orc/config"
+)
+
+const (
+	contextCommandName = "context"
+	contextCommandHelp = "Manage the ORC servers the CLI connects to."
+	contextCommandArgs = "[list | current | use NAME | set NAME [KEY=VALUE]... | delete NAME]"
+
+	contextCommandLongHelp = contextCommandHelp + `
+
+Contexts name the servers of ~/` + defaultCLIConfigSuffix + ` ($` + cliConfigEnvVar + `),
+along with their token and TLS settings, so that "orc cli", "orc shell" and "orc shellinit" can switch
+between them with -context NAME, $` + contextEnvVar + ` or the current context:
+
+  orc context set home-server host=https://home.lan:33443 token=s3cr3t ca_cert_file=/etc/orc/home-ca.pem
+  orc context use home-server
+  orc context list
+
+The keys of "set" are host, token, ca_cert_file, client_cert_file and
+client_key_file; an empty value clears the key. Without a context, the CLI uses the
+settings at the top level of the file, or http://` + config.DefaultHost + `.
+
+$` + hostEnvVar + `, $` + tokenEnvVar + ` and the TLS variables override the settings of the current
+context, but not those of a context selected with -context. When $` + hostEnvVar + ` names another
+server, the token and TLS settings of the context are not used.`
+
+	contextEnvVar   = "ORC_CONTEXT"
+	cliConfigEnvVar = "ORC_CLI_CONFIG"
+
+	contextActionList    = "list"
+	contextActionCurrent = "current"
+	contextActionUse     = "use"
+	contextActionSet     = "set"
+	contextActionDelete  = "delete"
+)
+
+// cliConfig is the content of the client settings file. The settings at the top
+// level are used when no context is selected, as in files predating contexts.
+type cliConfig struct {
+	client.Config
+
+	CurrentContext string                   `json:"current_context,omitempty"`
+	Contexts       map[string]client.Config `json:"contexts,omitempty"`
+}
+
+// cliConfigPath returns the path of the client settings file, honoring $ORC_CLI_CONFIG.
+func cliConfigPath() (string, error) {
+	if filePath := os.Getenv(cliConfigEnvVar); filePath != "" {
+		return filePath, nil
+	}
+
+	homeDir, err := config.HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(homeDir, defaultCLIConfigSuffix), nil
+}
+
+// readCLIConfig reads the client settings file. A missing file yields empty settings.
+func readCLIConfig(filePath string) (*cliConfig, error) {
+	cfg := &cliConfig{}
+
+	data, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid client settings %s: %s", filePath, err.Error())
+	}
+	return cfg, nil
+}
+
+// writeCLIConfig writes the client settings file, which is only readable by its
+// owner since it holds tokens.
+func writeCLIConfig(filePath string, cfg *cliConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(filePath), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filePath, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+
+	// The mode is only set when the file is created, existing files may be readable by others.
+	return os.Chmod(filePath, 0600)
+}
+
+// contextNames returns the names of the contexts, sorted.
+func (cfg *cliConfig) contextNames() []string {
+	names := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve returns the settings of the named context, of the context selected by
+// $ORC_CONTEXT or of the current context when the name is empty, or the top-level
+// settings when no context is selected.
+func (cfg *cliConfig) resolve(name string) (client.Config, error) {
+	explicit := name != ""
+	if name == "" {
+		name = os.Getenv(contextEnvVar)
+	}
+	if name == "" {
+		name = cfg.CurrentContext
+	}
+
+	settings := cfg.Config
+	if name != "" {
+		ctxSettings, ok := cfg.Contexts[name]
+		if !ok {
+			return settings, apierr.NotFound("unknown context: %s", name).WithDetail("context", name)
+		}
+		settings = ctxSettings
+	}
+
+	if settings.Host == "" {
+		settings.Host = fmt.Sprintf("http://%s:%d", config.DefaultHost, config.DefaultPort)
+	}
+
+	// A context given on the command line is used as is, the environment being
+	// more likely to target another server.
+	if explicit {
+		return settings, nil
+	}
+
+	// The credentials of the context are not sent to another server.
+	if host := os.Getenv(hostEnvVar); host != "" && host != settings.Host {
+		settings = client.Config{Host: host}
+	}
+
+	overrides := map[string]*string{
+		hostEnvVar:       &settings.Host,
+		tokenEnvVar:      &settings.Token,
+		tlsCAEnvVar:      &settings.CACertFile,
+		tlsCertEnvVar:    &settings.ClientCertFile,
+		tlsCertKeyEnvVar: &settings.ClientKeyFile,
+	}
+	for envVar, field := range overrides {
+		if val := os.Getenv(envVar); val != "" {
+			*field = val
+		}
+	}
+
+	return settings, nil
+}
+
+// setContext creates or updates a context from KEY=VALUE settings.
+func (cfg *cliConfig) setContext(name string, settings []string) error {
+	if name == "" {
+		return errors.New("the context name can't be empty")
+	}
+
+	ctxSettings := cfg.Contexts[name]
+	fields := map[string]*string{
+		"host":             &ctxSettings.Host,
+		"token":            &ctxSettings.Token,
+		"ca_cert_file":     &ctxSettings.CACertFile,
+		"client_cert_file": &ctxSettings.ClientCertFile,
+		"client_key_file":  &ctxSettings.ClientKeyFile,
+	}
+
+	for _, setting := range settings {
+		i := strings.Index(setting, "=")
+		if i < 0 {
+			return fmt.Errorf("invalid setting, expected KEY=VALUE: %s", setting)
+		}
+
+		field, ok := fields[setting[:i]]
+		if !ok {
+			return fmt.Errorf("unknown setting: %s", setting[:i])
+		}
+		*field = setting[i+1:]
+	}
+
+	if ctxSettings.Host != "" {
+		if _, err := client.New(ctxSettings); err != nil {
+			return fmt.Errorf("invalid context %s: %s", name, err.Error())
+		}
+	}
+
+	if cfg.Contexts == nil {
+		cfg.Contexts = make(map[string]client.Config)
+	}
+	cfg.Contexts[name] = ctxSettings
+	return nil
+}
+
+type contextCommand struct{}
+
+func (cmd *contextCommand) Name() string              { return contextCommandName }
+func (cmd *contextCommand) Args() string              { return contextCommandArgs }
+func (cmd *contextCommand) ShortHelp() string         { return contextCommandHelp }
+func (cmd *contextCommand) LongHelp() string          { return contextCommandLongHelp }
+func (cmd *contextCommand) Hidden() bool              { return false }
+func (cmd *contextCommand) Register(fs *flag.FlagSet) {}
+
+func (cmd *contextCommand) Run(ctx context.Context, args []string) error {
+	filePath, err := cliConfigPath()
+	if err != nil {
+		return err
+	}
+
+	action := contextActionList
+	if len(args) > 0 {
+		action = args[0]
+		args = args[1:]
+	}
+
+	return runContextAction(filePath, action, args)
+}
+
+// runContextAction runs a context action against the client settings file.
+func runContextAction(filePath, action string, args []string) error {
+	cfg, err := readCLIConfig(filePath)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case action == contextActionList && len(args) == 0:
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tNAME\tHOST")
+		for _, name := range cfg.contextNames() {
+			current := ""
+			if name == cfg.CurrentContext {
+				current = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", current, name, cfg.Contexts[name].Host)
+		}
+		return w.Flush()
+
+	case action == contextActionCurrent && len(args) == 0:
+		if cfg.CurrentContext == "" {
+			return errors.New("no current context")
+		}
+		fmt.Println(cfg.CurrentContext)
+		return nil
+
+	case action == contextActionUse && len(args) == 1:
+		if _, ok := cfg.Contexts[args[0]]; !ok {
+			return fmt.Errorf("unknown context: %s", args[0])
+		}
+		cfg.CurrentContext = args[0]
+
+	case action == contextActionSet && len(args) >= 1:
+		if err := cfg.setContext(args[0], args[1:]); err != nil {
+			return err
+		}
+
+	case action == contextActionDelete && len(args) == 1:
+		if _, ok := cfg.Contexts[args[0]]; !ok {
+			return fmt.Errorf("unknown context: %s", args[0])
+		}
+		delete(cfg.Contexts, args[0])
+		if cfg.CurrentContext == args[0] {
+			cfg.CurrentContext = ""
+		}
+
+	default:
+		return errors.New("Invalid syntax")
+	}
+
+	return writeCLIConfig(filePath, cfg)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/client"
+	"github.com/dalloriam/orc/output"
+)
+
+func TestCLIConfig_Resolve(t *testing.T) {
+	type testCase struct {
+		name    string
+		context string
+		env     map[string]string
+
+		expectedHost  string
+		expectedToken string
+		wantErr       bool
+	}
+
+	cfg := &cliConfig{
+		Config:         client.Config{Host: "http://top:33000", Token: "top"},
+		CurrentContext: "home",
+		Contexts: map[string]client.Config{
+			"home":   {Host: "https://home:33443", Token: "home"},
+			"laptop": {Token: "laptop"},
+		},
+	}
+
+	cases := []testCase{
+		{"current context", "", nil, "https://home:33443", "home", false},
+		{"named context", "laptop", nil, "http://127.0.0.1:33000", "laptop", false},
+		{"environment context", "", map[string]string{contextEnvVar: "laptop"}, "http://127.0.0.1:33000", "laptop", false},
+		{"environment overrides", "", map[string]string{hostEnvVar: "unix:///orc.sock", tokenEnvVar: "env"}, "unix:///orc.sock", "env", false},
+		{"environment host drops token", "", map[string]string{hostEnvVar: "unix:///orc.sock"}, "unix:///orc.sock", "", false},
+		{"environment host of context", "", map[string]string{hostEnvVar: "https://home:33443"}, "https://home:33443", "home", false},
+		{"named context ignores environment", "home", map[string]string{tokenEnvVar: "env"}, "https://home:33443", "home", false},
+		{"unknown context", "nope", nil, "", "", true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			for envVar, val := range tCase.env {
+				os.Setenv(envVar, val)
+				defer os.Unsetenv(envVar)
+			}
+
+			settings, err := cfg.resolve(tCase.context)
+			if (err != nil) != tCase.wantErr {
+				t.Fatalf("expected err: %v, got err=%v", tCase.wantErr, err)
+			}
+			if tCase.wantErr {
+				return
+			}
+
+			if settings.Host != tCase.expectedHost || settings.Token != tCase.expectedToken {
+				t.Errorf("expected %s with token %s, got %s with token %s", tCase.expectedHost, tCase.expectedToken, settings.Host, settings.Token)
+			}
+		})
+	}
+
+	// Without contexts, the top-level settings are used.
+	if settings, err := (&cliConfig{Config: cfg.Config}).resolve(""); err != nil || settings.Host != "http://top:33000" {
+		t.Errorf("expected top-level settings, got %v (%v)", settings, err)
+	}
+}
+
+func TestRunContextAction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orc-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Existing settings are made private.
+	filePath := path.Join(dir, "orc", "cli.json")
+	if err := os.MkdirAll(path.Dir(filePath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filePath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runContextAction(filePath, contextActionSet, []string{"home", "host=https://home:33443", "token=s3cr3t"}); err != nil {
+		t.Fatalf("unexpected error creating the context: %s", err.Error())
+	}
+	if err := runContextAction(filePath, contextActionSet, []string{"home", "token="}); err != nil {
+		t.Fatalf("unexpected error updating the context: %s", err.Error())
+	}
+	if err := runContextAction(filePath, contextActionUse, []string{"home"}); err != nil {
+		t.Fatalf("unexpected error using the context: %s", err.Error())
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected settings to be private, got mode %s", info.Mode())
+	}
+
+	cfg, err := readCLIConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentContext != "home" || cfg.Contexts["home"] != (client.Config{Host: "https://home:33443"}) {
+		t.Errorf("unexpected settings: %+v", cfg)
+	}
+
+	invalid := [][]string{
+		{contextActionSet, "home", "port=33000"},
+		{contextActionSet, "home", "host"},
+		{contextActionSet, "home", "host=localhost"},
+		{contextActionUse, "nope"},
+		{contextActionDelete, "nope"},
+		{"rename", "home"},
+	}
+	for _, args := range invalid {
+		if err := runContextAction(filePath, args[0], args[1:]); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+
+	if err := runContextAction(filePath, contextActionDelete, []string{"home"}); err != nil {
+		t.Fatalf("unexpected error deleting the context: %s", err.Error())
+	}
+	if cfg, err := readCLIConfig(filePath); err != nil || len(cfg.Contexts) != 0 || cfg.CurrentContext != "" {
+		t.Errorf("expected the context to be deleted, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestCLICommand_CallContexts(t *testing.T) {
+	_, home, cleanupHome := newTestOrc(t)
+	defer cleanupHome()
+	_, laptop, cleanupLaptop := newTestOrc(t)
+	defer cleanupLaptop()
+
+	if status, _ := call(t, http.MethodPost, home.URL+"/keyval/set", `{"key": "name", "val": "home"}`); status != http.StatusOK {
+		t.Fatalf("unexpected status setting the key: %d", status)
+	}
+
+	dir, err := ioutil.TempDir("", "orc-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filePath := path.Join(dir, "cli.json")
+	cfg := &cliConfig{Contexts: map[string]client.Config{"home": {Host: home.URL}, "laptop": {Host: laptop.URL}}}
+	if err := writeCLIConfig(filePath, cfg); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(cliConfigEnvVar, filePath)
+	defer os.Unsetenv(cliConfigEnvVar)
+
+	cmd := &cliCommand{allContexts: true}
+	contexts, err := cmd.targets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := cmd.parseArguments([]string{"key=name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, failures := cmd.callContexts(contexts, "keyval", "get", args)
+
+	if value, _ := results["home"].(map[string]interface{}); value["value"] != "home" {
+		t.Errorf("expected the value of home, got %v", results["home"])
+	}
+	if len(failures) != 1 || failures["laptop"] == nil || failures["laptop"].Code != apierr.CodeNotFound {
+		t.Errorf("expected the key to be missing from laptop, got %v", failures)
+	}
+	if values, err := output.Query(results, ".laptop.error.code"); err != nil || len(values) != 1 || values[0] != string(apierr.CodeNotFound) {
+		t.Errorf("expected the error of laptop in the results, got %v", results["laptop"])
+	}
+}
@@ -0,0 +1,337 @@
+package dashboard
+
+// Path is the path under which the dashboard is served.
+const Path = "/ui/"
+
+// HTML is a self-contained dashboard showing the modules, tasks, run history and
+// keyval store of the server, and invoking its actions. Like the API explorer, it
+// loads no external resources so that it works offline. It is laid out to be
+// usable on tablets and phones.
+const HTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>ORC dashboard</title>
+<style>
+  body { font-family: sans-serif; margin: 0; background: #f5f5f5; color: #222; }
+  header { background: #222; color: #eee; padding: 12px 16px; display: flex; flex-wrap: wrap; align-items: center; gap: 12px; }
+  header h1 { font-size: 18px; margin: 0; flex: 1; }
+  header a { color: #9cf; }
+  header input { width: 240px; max-width: 100%; padding: 6px; }
+  main { max-width: 1100px; margin: 0 auto; padding: 12px; display: grid; grid-template-columns: repeat(auto-fit, minmax(320px, 1fr)); gap: 12px; }
+  section { background: #fff; border: 1px solid #ddd; border-radius: 6px; padding: 8px 12px 12px; overflow: auto; }
+  section.wide { grid-column: 1 / -1; }
+  h2 { font-size: 16px; border-bottom: 1px solid #ccc; padding-bottom: 4px; }
+  table { width: 100%; border-collapse: collapse; font-size: 14px; }
+  th, td { text-align: left; padding: 6px 4px; border-bottom: 1px solid #eee; vertical-align: top; }
+  td.actions { white-space: nowrap; text-align: right; }
+  button { padding: 8px 14px; margin: 2px; border: 0; border-radius: 4px; background: #2a7; color: #fff; font-size: 14px; cursor: pointer; }
+  button.stop, button.delete { background: #c33; }
+  button:disabled { background: #aaa; }
+  input[type=text], select, textarea { width: 100%; box-sizing: border-box; padding: 6px; font-family: monospace; font-size: 14px; }
+  label { display: block; margin: 8px 0 2px; font-size: 13px; }
+  pre { background: #272822; color: #f8f8f2; padding: 8px; overflow: auto; border-radius: 3px; max-height: 320px; }
+  .badge { display: inline-block; padding: 1px 6px; border-radius: 3px; font-size: 12px; background: #ddd; }
+  .ok, .running, .succeeded { background: #2a7; color: #fff; }
+  .failed, .disabled { background: #c33; color: #fff; }
+  .canceled { background: #e90; color: #fff; }
+  .muted { color: #777; font-size: 12px; }
+  #error { color: #c33; grid-column: 1 / -1; margin: 0; }
+  #error:empty { display: none; }
+</style>
+</head>
+<body>
+<header>
+  <h1>ORC dashboard</h1>
+  <a href="../docs/">API explorer</a>
+  <input id="token" type="password" placeholder="Bearer token (optional)">
+</header>
+<main>
+  <p id="error"></p>
+  <section>
+    <h2>Tasks</h2>
+    <table><tbody id="tasks"></tbody></table>
+  </section>
+  <section>
+    <h2>Modules</h2>
+    <table><tbody id="modules"></tbody></table>
+  </section>
+  <section class="wide">
+    <h2>Invoke an action</h2>
+    <form id="invoke">
+      <label>Action <select id="action"></select></label>
+      <label>Arguments (JSON object) <textarea id="arguments" rows="4">{}</textarea></label>
+      <label><input id="async" type="checkbox"> run as background job</label>
+      <button type="submit">Run</button>
+      <p id="invoke-status"></p>
+      <pre id="invoke-output" style="display: none"></pre>
+    </form>
+  </section>
+  <section class="wide">
+    <h2>Key/value store</h2>
+    <table><tbody id="keyval"></tbody></table>
+    <form id="keyval-add">
+      <label>Key <input id="keyval-key" type="text"></label>
+      <label>Value (JSON) <textarea id="keyval-value" rows="2"></textarea></label>
+      <button type="submit">Set</button>
+    </form>
+  </section>
+  <section class="wide">
+    <h2>Run history</h2>
+    <table>
+      <thead><tr><th>Started</th><th>Action</th><th>Caller</th><th>Duration</th><th>Result</th></tr></thead>
+      <tbody id="history"></tbody>
+    </table>
+  </section>
+  <section class="wide">
+    <h2>Background jobs</h2>
+    <table>
+      <thead><tr><th>Created</th><th>Action</th><th>Caller</th><th>Status</th><th></th></tr></thead>
+      <tbody id="jobs"></tbody>
+    </table>
+  </section>
+</main>
+<script>
+(function () {
+  var refreshInterval = 5000;
+  var tokenInput = document.getElementById("token");
+  tokenInput.value = localStorage.getItem("orc-token") || "";
+  tokenInput.addEventListener("change", function () {
+    localStorage.setItem("orc-token", tokenInput.value);
+    refresh();
+  });
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") { node.textContent = attrs[k]; } else { node.setAttribute(k, attrs[k]); }
+    });
+    (children || []).forEach(function (c) {
+      node.appendChild(typeof c === "string" ? document.createTextNode(c) : c);
+    });
+    return node;
+  }
+
+  function badge(text, cls) {
+    return el("span", { "class": "badge " + (cls || text), text: text });
+  }
+
+  function button(text, cls, onClick) {
+    var b = el("button", { type: "button", "class": cls || "", text: text });
+    b.addEventListener("click", function () {
+      b.disabled = true;
+      onClick().then(function () { b.disabled = false; }, function () { b.disabled = false; });
+    });
+    return b;
+  }
+
+  function replace(id, rows) {
+    var body = document.getElementById(id);
+    while (body.firstChild) { body.removeChild(body.firstChild); }
+    rows.forEach(function (row) { body.appendChild(row); });
+  }
+
+  function time(value) {
+    return value ? new Date(value).toLocaleString() : "";
+  }
+
+  function showError(err) {
+    document.getElementById("error").textContent = err ? String(err) : "";
+  }
+
+  // call sends a request to the API, resolving to the decoded response and
+  // rejecting with the message of the error envelope.
+  function call(method, path, body) {
+    var headers = {};
+    if (body !== undefined) { headers["Content-Type"] = "application/json"; }
+    if (tokenInput.value) { headers["Authorization"] = "Bearer " + tokenInput.value; }
+
+    return fetch("../" + path, { method: method, headers: headers, body: body === undefined ? undefined : JSON.stringify(body) })
+      .then(function (resp) {
+        return resp.text().then(function (text) {
+          var data = null;
+          try { data = JSON.parse(text); } catch (e) { data = text; }
+          if (!resp.ok) {
+            var message = data && data.error ? data.error.message : resp.status + " " + resp.statusText;
+            throw new Error(method + " /" + path + ": " + message);
+          }
+          return data;
+        });
+      });
+  }
+
+  // act runs an action triggered by the user, then refreshes the dashboard.
+  function act(method, path, body) {
+    return call(method, path, body).then(function () { showError(); refresh(); }, function (err) { showError(err); throw err; });
+  }
+
+  function renderTasks(data) {
+    var rows = (data.tasks || []).map(function (task) {
+      return el("tr", {}, [
+        el("td", {}, [task.name]),
+        el("td", {}, [task.running ? badge("running") : badge("stopped", "")]),
+        el("td", { "class": "actions" }, [
+          task.running
+            ? button("Stop", "stop", function () { return act("POST", "task/stop", { name: task.name }); })
+            : button("Start", "", function () { return act("POST", "task/start", { name: task.name }); })
+        ])
+      ]);
+    });
+    if (rows.length === 0) { rows = [el("tr", {}, [el("td", { "class": "muted", text: "No tasks defined." })])]; }
+    replace("tasks", rows);
+  }
+
+  var actionSelect = document.getElementById("action");
+
+  function renderModules(data) {
+    var modules = data.modules || [];
+    replace("modules", modules.map(function (mod) {
+      return el("tr", {}, [
+        el("td", {}, [mod.name]),
+        el("td", {}, [badge(mod.kind, ""), " ", mod.disabled ? badge("disabled") : badge("ok")]),
+        el("td", { "class": "muted", text: mod.actions.join(", ") })
+      ]);
+    }));
+
+    // The selection is kept across refreshes.
+    var selected = actionSelect.value;
+    while (actionSelect.firstChild) { actionSelect.removeChild(actionSelect.firstChild); }
+    modules.forEach(function (mod) {
+      mod.actions.forEach(function (action) {
+        var path = mod.name + "/" + action;
+        actionSelect.appendChild(el("option", { value: path, text: path }));
+      });
+    });
+    if (selected) { actionSelect.value = selected; }
+  }
+
+  function renderKeyval(data) {
+    var keyval = document.getElementById("keyval");
+    // Values being edited are not overwritten.
+    if (keyval.contains(document.activeElement)) { return; }
+
+    var values = data.values || {};
+    var rows = Object.keys(values).sort().map(function (key) {
+      var path = "keyval/keys/" + encodeURIComponent(key);
+      var editor = el("textarea", { rows: "1" });
+      editor.value = JSON.stringify(values[key]);
+      return el("tr", {}, [
+        el("td", {}, [key]),
+        el("td", {}, [editor]),
+        el("td", { "class": "actions" }, [
+          button("Save", "", function () {
+            var value;
+            try { value = JSON.parse(editor.value); } catch (e) { value = editor.value; }
+            return act("PUT", path, value);
+          }),
+          button("Delete", "delete", function () { return act("DELETE", path); })
+        ])
+      ]);
+    });
+    if (rows.length === 0) { rows = [el("tr", {}, [el("td", { "class": "muted", text: "The store is empty." })])]; }
+    replace("keyval", rows);
+  }
+
+  function renderHistory(data) {
+    var rows = (data.runs || []).map(function (run) {
+      return el("tr", {}, [
+        el("td", {}, [time(run.started_at)]),
+        el("td", {}, [run.module + "/" + run.action]),
+        el("td", {}, [run.caller || ""]),
+        el("td", {}, [Math.round(run.duration_ms) + " ms"]),
+        el("td", {}, run.error ? [badge("failed"), " ", run.error.message] : [badge("succeeded")])
+      ]);
+    });
+    if (rows.length === 0) { rows = [el("tr", {}, [el("td", { "class": "muted", text: "No actions ran yet." })])]; }
+    replace("history", rows);
+  }
+
+  function renderJobs(data) {
+    var rows = (data.jobs || []).slice().reverse().map(function (job) {
+      var path = "job/" + encodeURIComponent(job.id);
+      var actions = [];
+      if (job.status === "running") {
+        actions.push(button("Cancel", "stop", function () { return act("DELETE", path); }));
+      } else if (job.status === "succeeded") {
+        actions.push(button("Result", "", function () {
+          return call("GET", path + "/result").then(function (result) {
+            showOutput("200 OK", true, result);
+          }, showError);
+        }));
+      }
+      return el("tr", {}, [
+        el("td", {}, [time(job.created_at)]),
+        el("td", {}, [job.module + "/" + job.action]),
+        el("td", {}, [job.caller || ""]),
+        el("td", {}, job.error ? [badge(job.status), " ", job.error.message] : [badge(job.status)]),
+        el("td", { "class": "actions" }, actions)
+      ]);
+    });
+    if (rows.length === 0) { rows = [el("tr", {}, [el("td", { "class": "muted", text: "No background jobs." })])]; }
+    replace("jobs", rows);
+  }
+
+  var invokeStatus = document.getElementById("invoke-status");
+  var invokeOutput = document.getElementById("invoke-output");
+
+  function showOutput(status, ok, data) {
+    invokeStatus.textContent = status;
+    invokeStatus.className = ok ? "badge ok" : "badge failed";
+    invokeOutput.textContent = typeof data === "string" ? data : JSON.stringify(data, null, 2);
+    invokeOutput.style.display = "block";
+  }
+
+  document.getElementById("invoke").addEventListener("submit", function (evt) {
+    evt.preventDefault();
+    var args;
+    try {
+      args = JSON.parse(document.getElementById("arguments").value || "{}");
+    } catch (e) {
+      showOutput("invalid JSON", false, String(e));
+      return;
+    }
+
+    var path = actionSelect.value + (document.getElementById("async").checked ? "?async=1" : "");
+    call("POST", path, args).then(function (data) {
+      showOutput("OK", true, data);
+      refresh();
+    }, function (err) {
+      showOutput("error", false, String(err));
+    });
+  });
+
+  document.getElementById("keyval-add").addEventListener("submit", function (evt) {
+    evt.preventDefault();
+    var key = document.getElementById("keyval-key").value;
+    var raw = document.getElementById("keyval-value").value;
+    if (!key) { return; }
+
+    var value;
+    try { value = JSON.parse(raw); } catch (e) { value = raw; }
+    act("PUT", "keyval/keys/" + encodeURIComponent(key), value).then(function () {
+      document.getElementById("keyval-key").value = "";
+      document.getElementById("keyval-value").value = "";
+    }, function () {});
+  });
+
+  function refresh() {
+    var panels = [
+      ["manage/modules", renderModules],
+      ["task/list", renderTasks],
+      ["keyval/list", renderKeyval],
+      ["manage/history", renderHistory],
+      ["job/list", renderJobs]
+    ];
+    Promise.all(panels.map(function (panel) {
+      return call("GET", panel[0]).then(panel[1]);
+    })).then(function () { showError(); }, showError);
+  }
+
+  refresh();
+  setInterval(refresh, refreshInterval);
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,227 @@
+package events
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dalloriam/orc/request"
+)
+
+const (
+	// Path is the path of the event stream.
+	Path = "/events"
+
+	// ModuleName and SubscribeAction form the scope granting access to the event stream,
+	// e.g. "events/subscribe".
+	ModuleName      = "events"
+	SubscribeAction = "subscribe"
+
+	// HistorySize is the number of events kept to be replayed to reconnecting subscribers.
+	HistorySize = 256
+
+	subscriptionBuffer = 64
+)
+
+// Topics published by ORC. Topics are of the form "module/event".
+const (
+	TopicTaskStarted         = "task/started"
+	TopicTaskStopped         = "task/stopped"
+	TopicTaskExited          = "task/exited"
+	TopicTaskFailed          = "task/failed"
+	TopicJobFinished         = "job/finished"
+	TopicKeySet              = "keyval/set"
+	TopicKeyCleared          = "keyval/cleared"
+	TopicServiceRegistered   = "registry/registered"
+	TopicServiceDeregistered = "registry/deregistered"
+	TopicServiceExpired      = "registry/expired"
+	TopicPluginLoaded        = "plugin/loaded"
+	TopicModuleRemoved       = "module/removed"
+	TopicActionInvoked       = "action/invoked"
+	TopicActionCompleted     = "action/completed"
+)
+
+// Event is something that happened on the server.
+type Event struct {
+	ID        uint64                 `json:"id"`
+	Topic     string                 `json:"topic"`
+	Time      time.Time              `json:"time"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// Module returns the name of the module the event is about: the "module" of its
+// data, e.g. for the action and job events, or else the module of its topic.
+func (e *Event) Module() string {
+	if moduleName, ok := e.Data["module"].(string); ok && moduleName != "" {
+		return moduleName
+	}
+	return strings.SplitN(e.Topic, "/", 2)[0]
+}
+
+// Match returns whether the topic matches one of the patterns.
+// Patterns are of the form "module/event" and may contain wildcards, e.g. "task/*".
+// A pattern without a slash matches all the events of a module, and "*" matches
+// every event. No patterns match every event as well.
+func Match(patterns []string, topic string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			pattern += "/*"
+		}
+		if matched, err := path.Match(pattern, topic); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidPattern returns whether the topic pattern is well-formed.
+func ValidPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// Subscription receives the events matching its patterns.
+type Subscription struct {
+	bus      *Bus
+	patterns []string
+	events   chan Event
+
+	closeOnce sync.Once
+}
+
+// Events returns the channel of the events. It is closed when the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.bus.unsubscribe(s)
+	})
+}
+
+// Bus dispatches the events published by the modules to the subscribers.
+// Publishing never blocks: events are dropped for the subscribers that don't
+// keep up. A nil bus discards the events.
+type Bus struct {
+	mu            sync.Mutex
+	lastID        uint64
+	history       []Event
+	subscriptions map[*Subscription]struct{}
+	closed        bool
+}
+
+// NewBus returns a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subscriptions: make(map[*Subscription]struct{})}
+}
+
+// Publish sends an event to the subscribers, along with the request ID of ctx.
+func (b *Bus) Publish(ctx context.Context, topic string, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	evt := Event{
+		ID:        b.lastID,
+		Topic:     topic,
+		Time:      time.Now().UTC(),
+		RequestID: request.ID(ctx),
+		Data:      data,
+	}
+
+	b.history = append(b.history, evt)
+	if len(b.history) > HistorySize {
+		b.history = append([]Event{}, b.history[len(b.history)-HistorySize:]...)
+	}
+
+	eventsPublished.WithLabelValues(topic).Inc()
+
+	for sub := range b.subscriptions {
+		if !Match(sub.patterns, topic) {
+			continue
+		}
+
+		select {
+		case sub.events <- evt:
+		default:
+			eventsDropped.WithLabelValues().Inc()
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events matching the patterns, see Match.
+// The retained events published after afterID are replayed first, so that
+// subscribers can resume where they left off. An afterID of zero replays nothing.
+func (b *Bus) Subscribe(patterns []string, afterID uint64) *Subscription {
+	sub := &Subscription{
+		bus:      b,
+		patterns: patterns,
+		events:   make(chan Event, subscriptionBuffer+HistorySize),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+
+	if afterID > 0 {
+		for _, evt := range b.history {
+			if evt.ID > afterID && Match(patterns, evt.Topic) {
+				sub.events <- evt
+			}
+		}
+	}
+
+	b.subscriptions[sub] = struct{}{}
+	eventSubscribers.WithLabelValues().Inc()
+
+	return sub
+}
+
+// Close ends all the subscriptions, e.g. so that event streams don't hold up the
+// shutdown of the server. Subscriptions made after Close are ended right away.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscriptions {
+		b.remove(sub)
+	}
+}
+
+func (b *Bus) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscriptions[sub]; ok {
+		b.remove(sub)
+	}
+}
+
+// remove ends a subscription. The caller must hold the lock.
+func (b *Bus) remove(sub *Subscription) {
+	delete(b.subscriptions, sub)
+	close(sub.events)
+	eventSubscribers.WithLabelValues().Dec()
+}
@@ -0,0 +1,148 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/request"
+)
+
+func TestMatch(t *testing.T) {
+	type testCase struct {
+		name     string
+		patterns []string
+		topic    string
+
+		want bool
+	}
+
+	cases := []testCase{
+		{"no patterns", nil, "task/started", true},
+		{"wildcard", []string{"*"}, "task/started", true},
+		{"exact", []string{"task/started"}, "task/started", true},
+		{"other topic", []string{"task/started"}, "task/exited", false},
+		{"topic wildcard", []string{"task/*"}, "task/exited", true},
+		{"module name", []string{"keyval"}, "keyval/set", true},
+		{"other module", []string{"keyval"}, "task/started", false},
+		{"any of the patterns", []string{"keyval", "task/exited"}, "task/exited", true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if actual := events.Match(tCase.patterns, tCase.topic); actual != tCase.want {
+				t.Errorf("expected %v, got %v", tCase.want, actual)
+			}
+		})
+	}
+}
+
+func TestEvent_Module(t *testing.T) {
+	type testCase struct {
+		name  string
+		event events.Event
+
+		want string
+	}
+
+	cases := []testCase{
+		{"topic", events.Event{Topic: events.TopicTaskStarted}, "task"},
+		{"data", events.Event{Topic: events.TopicJobFinished, Data: map[string]interface{}{"module": "local"}}, "local"},
+		{"empty data module", events.Event{Topic: events.TopicKeySet, Data: map[string]interface{}{"module": ""}}, "keyval"},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if actual := tCase.event.Module(); actual != tCase.want {
+				t.Errorf("expected %s, got %s", tCase.want, actual)
+			}
+		})
+	}
+}
+
+func receive(t *testing.T, sub *events.Subscription) events.Event {
+	select {
+	case evt := <-sub.Events():
+		return evt
+	case <-time.After(time.Second):
+		t.Fatalf("no event received")
+	}
+	return events.Event{}
+}
+
+func TestBus_Publish(t *testing.T) {
+	bus := events.NewBus()
+
+	tasks := bus.Subscribe([]string{"task/*"}, 0)
+	defer tasks.Close()
+	all := bus.Subscribe(nil, 0)
+	defer all.Close()
+
+	ctx := request.WithID(context.Background(), "req-1")
+	bus.Publish(ctx, events.TopicKeySet, map[string]interface{}{"key": "a"})
+	bus.Publish(ctx, events.TopicTaskStarted, map[string]interface{}{"task": "web"})
+
+	if evt := receive(t, tasks); evt.Topic != events.TopicTaskStarted || evt.Data["task"] != "web" || evt.RequestID != "req-1" {
+		t.Errorf("unexpected event: %+v", evt)
+	}
+
+	first, second := receive(t, all), receive(t, all)
+	if first.Topic != events.TopicKeySet || second.Topic != events.TopicTaskStarted || second.ID <= first.ID {
+		t.Errorf("expected events in order, got %+v then %+v", first, second)
+	}
+}
+
+func TestBus_Replay(t *testing.T) {
+	bus := events.NewBus()
+
+	for i := 0; i < 3; i++ {
+		bus.Publish(context.Background(), events.TopicKeySet, nil)
+	}
+
+	sub := bus.Subscribe(nil, 1)
+	defer sub.Close()
+
+	if first, second := receive(t, sub), receive(t, sub); first.ID != 2 || second.ID != 3 {
+		t.Errorf("expected the events after the last ID to be replayed, got %d and %d", first.ID, second.ID)
+	}
+}
+
+func TestBus_SlowSubscriber(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(nil, 0)
+	defer sub.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10000; i++ {
+			bus.Publish(context.Background(), events.TopicKeySet, nil)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected publishing not to block on slow subscribers")
+	}
+}
+
+func TestBus_Close(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(nil, 0)
+
+	bus.Close()
+	sub.Close()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Errorf("expected subscription to be closed")
+	}
+
+	if _, ok := <-bus.Subscribe(nil, 0).Events(); ok {
+		t.Errorf("expected subscriptions to a closed bus to be closed")
+	}
+
+	var nilBus *events.Bus
+	nilBus.Publish(context.Background(), events.TopicKeySet, nil)
+}
@@ -0,0 +1,14 @@
+package events
+
+import "github.com/dalloriam/orc/metrics"
+
+var (
+	eventsPublished  = metrics.NewCounterVec("orc_events_published_total", "Number of events published.", "topic")
+	eventsDropped    = metrics.NewCounterVec("orc_events_dropped_total", "Number of events dropped for subscribers that did not keep up.")
+	eventSubscribers = metrics.NewGaugeVec("orc_event_subscribers", "Number of subscribers to the event stream.")
+)
+
+func init() {
+	metrics.MustRegister(eventsPublished, eventsDropped, eventSubscribers)
+	eventSubscribers.WithLabelValues().Set(0)
+}
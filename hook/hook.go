@@ -0,0 +1,369 @@
+package hook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Path is the prefix of the hook URLs, e.g. /hooks/deploy.
+	Path = "/hooks/"
+
+	// callerPrefix identifies the hooks in the caller of the actions, e.g. "hook:deploy".
+	callerPrefix = "hook:"
+
+	maxPayloadSize = 1 << 20
+)
+
+// Signature schemes of the hooks.
+const (
+	// SignatureGitHub verifies the X-Hub-Signature-256 header sent by GitHub and compatible
+	// services, "sha256=" followed by the hex HMAC-SHA256 of the payload.
+	SignatureGitHub = "github"
+
+	// SignatureHMAC verifies the hex HMAC-SHA256 of the payload sent in the signature
+	// header of the hook, X-Orc-Signature by default. The "sha256=" prefix is optional.
+	SignatureHMAC = "hmac"
+
+	githubSignatureHeader = "X-Hub-Signature-256"
+	signaturePrefix       = "sha256="
+)
+
+// Hook invokes a module action when its URL receives a signed JSON payload, e.g.
+// starting the deploy task on pushes to the main branch:
+//
+//	{"name": "deploy", "module": "task", "action": "start", "secret": "...",
+//	 "signature": "github", "filter": {"ref": "refs/heads/main"},
+//	 "arguments": {"name": "deploy"}}
+//
+// or storing the last commit pushed in the keyval store:
+//
+//	{"name": "push", "module": "keyval", "action": "set", "secret": "...",
+//	 "signature": "github", "arguments": {"key": "last_commit"},
+//	 "mappings": {"val": "head_commit.id"}}
+type Hook struct {
+	Name   string `json:"name"`
+	Module string `json:"module"`
+	Action string `json:"action"`
+
+	// Secret is the shared secret of the payload signatures.
+	Secret string `json:"secret"`
+
+	// Signature is the signature scheme, SignatureHMAC by default.
+	Signature string `json:"signature,omitempty"`
+
+	// SignatureHeader overrides the header holding the signature of the hmac scheme.
+	SignatureHeader string `json:"signature_header,omitempty"`
+
+	// Filter only triggers the action for the payloads holding these values, indexed
+	// by path, see Extract. Other payloads are acknowledged and ignored.
+	Filter map[string]interface{} `json:"filter,omitempty"`
+
+	// Arguments are fixed arguments of the action.
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+
+	// Mappings are the arguments of the action extracted from the payload, indexed by
+	// argument name. Arguments missing from the payload are left out.
+	Mappings map[string]string `json:"mappings,omitempty"`
+
+	// Async runs the action as a background job.
+	Async bool `json:"async,omitempty"`
+}
+
+// Validate ensures the hook is usable.
+func (h *Hook) Validate() error {
+	if h.Name == "" || strings.Contains(h.Name, "/") {
+		return fmt.Errorf("invalid hook name: %q", h.Name)
+	}
+
+	if h.Module == "" || h.Action == "" {
+		return fmt.Errorf("no action for hook %s", h.Name)
+	}
+
+	if h.Secret == "" {
+		return fmt.Errorf("no secret for hook %s", h.Name)
+	}
+
+	switch h.Signature {
+	case "", SignatureHMAC, SignatureGitHub:
+	default:
+		return fmt.Errorf("unknown signature scheme for hook %s: %s", h.Name, h.Signature)
+	}
+
+	for expr := range h.Filter {
+		if _, err := parsePath(expr); err != nil {
+			return fmt.Errorf("invalid filter for hook %s: %s", h.Name, err.Error())
+		}
+	}
+
+	for name, expr := range h.Mappings {
+		if _, err := parsePath(expr); err != nil {
+			return fmt.Errorf("invalid mapping of %s for hook %s: %s", name, h.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Verify checks the signature of the payload.
+func (h *Hook) Verify(header http.Header, payload []byte) error {
+	var signature string
+	switch h.Signature {
+	case SignatureGitHub:
+		signature = header.Get(githubSignatureHeader)
+		if !strings.HasPrefix(signature, signaturePrefix) {
+			return fmt.Errorf("missing %s header", githubSignatureHeader)
+		}
+	default:
+		name := h.SignatureHeader
+		if name == "" {
+			name = webhook.SignatureHeader
+		}
+		signature = header.Get(name)
+		if signature == "" {
+			return fmt.Errorf("missing %s header", name)
+		}
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return fmt.Errorf("malformed signature")
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.Secret))
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
+
+// Matches returns whether the payload passes the filter of the hook.
+func (h *Hook) Matches(doc interface{}) bool {
+	for expr, want := range h.Filter {
+		got, ok := Extract(doc, expr)
+		if !ok || fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Args returns the arguments of the action for the payload.
+func (h *Hook) Args(doc interface{}) map[string]interface{} {
+	args := make(map[string]interface{}, len(h.Arguments)+len(h.Mappings))
+	for name, val := range h.Arguments {
+		args[name] = val
+	}
+	for name, expr := range h.Mappings {
+		if val, ok := Extract(doc, expr); ok {
+			args[name] = val
+		}
+	}
+	return args
+}
+
+// Extract returns the value at a path of a JSON document. Paths are dot-separated
+// keys with optional array indexes, and may start with "$", e.g. "$.commits[0].id"
+// or "repository.name". "$" alone is the whole document.
+func Extract(doc interface{}, expr string) (interface{}, bool) {
+	segments, err := parsePath(expr)
+	if err != nil {
+		return nil, false
+	}
+
+	val := doc
+	for _, segment := range segments {
+		switch s := segment.(type) {
+		case string:
+			obj, ok := val.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if val, ok = obj[s]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := val.([]interface{})
+			if !ok || s >= len(arr) {
+				return nil, false
+			}
+			val = arr[s]
+		}
+	}
+
+	return val, true
+}
+
+// parsePath splits a path into keys and array indexes.
+func parsePath(expr string) ([]interface{}, error) {
+	if expr == "$" {
+		return nil, nil
+	}
+
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(expr, "$"), ".")
+	if trimmed == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	var segments []interface{}
+	for _, part := range strings.Split(trimmed, ".") {
+		key := part
+		rest := ""
+		if i := strings.Index(part, "["); i >= 0 {
+			key, rest = part[:i], part[i:]
+		}
+
+		if key == "" && rest == "" {
+			return nil, fmt.Errorf("invalid path: %s", expr)
+		}
+		if key != "" {
+			segments = append(segments, key)
+		}
+
+		for rest != "" {
+			end := strings.Index(rest, "]")
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid path: %s", expr)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("invalid index in path: %s", expr)
+			}
+			segments = append(segments, idx)
+			rest = rest[end+1:]
+		}
+	}
+
+	return segments, nil
+}
+
+// InvokeFunc runs a module action.
+type InvokeFunc func(ctx context.Context, moduleName, actionName string, data map[string]interface{}) ([]byte, error)
+
+// Handler serves the hooks on /hooks/{name}. The signature of the payload replaces
+// the API tokens, so the actions run regardless of the authentication settings,
+// with a caller of the form "hook:{name}".
+type Handler struct {
+	mu     sync.RWMutex
+	hooks  map[string]Hook
+	invoke InvokeFunc
+}
+
+// NewHandler returns a handler without hooks, invoking the actions with invoke.
+func NewHandler(invoke InvokeFunc) *Handler {
+	return &Handler{hooks: make(map[string]Hook), invoke: invoke}
+}
+
+// SetHooks replaces the hooks.
+func (h *Handler) SetHooks(hooks []Hook) error {
+	hookMap := make(map[string]Hook)
+	for _, hk := range hooks {
+		if err := hk.Validate(); err != nil {
+			return err
+		}
+		if _, ok := hookMap[hk.Name]; ok {
+			return fmt.Errorf("duplicate hook: %s", hk.Name)
+		}
+		hookMap[hk.Name] = hk
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = hookMap
+
+	return nil
+}
+
+func (h *Handler) getHook(name string) (Hook, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	hk, ok := h.hooks[name]
+	return hk, ok
+}
+
+// ServeHTTP verifies the payload and invokes the action of the hook.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, Path)
+	ctx := request.WithCaller(r.Context(), callerPrefix+name)
+	ctxLog := request.Log(ctx).WithField("hook", name)
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		apierr.Write(w, apierr.MethodNotAllowed("method %s not allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+
+	hk, ok := h.getHook(name)
+	if !ok {
+		apierr.Write(w, apierr.NotFound("unknown hook: %s", name).WithDetail("hook", name))
+		return
+	}
+
+	payload, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		hookRequests.WithLabelValues(name, resultRejected).Inc()
+		apierr.Write(w, apierr.InvalidArgument("error reading payload: %s", err.Error()))
+		return
+	}
+
+	if err := hk.Verify(r.Header, payload); err != nil {
+		hookRequests.WithLabelValues(name, resultRejected).Inc()
+		ctxLog.Warnf("rejected hook request from %s: %s", r.RemoteAddr, err.Error())
+		apierr.Write(w, apierr.New(apierr.CodeUnauthenticated, "%s", err.Error()))
+		return
+	}
+
+	var doc interface{}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &doc); err != nil {
+			hookRequests.WithLabelValues(name, resultRejected).Inc()
+			apierr.Write(w, apierr.InvalidArgument("invalid JSON payload: %s", err.Error()))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !hk.Matches(doc) {
+		hookRequests.WithLabelValues(name, resultIgnored).Inc()
+		ctxLog.Debug("hook payload filtered out")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"triggered": false}`)
+		return
+	}
+
+	if hk.Async {
+		ctx = request.WithAsync(ctx)
+	}
+
+	ctxLog.WithFields(logrus.Fields{
+		"module": hk.Module,
+		"action": hk.Action,
+	}).Info("hook triggered")
+
+	out, err := h.invoke(ctx, hk.Module, hk.Action, hk.Args(doc))
+	if err != nil {
+		hookRequests.WithLabelValues(name, resultFailed).Inc()
+		apierr.Write(w, err)
+		return
+	}
+
+	hookRequests.WithLabelValues(name, resultTriggered).Inc()
+	w.Write(out)
+}
@@ -0,0 +1,225 @@
+package hook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dalloriam/orc/hook"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logrus.SetLevel(logrus.PanicLevel)
+}
+
+func TestExtract(t *testing.T) {
+	type testCase struct {
+		name string
+		expr string
+
+		expected interface{}
+		found    bool
+	}
+
+	var doc interface{}
+	payload := `{"ref": "refs/heads/main", "repository": {"name": "orc"}, "commits": [{"id": "a"}, {"id": "b"}], "matrix": [[1, 2]]}`
+	if err := json.Unmarshal([]byte(payload), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []testCase{
+		{"key", "ref", "refs/heads/main", true},
+		{"root prefix", "$.ref", "refs/heads/main", true},
+		{"nested key", "repository.name", "orc", true},
+		{"index", "commits[1].id", "b", true},
+		{"nested index", "$.matrix[0][1]", float64(2), true},
+		{"whole document", "$", doc, true},
+		{"missing key", "repository.owner", nil, false},
+		{"index out of range", "commits[2].id", nil, false},
+		{"index on object", "repository[0]", nil, false},
+		{"invalid path", "commits[x]", nil, false},
+		{"empty segment", "repository..name", nil, false},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			actual, found := hook.Extract(doc, tCase.expr)
+			if found != tCase.found || !reflect.DeepEqual(actual, tCase.expected) {
+				t.Errorf("expected %v (found=%v), got %v (found=%v)", tCase.expected, tCase.found, actual, found)
+			}
+		})
+	}
+}
+
+func TestHook_Validate(t *testing.T) {
+	type testCase struct {
+		name string
+		hook hook.Hook
+
+		wantErr bool
+	}
+
+	valid := hook.Hook{Name: "deploy", Module: "task", Action: "start", Secret: "hunter2"}
+	withChange := func(change func(hk *hook.Hook)) hook.Hook {
+		hk := valid
+		change(&hk)
+		return hk
+	}
+
+	cases := []testCase{
+		{"valid", valid, false},
+		{"github signature", withChange(func(hk *hook.Hook) { hk.Signature = hook.SignatureGitHub }), false},
+		{"no name", withChange(func(hk *hook.Hook) { hk.Name = "" }), true},
+		{"name with slash", withChange(func(hk *hook.Hook) { hk.Name = "a/b" }), true},
+		{"no action", withChange(func(hk *hook.Hook) { hk.Action = "" }), true},
+		{"no secret", withChange(func(hk *hook.Hook) { hk.Secret = "" }), true},
+		{"unknown signature", withChange(func(hk *hook.Hook) { hk.Signature = "md5" }), true},
+		{"bad mapping", withChange(func(hk *hook.Hook) { hk.Mappings = map[string]string{"commit": "commits["} }), true},
+		{"bad filter", withChange(func(hk *hook.Hook) { hk.Filter = map[string]interface{}{"": "main"} }), true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if err := tCase.hook.Validate(); (err != nil) != tCase.wantErr {
+				t.Errorf("expected err: %v, got err=%v", tCase.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHook_Verify(t *testing.T) {
+	type testCase struct {
+		name      string
+		signature string
+		header    string
+		value     string
+
+		wantErr bool
+	}
+
+	payload := []byte(`{"ref": "refs/heads/main"}`)
+	valid := webhook.Sign("hunter2", payload)
+
+	cases := []testCase{
+		{"hmac", hook.SignatureHMAC, webhook.SignatureHeader, valid, false},
+		{"hmac without prefix", hook.SignatureHMAC, webhook.SignatureHeader, strings.TrimPrefix(valid, "sha256="), false},
+		{"github", hook.SignatureGitHub, "X-Hub-Signature-256", valid, false},
+		{"github without prefix", hook.SignatureGitHub, "X-Hub-Signature-256", strings.TrimPrefix(valid, "sha256="), true},
+		{"missing header", hook.SignatureHMAC, "X-Other", valid, true},
+		{"wrong secret", hook.SignatureHMAC, webhook.SignatureHeader, webhook.Sign("wrong", payload), true},
+		{"malformed", hook.SignatureHMAC, webhook.SignatureHeader, "sha256=zz", true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			hk := hook.Hook{Name: "deploy", Secret: "hunter2", Signature: tCase.signature}
+			header := http.Header{}
+			header.Set(tCase.header, tCase.value)
+
+			if err := hk.Verify(header, payload); (err != nil) != tCase.wantErr {
+				t.Errorf("expected err: %v, got err=%v", tCase.wantErr, err)
+			}
+		})
+	}
+}
+
+type invocation struct {
+	module string
+	action string
+	caller string
+	data   map[string]interface{}
+}
+
+func TestHandler(t *testing.T) {
+	type testCase struct {
+		name    string
+		method  string
+		path    string
+		payload string
+		secret  string
+
+		expectedStatus int
+		expectedData   map[string]interface{}
+	}
+
+	cases := []testCase{
+		{
+			"triggered", http.MethodPost, "/hooks/deploy", `{"ref": "refs/heads/main", "after": "abc123"}`, "hunter2",
+			http.StatusOK, map[string]interface{}{"name": "deploy", "commit": "abc123"},
+		},
+		{"filtered out", http.MethodPost, "/hooks/deploy", `{"ref": "refs/heads/dev"}`, "hunter2", http.StatusAccepted, nil},
+		{"bad signature", http.MethodPost, "/hooks/deploy", `{"ref": "refs/heads/main"}`, "wrong", http.StatusUnauthorized, nil},
+		{"invalid JSON", http.MethodPost, "/hooks/deploy", `{"ref"`, "hunter2", http.StatusBadRequest, nil},
+		{"unknown hook", http.MethodPost, "/hooks/build", `{}`, "hunter2", http.StatusNotFound, nil},
+		{"bad method", http.MethodGet, "/hooks/deploy", "", "hunter2", http.StatusMethodNotAllowed, nil},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			var invoked *invocation
+			h := hook.NewHandler(func(ctx context.Context, moduleName, actionName string, data map[string]interface{}) ([]byte, error) {
+				invoked = &invocation{module: moduleName, action: actionName, caller: request.Caller(ctx), data: data}
+				return []byte(`{}`), nil
+			})
+
+			err := h.SetHooks([]hook.Hook{{
+				Name:      "deploy",
+				Module:    "task",
+				Action:    "start",
+				Secret:    "hunter2",
+				Signature: hook.SignatureGitHub,
+				Filter:    map[string]interface{}{"ref": "refs/heads/main"},
+				Arguments: map[string]interface{}{"name": "deploy"},
+				Mappings:  map[string]string{"commit": "after", "missing": "head_commit.id"},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(tCase.method, tCase.path, strings.NewReader(tCase.payload))
+			req.Header.Set("X-Hub-Signature-256", webhook.Sign(tCase.secret, []byte(tCase.payload)))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tCase.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tCase.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tCase.expectedData == nil {
+				if invoked != nil {
+					t.Errorf("expected no invocation, got %+v", invoked)
+				}
+				return
+			}
+
+			if invoked == nil {
+				t.Fatalf("expected an invocation")
+			}
+			if invoked.module != "task" || invoked.action != "start" || invoked.caller != "hook:deploy" {
+				t.Errorf("unexpected invocation: %+v", invoked)
+			}
+			if !reflect.DeepEqual(invoked.data, tCase.expectedData) {
+				t.Errorf("expected arguments %v, got %v", tCase.expectedData, invoked.data)
+			}
+		})
+	}
+}
+
+func TestHandler_SetHooks(t *testing.T) {
+	h := hook.NewHandler(func(ctx context.Context, moduleName, actionName string, data map[string]interface{}) ([]byte, error) {
+		return nil, errors.New("not invoked")
+	})
+
+	hk := hook.Hook{Name: "deploy", Module: "task", Action: "start", Secret: "hunter2"}
+	if err := h.SetHooks([]hook.Hook{hk, hk}); err == nil {
+		t.Errorf("expected duplicate hooks to be rejected")
+	}
+}
@@ -0,0 +1,17 @@
+package hook
+
+import "github.com/dalloriam/orc/metrics"
+
+// Results of hook requests.
+const (
+	resultTriggered = "triggered"
+	resultIgnored   = "ignored"
+	resultRejected  = "rejected"
+	resultFailed    = "failed"
+)
+
+var hookRequests = metrics.NewCounterVec("orc_hook_requests_total", "Number of requests received by the inbound hooks, by result.", "hook", "result")
+
+func init() {
+	metrics.MustRegister(hookRequests)
+}
@@ -0,0 +1,101 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+type accessKey struct{}
+
+// accessEntry holds the request details only known to the handlers, such as the caller.
+type accessEntry struct {
+	caller string
+}
+
+// setCaller records the caller of the request in its access log entry.
+func setCaller(ctx context.Context, caller string) {
+	if entry, ok := ctx.Value(accessKey{}).(*accessEntry); ok {
+		entry.caller = caller
+	}
+}
+
+// countingReader counts the bytes read from the request body.
+type countingReader struct {
+	io.ReadCloser
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += n
+	return n, err
+}
+
+// AccessLog assigns an ID to every request, honoring the X-Request-ID header sent
+// by the client, and returns it in the response headers. The ID is carried by the
+// request context, so that module, plugin and task logs can be correlated with the
+// request that caused them. A structured access log line is emitted once the request
+// is served, and a server span is recorded when tracing is enabled.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := request.IDFromHeader(r.Header)
+		w.Header().Set(request.IDHeader, requestID)
+
+		ctx := request.WithID(r.Context(), requestID)
+		if traceParent := r.Header.Get(request.TraceParentHeader); traceParent != "" {
+			if _, _, ok := tracing.ParseTraceParent(traceParent); ok {
+				ctx = request.WithTraceParent(ctx, traceParent)
+			}
+		}
+
+		ctx, span := tracing.Start(ctx, r.Method+" "+r.URL.Path, tracing.KindServer)
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		span.SetAttribute("orc.request_id", requestID)
+
+		entry := &accessEntry{caller: request.Anonymous}
+		ctx = context.WithValue(ctx, accessKey{}, entry)
+
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		duration := time.Since(start)
+
+		span.SetAttribute("http.status_code", rec.status)
+		span.SetAttribute("orc.caller", entry.caller)
+		if rec.status >= http.StatusInternalServerError {
+			span.SetError(errorStatus(rec.status))
+		}
+		span.End()
+
+		logrus.WithFields(logrus.Fields{
+			"request_id":  requestID,
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      rec.status,
+			"duration_ms": float64(duration.Nanoseconds()) / 1e6,
+			"caller":      entry.caller,
+			"bytes_in":    body.read,
+			"bytes_out":   rec.written,
+			"remote_addr": r.RemoteAddr,
+		}).Info("http request served")
+	})
+}
+
+// errorStatus is the error recorded on the spans of failed requests.
+type errorStatus int
+
+func (s errorStatus) Error() string {
+	return http.StatusText(int(s))
+}
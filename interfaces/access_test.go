@@ -0,0 +1,73 @@
+package interfaces
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dalloriam/orc/request"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	logrus.SetOutput(&logs)
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+	defer logrus.SetOutput(ioutil.Discard)
+
+	var seenID string
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenID = request.ID(r.Context())
+		setCaller(r.Context(), "deploy")
+
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/keyval/get", strings.NewReader(`{"key": "secret"}`))
+	req.Header.Set(request.IDHeader, "deploy-1234")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if seenID != "deploy-1234" {
+		t.Errorf("expected the client request ID to be honored, got %s", seenID)
+	}
+
+	if rec.Header().Get(request.IDHeader) != "deploy-1234" {
+		t.Errorf("expected the request ID to be returned, got %s", rec.Header().Get(request.IDHeader))
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid access log line: %s", logs.String())
+	}
+
+	expected := map[string]interface{}{
+		"request_id": "deploy-1234",
+		"method":     "POST",
+		"path":       "/keyval/get",
+		"status":     float64(http.StatusTeapot),
+		"caller":     "deploy",
+		"bytes_in":   float64(17),
+		"bytes_out":  float64(15),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value, entry[key])
+		}
+	}
+
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Errorf("expected the request duration to be logged")
+	}
+
+	if strings.Contains(logs.String(), "secret") {
+		t.Errorf("the request payload leaked into the access log")
+	}
+}
@@ -0,0 +1,118 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/request"
+)
+
+// eventsHeartbeat is the interval at which comments are sent on idle event streams,
+// so that proxies and clients don't time them out.
+const eventsHeartbeat = 15 * time.Second
+
+// topicParam is the query parameter filtering the streamed events, it may be repeated.
+const topicParam = "topic"
+
+// EventsHandler streams the events of the bus as server-sent events.
+// Clients filter the events with topic query parameters, e.g. /events?topic=task/*,
+// and resume after reconnecting with the Last-Event-ID header. The stream requires
+// a token allowed to call events/subscribe when authentication is enabled, and
+// only streams the events of the modules the token may call, see visibleEvent.
+func (s *Server) EventsHandler(bus *events.Bus) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeError(w, apierr.MethodNotAllowed("method %s not allowed on %s", r.Method, r.URL.Path))
+			return
+		}
+
+		ctx, err := authorize(r.Context(), s.authenticator, r.Header.Get("Authorization"), events.ModuleName, events.SubscribeAction)
+		if err != nil {
+			request.Log(ctx).Warnf("rejected event subscription from %s: %s", r.RemoteAddr, err.Error())
+			writeAuthError(w, err)
+			return
+		}
+
+		patterns := r.URL.Query()[topicParam]
+		for _, pattern := range patterns {
+			if !events.ValidPattern(pattern) {
+				writeError(w, apierr.InvalidArgument("invalid topic pattern: %s", pattern).WithDetail(topicParam, pattern))
+				return
+			}
+		}
+
+		var lastID uint64
+		if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+			lastID, _ = strconv.ParseUint(raw, 10, 64)
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, apierr.Internal("streaming is not supported"))
+			return
+		}
+
+		sub := bus.Subscribe(patterns, lastID)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		request.Log(ctx).WithField("topics", patterns).Debug("event subscription started")
+
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.streams:
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case evt, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if !visibleEvent(ctx, &evt) {
+					continue
+				}
+				data, err := json.Marshal(evt)
+				if err != nil {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evt.ID, evt.Topic, data); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+// visibleEvent returns whether the caller of ctx may see the event. The data of the
+// events holds the callers, arguments and results of the actions of a module, so they
+// are only streamed to the callers allowed to call one of its actions. Streams served
+// without authentication see every event.
+func visibleEvent(ctx context.Context, evt *events.Event) bool {
+	scopes := request.Scopes(ctx)
+	if scopes == nil {
+		return true
+	}
+
+	tok := auth.Token{Scopes: scopes}
+	return tok.AllowsModule(evt.Module())
+}
@@ -0,0 +1,117 @@
+package interfaces
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/events"
+)
+
+func TestServer_Events(t *testing.T) {
+	bus := events.NewBus()
+	srv := NewServer(auth.NewAuthenticator([]auth.Token{
+		{Name: "statusbar", Token: "secret", Scopes: []string{"events/subscribe", "task/*"}},
+		{Name: "other", Token: "other", Scopes: []string{"keyval/*"}},
+	}))
+	srv.Handle(events.Path, srv.EventsHandler(bus))
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	get := func(token, query string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+events.Path+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := get("other", ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected tokens without the events scope to be rejected, got %d", resp.StatusCode)
+	}
+
+	if resp := get("secret", "?topic=["); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected invalid patterns to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp := get("secret", "?topic=task/*")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// The subscription is made before the response headers are sent, so events
+	// published from now on are streamed. Other topics are filtered out.
+	bus.Publish(context.Background(), events.TopicKeySet, nil)
+	bus.Publish(context.Background(), events.TopicTaskStarted, map[string]interface{}{"task": "web"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var received []string
+	for line := range lines {
+		if line == "" {
+			break
+		}
+		received = append(received, line)
+	}
+
+	if len(received) != 3 || received[1] != "event: task/started" || !strings.Contains(received[2], `"task":"web"`) {
+		t.Errorf("unexpected event: %v", received)
+	}
+
+	// Closing the streams ends the stream.
+	srv.CloseStreams()
+	for range lines {
+	}
+}
+
+func TestServer_EventsScopes(t *testing.T) {
+	bus := events.NewBus()
+	srv := NewServer(auth.NewAuthenticator([]auth.Token{
+		{Name: "tasks", Token: "secret", Scopes: []string{"events/subscribe", "task/status"}},
+	}))
+	srv.Handle(events.Path, srv.EventsHandler(bus))
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	defer srv.CloseStreams()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+events.Path, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// Only the events of the modules the token may call are streamed, whatever their topic.
+	bus.Publish(context.Background(), events.TopicKeySet, map[string]interface{}{"key": "password"})
+	bus.Publish(context.Background(), events.TopicActionInvoked, map[string]interface{}{"module": "keyval", "action": "set"})
+	bus.Publish(context.Background(), events.TopicActionInvoked, map[string]interface{}{"module": "task", "action": "start"})
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		if !strings.Contains(line, `"module":"task"`) {
+			t.Errorf("unexpected event: %s", line)
+		}
+		break
+	}
+}
@@ -0,0 +1,293 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/interfaces/orcpb"
+	"github.com/dalloriam/orc/logs"
+	"github.com/dalloriam/orc/request"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const grpcContentType = "application/grpc"
+
+// gRPC status codes of the API error codes.
+var grpcCodes = map[apierr.Code]codes.Code{
+	apierr.CodeCanceled:         codes.Canceled,
+	apierr.CodeInvalidArgument:  codes.InvalidArgument,
+	apierr.CodeDeadlineExceeded: codes.DeadlineExceeded,
+	apierr.CodeNotFound:         codes.NotFound,
+	apierr.CodePermissionDenied: codes.PermissionDenied,
+	apierr.CodeConflict:         codes.FailedPrecondition,
+	apierr.CodeMethodNotAllowed: codes.Unimplemented,
+	apierr.CodeInternal:         codes.Internal,
+	apierr.CodeUnavailable:      codes.Unavailable,
+	apierr.CodeUnauthenticated:  codes.Unauthenticated,
+}
+
+// EnableGRPC serves the gRPC service of orcpb along with the HTTP API, on the same listeners.
+// The actions of the registered modules are invoked by name, and the Events and Logs
+// methods stream the events of the bus and the entries of the broadcaster.
+// It must be called before the server starts serving.
+func (s *Server) EnableGRPC(bus *events.Bus, broadcaster *logs.Broadcaster) {
+	s.grpc = grpc.NewServer()
+	orcpb.RegisterOrcServer(s.grpc, &grpcService{server: s, bus: bus, logs: broadcaster})
+}
+
+// ListenerHandler returns the handler serving the connections of a listener. The gRPC
+// clients require HTTP/2, which tls listeners negotiate. When gRPC is enabled, the tcp
+// and unix listeners also accept HTTP/2 without TLS (h2c), otherwise they only serve HTTP/1.
+func (s *Server) ListenerHandler(l Listener) http.Handler {
+	if s.grpc == nil || l.Scheme == SchemeTLS {
+		return s
+	}
+	return h2c.NewHandler(s, &http2.Server{})
+}
+
+// isGRPC returns whether the request is a gRPC call, which require HTTP/2.
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), grpcContentType)
+}
+
+// grpcError converts an error to the gRPC status of its API error code.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	apiErr := apierr.From(err)
+	code, ok := grpcCodes[apiErr.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, apiErr.Message)
+}
+
+// grpcService implements the gRPC service. Calls are served through the middlewares of
+// the server, so their context carries the request ID and records the caller.
+type grpcService struct {
+	server *Server
+	bus    *events.Bus
+	logs   *logs.Broadcaster
+}
+
+// authorization returns the authorization metadata of a call, e.g. "Bearer <token>".
+func (g *grpcService) authorization(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// remoteAddr returns the address of the caller.
+func (g *grpcService) remoteAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// authorize ensures the caller is allowed to call the action, like the HTTP handlers.
+func (g *grpcService) authorize(ctx context.Context, moduleName, actionName string) (context.Context, error) {
+	ctx, err := authorize(ctx, g.server.authenticator, g.authorization(ctx), moduleName, actionName)
+	if err != nil {
+		request.Log(ctx).Warnf("rejected grpc call from %s: %s", g.remoteAddr(ctx), err.Error())
+		return ctx, grpcError(authError(err))
+	}
+	return ctx, nil
+}
+
+func (g *grpcService) Invoke(ctx context.Context, req *orcpb.InvokeRequest) (*orcpb.InvokeResponse, error) {
+	// The caller is authorized first, so that the actions can't be probed anonymously.
+	ctx, err := g.authorize(ctx, req.Module, req.Action)
+	if err != nil {
+		return nil, err
+	}
+
+	action, ok := g.server.routes.action(req.Module, req.Action)
+	if !ok {
+		return nil, grpcError(apierr.NotFound("unknown action: %s/%s", req.Module, req.Action))
+	}
+
+	var args map[string]interface{}
+	if len(req.ArgsJson) > 0 {
+		if err := json.Unmarshal(req.ArgsJson, &args); err != nil {
+			return nil, grpcError(apierr.InvalidArgument("invalid JSON arguments: %s", err.Error()))
+		}
+	}
+	if req.Async {
+		ctx = request.WithAsync(ctx)
+	}
+	ctx = request.WithRemoteAddr(ctx, g.remoteAddr(ctx))
+
+	start := time.Now()
+	out, err := action.Execute(ctx, action.Name, args)
+	if err != nil {
+		apiErr := apierr.From(err)
+		observeAction(action.Module, action.Name, start, apiErr.Code)
+		return nil, grpcError(apiErr)
+	}
+	observeAction(action.Module, action.Name, start, "")
+
+	return &orcpb.InvokeResponse{Output: out}, nil
+}
+
+func (g *grpcService) ListActions(ctx context.Context, req *orcpb.ListActionsRequest) (*orcpb.ListActionsResponse, error) {
+	// Any valid token may list the actions, but only sees those it is allowed to call.
+	var tok *auth.Token
+	if authenticator := g.server.authenticator; authenticator != nil && authenticator.Enabled() {
+		var err error
+		if tok, err = authenticator.Authenticate(g.authorization(ctx)); err != nil {
+			return nil, grpcError(authError(err))
+		}
+		setCaller(ctx, tok.Name)
+	}
+
+	resp := &orcpb.ListActionsResponse{}
+	for _, action := range g.server.routes.listActions() {
+		if (req.Module != "" && action.Module != req.Module) || (tok != nil && !tok.Allows(action.Module, action.Name)) {
+			continue
+		}
+
+		schemaData, err := json.Marshal(action.Schema)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+
+		resp.Actions = append(resp.Actions, &orcpb.Action{
+			Module:     action.Module,
+			Name:       action.Name,
+			ReadOnly:   action.Schema.ReadOnly,
+			SchemaJson: schemaData,
+		})
+	}
+
+	return resp, nil
+}
+
+func (g *grpcService) Events(req *orcpb.EventsRequest, stream orcpb.Orc_EventsServer) error {
+	if g.bus == nil {
+		return grpcError(apierr.MethodNotAllowed("event streaming is not enabled"))
+	}
+
+	ctx, err := g.authorize(stream.Context(), events.ModuleName, events.SubscribeAction)
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range req.Topics {
+		if !events.ValidPattern(pattern) {
+			return grpcError(apierr.InvalidArgument("invalid topic pattern: %s", pattern))
+		}
+	}
+
+	sub := g.bus.Subscribe(req.Topics, req.LastEventId)
+	defer sub.Close()
+
+	// The headers are sent once subscribed, so that clients know events published from then on are streamed.
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-g.server.streams:
+			return nil
+		case evt, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			if !visibleEvent(ctx, &evt) {
+				continue
+			}
+
+			data, err := json.Marshal(evt.Data)
+			if err != nil {
+				continue
+			}
+
+			err = stream.Send(&orcpb.Event{
+				Id:        evt.ID,
+				Topic:     evt.Topic,
+				Time:      evt.Time.Format(time.RFC3339Nano),
+				RequestId: evt.RequestID,
+				DataJson:  data,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (g *grpcService) Logs(req *orcpb.LogsRequest, stream orcpb.Orc_LogsServer) error {
+	if g.logs == nil {
+		return grpcError(apierr.MethodNotAllowed("log streaming is not enabled"))
+	}
+
+	ctx, err := g.authorize(stream.Context(), logs.ModuleName, logs.StreamAction)
+	if err != nil {
+		return err
+	}
+
+	level := logrus.InfoLevel
+	if req.Level != "" {
+		if level, err = logrus.ParseLevel(req.Level); err != nil {
+			return grpcError(apierr.InvalidArgument("invalid log level: %s", req.Level))
+		}
+	}
+
+	sub := g.logs.Subscribe(level)
+	defer sub.Close()
+
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-g.server.streams:
+			return nil
+		case entry, ok := <-sub.Entries():
+			if !ok {
+				return nil
+			}
+
+			var fields []byte
+			if len(entry.Fields) > 0 {
+				if fields, err = json.Marshal(entry.Fields); err != nil {
+					continue
+				}
+			}
+
+			err = stream.Send(&orcpb.LogEntry{
+				Time:       entry.Time.Format(time.RFC3339Nano),
+				Level:      entry.Level,
+				Message:    entry.Message,
+				FieldsJson: fields,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/interfaces/orcpb"
+	"github.com/dalloriam/orc/logs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startGRPC serves the server over cleartext HTTP/2, as on a tcp listener, and
+// connects the grpc-go client generated from orc.proto to it.
+func startGRPC(t *testing.T, srv *Server) (*httptest.Server, orcpb.OrcClient, func()) {
+	ts := httptest.NewServer(srv.ListenerHandler(Listener{Scheme: SchemeTCP}))
+
+	conn, err := grpc.Dial(ts.Listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+
+	return ts, orcpb.NewOrcClient(conn), func() {
+		conn.Close()
+		ts.Close()
+	}
+}
+
+// withToken returns a context authenticating the calls with the token.
+func withToken(token string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	return ctx, cancel
+}
+
+func TestServer_GRPC(t *testing.T) {
+	srv := NewServer(auth.NewAuthenticator([]auth.Token{
+		{Name: "admin", Token: "secret", Scopes: []string{"*"}},
+		{Name: "reader", Token: "reader", Scopes: []string{"mod/get"}},
+	}))
+	srv.EnableGRPC(events.NewBus(), logs.NewBroadcaster())
+	srv.RegisterModule("mod", []Action{
+		okAction("mod", "get"),
+		{
+			Module: "mod",
+			Name:   "echo",
+			Execute: func(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+				return json.Marshal(data)
+			},
+		},
+	})
+
+	_, client, stop := startGRPC(t, srv)
+	defer stop()
+
+	invoke := func(token, action, args string) (*orcpb.InvokeResponse, codes.Code) {
+		ctx, cancel := withToken(token)
+		defer cancel()
+
+		resp, err := client.Invoke(ctx, &orcpb.InvokeRequest{Module: "mod", Action: action, ArgsJson: []byte(args)})
+		return resp, status.Code(err)
+	}
+
+	resp, code := invoke("secret", "echo", `{"greeting": "hello"}`)
+	if code != codes.OK || string(resp.Output) != `{"greeting":"hello"}` {
+		t.Errorf("unexpected invoke response: %v %v", resp, code)
+	}
+
+	type tCase struct {
+		name   string
+		token  string
+		action string
+		args   string
+		code   codes.Code
+	}
+
+	cases := []tCase{
+		{"unknown action", "secret", "missing", "", codes.NotFound},
+		{"forbidden action", "reader", "echo", "", codes.PermissionDenied},
+		{"anonymous call", "", "echo", "", codes.Unauthenticated},
+		{"anonymous call to unknown action", "", "missing", "", codes.Unauthenticated},
+		{"invalid arguments", "secret", "echo", "{", codes.InvalidArgument},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, code := invoke(tc.token, tc.action, tc.args); code != tc.code {
+				t.Errorf("expected code %v, got %v", tc.code, code)
+			}
+		})
+	}
+
+	// Tokens only list the actions they are allowed to call.
+	ctx, cancel := withToken("reader")
+	defer cancel()
+
+	list, err := client.ListActions(ctx, &orcpb.ListActionsRequest{})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if len(list.Actions) != 1 || list.Actions[0].Module != "mod" || list.Actions[0].Name != "get" {
+		t.Errorf("unexpected actions: %v", list.Actions)
+	}
+}
+
+func TestServer_GRPCDisabled(t *testing.T) {
+	srv := NewServer(nil)
+	srv.RegisterModule("mod", []Action{okAction("mod", "get")})
+
+	_, client, stop := startGRPC(t, srv)
+	defer stop()
+
+	ctx, cancel := withToken("")
+	defer cancel()
+
+	// Without gRPC, the tcp listeners only serve HTTP/1.
+	if _, err := client.Invoke(ctx, &orcpb.InvokeRequest{Module: "mod", Action: "get"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected cleartext HTTP/2 to be refused, got %v", err)
+	}
+}
+
+func TestServer_GRPCEvents(t *testing.T) {
+	bus := events.NewBus()
+	srv := NewServer(nil)
+	srv.EnableGRPC(bus, logs.NewBroadcaster())
+
+	_, client, stop := startGRPC(t, srv)
+	defer stop()
+
+	ctx, cancel := withToken("")
+	defer cancel()
+
+	stream, err := client.Events(ctx, &orcpb.EventsRequest{Topics: []string{"task/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The headers are sent once subscribed, so events published from now on are streamed.
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+	bus.Publish(context.Background(), events.TopicKeySet, nil)
+	bus.Publish(context.Background(), events.TopicTaskStarted, map[string]interface{}{"task": "web"})
+
+	evt, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Topic != events.TopicTaskStarted || string(evt.DataJson) != `{"task":"web"}` {
+		t.Errorf("unexpected event: %s %s", evt.Topic, evt.DataJson)
+	}
+
+	// Closing the streams ends the call.
+	srv.CloseStreams()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("expected stream to end, got %v", err)
+	}
+}
+
+func TestServer_GRPCEventsScopes(t *testing.T) {
+	bus := events.NewBus()
+	srv := NewServer(auth.NewAuthenticator([]auth.Token{
+		{Name: "tasks", Token: "secret", Scopes: []string{"events/subscribe", "task/status"}},
+	}))
+	srv.EnableGRPC(bus, logs.NewBroadcaster())
+
+	_, client, stop := startGRPC(t, srv)
+	defer stop()
+	defer srv.CloseStreams()
+
+	ctx, cancel := withToken("secret")
+	defer cancel()
+
+	stream, err := client.Events(ctx, &orcpb.EventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the events of the modules the token may call are streamed.
+	bus.Publish(context.Background(), events.TopicJobFinished, map[string]interface{}{"module": "keyval", "action": "get"})
+	bus.Publish(context.Background(), events.TopicTaskStarted, map[string]interface{}{"task": "web"})
+
+	evt, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Topic != events.TopicTaskStarted {
+		t.Errorf("unexpected event: %s %s", evt.Topic, evt.DataJson)
+	}
+}
@@ -1,60 +1,235 @@
 package interfaces
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strconv"
 
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/schema"
 	"github.com/sirupsen/logrus"
 )
 
-func writeError(w http.ResponseWriter, errMsg string) {
-	outBytes, _ := json.Marshal(map[string]string{"error": errMsg})
-	w.Write(outBytes)
-	w.WriteHeader(http.StatusInternalServerError)
+// asyncParam is the query parameter requesting an action to run as a background job.
+// It is never mapped to an action argument.
+const asyncParam = "async"
+
+// maxBodySize is the maximum size of the request bodies holding the arguments of the actions.
+const maxBodySize = 1 << 20
+
+// ExecuteFunc runs an action.
+type ExecuteFunc func(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error)
+
+// Action describes an action to serve.
+type Action struct {
+	Module string
+	Name   string
+
+	// Schema declares the arguments of the action, used to convert query and path
+	// parameters, and whether the action is read-only.
+	Schema schema.Schema
+
+	// Routes are the resource-style aliases of the action.
+	Routes []schema.Route
+
+	Execute ExecuteFunc
+}
+
+// argumentNames returns the sorted names of the arguments of an action.
+func argumentNames(data map[string]interface{}) []string {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apierr.Write(w, err)
+}
+
+func writeAuthError(w http.ResponseWriter, err error) {
+	apiErr := authError(err)
+	if apiErr.Code == apierr.CodeUnauthenticated {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="orc"`)
+	}
+	writeError(w, apiErr)
+}
+
+// authError classifies the errors of the authenticator.
+func authError(err error) *apierr.Error {
+	if err == auth.ErrForbidden {
+		return apierr.New(apierr.CodePermissionDenied, "%s", err.Error())
+	}
+	return apierr.New(apierr.CodeUnauthenticated, "%s", err.Error())
+}
+
+// authorize ensures the caller is allowed to call the action, unless the authenticator
+// is nil or has no tokens, and records the caller and its scopes in the context.
+// The HTTP and gRPC handlers share it, so that they enforce the same scopes.
+func authorize(ctx context.Context, authenticator *auth.Authenticator, authorization, moduleName, actionName string) (context.Context, error) {
+	if authenticator == nil || !authenticator.Enabled() {
+		return ctx, nil
+	}
+
+	tok, err := authenticator.Authorize(authorization, moduleName, actionName)
+	if err != nil {
+		return ctx, err
+	}
+
+	request.Log(ctx).Debugf("request authorized with token: %s", tok.Name)
+	setCaller(ctx, tok.Name)
+	return request.WithScopes(request.WithCaller(ctx, tok.Name), tok.Scopes), nil
 }
 
-// HandleWithHTTP creates a HTTP handler for the action.
-func HandleWithHTTP(moduleName, actionName string, fn func(actionName string, data map[string]interface{}) ([]byte, error)) {
-	pattern := fmt.Sprintf("/%s/%s", moduleName, actionName)
+// endpoints returns the endpoints serving the action.
+// Actions are served on POST /{module}/{action}, and also on GET when they are
+// read-only, in which case the query parameters are mapped to the arguments.
+// Their resource-style routes are served as well. Other methods are rejected.
+func (s *Server) endpoints(endpoints []*endpoint, action Action) []*endpoint {
+	routes := []schema.Route{{Method: http.MethodPost, Path: action.Name}}
+	if action.Schema.ReadOnly {
+		routes = append(routes, schema.Route{Method: http.MethodGet, Path: action.Name})
+	}
+
+	for _, route := range append(routes, action.Routes...) {
+		handler := instrument(action.Module, action.Name, actionHandler(s.authenticator, action, route))
+		endpoints = addHandler(endpoints, route.Method, route.Path, handler)
+	}
+
+	return endpoints
+}
+
+// actionHandler serves an action on a route. Requests must bear a token allowed
+// to call the action, unless the authenticator is nil or has no tokens.
+//
+// The action runs with the context of the HTTP request, and is therefore
+// canceled when the client disconnects, unless it runs as a job (?async=1).
+func actionHandler(authenticator *auth.Authenticator, action Action, route schema.Route) http.HandlerFunc {
+	pattern := fmt.Sprintf("%s /%s/%s", route.Method, action.Module, route.Path)
 	ctxLogger := logrus.WithFields(logrus.Fields{
-		"module": moduleName,
-		"action": actionName,
+		"module": action.Module,
+		"action": action.Name,
 	})
-	http.HandleFunc(pattern,
-		func(w http.ResponseWriter, r *http.Request) {
-			ctxLogger.Infof("received http request: %s", pattern)
-			w.Header().Add("Content-Type", "application/json")
-			// Read the data from the request
-			body, err := ioutil.ReadAll(r.Body)
-			if err != nil {
-				writeError(w, err.Error())
-				return
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Requests served through AccessLog already carry an ID.
+		ctx := r.Context()
+		requestID := request.ID(ctx)
+		if requestID == "" {
+			requestID = request.IDFromHeader(r.Header)
+			ctx = request.WithID(ctx, requestID)
+		}
+		if async, _ := strconv.ParseBool(r.URL.Query().Get(asyncParam)); async {
+			ctx = request.WithAsync(ctx)
+		}
+		ctx = request.WithRemoteAddr(ctx, r.RemoteAddr)
+		ctxLogger := ctxLogger.WithField("request_id", requestID)
+
+		ctxLogger.Debugf("received http request: %s", pattern)
+		w.Header().Add("Content-Type", "application/json")
+
+		ctx, err := authorize(ctx, authenticator, r.Header.Get("Authorization"), action.Module, action.Name)
+		if err != nil {
+			ctxLogger.Warnf("rejected http request from %s: %s", r.RemoteAddr, err.Error())
+			writeAuthError(w, err)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		parsed, err := arguments(r, action.Schema, route)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if len(parsed) > 0 {
+			// Only the argument names are logged, as their values may hold secrets.
+			ctxLogger.Debugf("action arguments: %v", argumentNames(parsed))
+		}
+
+		// Fetch the response from the module & return the output.
+		outBytes, err := action.Execute(ctx, action.Name, parsed)
+		if err != nil {
+			apiErr := apierr.From(err)
+			if len(outBytes) > 0 {
+				apiErr = apiErr.WithDetail("output", string(outBytes))
 			}
+			if apiErr.Code == apierr.CodeInternal {
+				ctxLogger.Errorf("action failed: %s", apiErr.Message)
+			}
+			writeError(w, apiErr)
+			return
+		}
+
+		w.Write(outBytes)
+	}
+}
+
+// arguments collects the arguments of the action from the request body, the
+// query parameters of GET and DELETE requests, and the path parameters, in
+// increasing order of precedence. Requests without arguments return a nil map,
+// and bodies larger than maxBodySize are rejected.
+func arguments(r *http.Request, actionSchema schema.Schema, route schema.Route) (map[string]interface{}, error) {
+	var args map[string]interface{}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, apierr.InvalidArgument("error reading request body: %s", err.Error())
+	}
 
-			// Decode the request into some dict.
-			var parsed map[string]interface{}
-			if len(body) > 0 {
-				if err := json.Unmarshal(body, &parsed); err != nil {
-					writeError(w, err.Error())
-					return
-				}
-				outBytes, _ := json.Marshal(parsed)
-				ctxLogger.Debugf("action payload: %s", string(outBytes))
+	if len(body) > 0 {
+		if route.Body != "" {
+			var val interface{}
+			if err := json.Unmarshal(body, &val); err != nil {
+				return nil, apierr.InvalidArgument("invalid JSON payload: %s", err.Error())
 			}
+			args = map[string]interface{}{route.Body: val}
+		} else if err := json.Unmarshal(body, &args); err != nil {
+			return nil, apierr.InvalidArgument("invalid JSON payload: %s", err.Error())
+		}
+	}
 
-			// Fetch the response from the module & return the output.
-			outBytes, err := fn(actionName, parsed)
-			if err != nil {
-				if len(outBytes) > 0 {
-					writeError(w, string(outBytes))
-				} else {
-					writeError(w, err.Error())
-				}
-				return
+	params := make(map[string]string)
+	if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+		for name, values := range r.URL.Query() {
+			if name != asyncParam && len(values) > 0 {
+				params[name] = values[0]
 			}
+		}
+	}
+	for name, val := range pathParams(r) {
+		params[name] = val
+	}
+
+	problems := make(map[string]string)
+	for name, raw := range params {
+		field, ok := actionSchema.Field(name)
+		if !ok {
+			field = schema.Field{Name: name, Type: schema.TypeAny}
+		}
+
+		val, err := field.Parse(raw)
+		if err != nil {
+			problems[name] = err.Error()
+			continue
+		}
+
+		if args == nil {
+			args = make(map[string]interface{})
+		}
+		args[name] = val
+	}
+
+	if len(problems) > 0 {
+		return nil, apierr.InvalidArgument("invalid parameters").WithDetail("fields", problems)
+	}
 
-			w.Write(outBytes)
-		})
+	return args, nil
 }
@@ -0,0 +1,198 @@
+package interfaces
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Supported listener schemes.
+const (
+	SchemeTCP  = "tcp"
+	SchemeTLS  = "tls"
+	SchemeUnix = "unix"
+
+	// DefaultSocketMode are the permissions applied to unix sockets when none are specified.
+	DefaultSocketMode os.FileMode = 0600
+)
+
+// TLSConfig holds the certificates used by a TLS listener.
+// When ClientCAFile is set, clients must present a certificate signed by that CA (mTLS).
+type TLSConfig struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file,omitempty"`
+}
+
+func (c *TLSConfig) load() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("tls listener requires both a certificate and a key file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		// HTTP/2 is negotiated for gRPC clients.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if c.ClientCAFile != "" {
+		caData, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no valid certificate found in %s", c.ClientCAFile)
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// Listener describes an endpoint on which the server accepts connections.
+type Listener struct {
+	Scheme  string
+	Address string
+
+	// SocketMode holds the permissions of the socket file (unix listeners only).
+	SocketMode os.FileMode
+
+	TLS *TLSConfig
+}
+
+// ParseListener parses a listener specification.
+// Supported forms are tcp://host:port, tls://host:port and unix:///path/to/socket.
+func ParseListener(spec string) (Listener, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return Listener{}, err
+	}
+
+	switch u.Scheme {
+	case SchemeTCP, SchemeTLS:
+		if u.Host == "" {
+			return Listener{}, fmt.Errorf("missing address in listener: %s", spec)
+		}
+		return Listener{Scheme: u.Scheme, Address: u.Host}, nil
+	case SchemeUnix:
+		if u.Path == "" {
+			return Listener{}, fmt.Errorf("missing socket path in listener: %s", spec)
+		}
+		return Listener{Scheme: u.Scheme, Address: u.Path}, nil
+	}
+
+	return Listener{}, fmt.Errorf("unsupported listener scheme: %s", u.Scheme)
+}
+
+// String returns the listener specification.
+func (l Listener) String() string {
+	return fmt.Sprintf("%s://%s", l.Scheme, l.Address)
+}
+
+// Exposed reports whether the listener accepts connections from other machines
+// without authenticating them: tcp and tls listeners bound to a non-loopback
+// address, except tls listeners requiring client certificates.
+func (l Listener) Exposed() bool {
+	switch l.Scheme {
+	case SchemeUnix:
+		return false
+	case SchemeTLS:
+		if l.TLS != nil && l.TLS.ClientCAFile != "" {
+			return false
+		}
+	}
+
+	host, _, err := net.SplitHostPort(l.Address)
+	if err != nil {
+		return true
+	}
+	if host == "localhost" {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// Listen opens the listener.
+func (l Listener) Listen() (net.Listener, error) {
+	switch l.Scheme {
+	case SchemeTCP:
+		return net.Listen("tcp", l.Address)
+	case SchemeTLS:
+		if l.TLS == nil {
+			return nil, fmt.Errorf("no tls configuration for listener: %s", l)
+		}
+		cfg, err := l.TLS.load()
+		if err != nil {
+			return nil, err
+		}
+		return tls.Listen("tcp", l.Address, cfg)
+	case SchemeUnix:
+		return l.listenUnix()
+	}
+
+	return nil, fmt.Errorf("unsupported listener scheme: %s", l.Scheme)
+}
+
+func (l Listener) listenUnix() (net.Listener, error) {
+	if _, err := os.Stat(l.Address); err == nil {
+		// Only remove the socket if nobody is listening on it anymore.
+		if conn, err := net.DialTimeout("unix", l.Address, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket already in use: %s", l.Address)
+		}
+		if err := os.Remove(l.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	mode := l.SocketMode
+	if mode == 0 {
+		mode = DefaultSocketMode
+	}
+
+	// The socket is created with the permissions of the mode at most, so that other
+	// users can't connect to it before it is chmod'd.
+	var ln net.Listener
+	err := withFileMode(mode, func() (err error) {
+		ln, err = net.Listen("unix", l.Address)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(l.Address, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// ParseSocketMode parses an octal permission string (e.g. "0660").
+func ParseSocketMode(mode string) (os.FileMode, error) {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid socket mode: %s", mode)
+	}
+	return os.FileMode(parsed), nil
+}
@@ -0,0 +1,77 @@
+package interfaces
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/metrics"
+)
+
+var (
+	actionRequests = metrics.NewCounterVec("orc_action_requests_total", "Number of action requests received.", "module", "action")
+	actionErrors   = metrics.NewCounterVec("orc_action_errors_total", "Number of action requests that failed, by error code.", "module", "action", "code")
+	actionDuration = metrics.NewHistogramVec("orc_action_duration_seconds", "Time taken to handle action requests.", nil, "module", "action")
+)
+
+func init() {
+	metrics.MustRegister(actionRequests, actionErrors, actionDuration)
+}
+
+// statusRecorder records the status code and the size of the response written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	written int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.written += n
+	return n, err
+}
+
+// Flush sends the buffered data to the client, for streaming handlers.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// CloseNotify reports when the client goes away, the gRPC service requires it.
+func (r *statusRecorder) CloseNotify() <-chan bool {
+	if notifier, ok := r.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// observeAction records a request of an action, which failed with the code unless it is empty.
+func observeAction(moduleName, actionName string, start time.Time, code apierr.Code) {
+	actionRequests.WithLabelValues(moduleName, actionName).Inc()
+	actionDuration.Observe(time.Since(start).Seconds(), moduleName, actionName)
+	if code != "" {
+		actionErrors.WithLabelValues(moduleName, actionName, string(code)).Inc()
+	}
+}
+
+// instrument records the count, errors and latency of the requests served by the action handler.
+func instrument(moduleName, actionName string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		handler(rec, r)
+
+		var code apierr.Code
+		if rec.status != http.StatusOK {
+			code = apierr.FromHTTPStatus(rec.status)
+		}
+		observeAction(moduleName, actionName, start, code)
+	}
+}
@@ -0,0 +1,4 @@
+// Package orcpb holds the gRPC service of ORC and its messages, generated from orc.proto.
+package orcpb
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. orc.proto
@@ -0,0 +1,786 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: orc.proto
+
+package orcpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type InvokeRequest struct {
+	Module string `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	Action string `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	// JSON object of the action arguments.
+	ArgsJson []byte `protobuf:"bytes,3,opt,name=args_json,json=argsJson,proto3" json:"args_json,omitempty"`
+	// Runs the action as a background job, the output then describes the job.
+	Async                bool     `protobuf:"varint,4,opt,name=async,proto3" json:"async,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InvokeRequest) Reset()         { *m = InvokeRequest{} }
+func (m *InvokeRequest) String() string { return proto.CompactTextString(m) }
+func (*InvokeRequest) ProtoMessage()    {}
+func (*InvokeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{0}
+}
+
+func (m *InvokeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvokeRequest.Unmarshal(m, b)
+}
+func (m *InvokeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvokeRequest.Marshal(b, m, deterministic)
+}
+func (m *InvokeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvokeRequest.Merge(m, src)
+}
+func (m *InvokeRequest) XXX_Size() int {
+	return xxx_messageInfo_InvokeRequest.Size(m)
+}
+func (m *InvokeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvokeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvokeRequest proto.InternalMessageInfo
+
+func (m *InvokeRequest) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
+}
+
+func (m *InvokeRequest) GetAction() string {
+	if m != nil {
+		return m.Action
+	}
+	return ""
+}
+
+func (m *InvokeRequest) GetArgsJson() []byte {
+	if m != nil {
+		return m.ArgsJson
+	}
+	return nil
+}
+
+func (m *InvokeRequest) GetAsync() bool {
+	if m != nil {
+		return m.Async
+	}
+	return false
+}
+
+type InvokeResponse struct {
+	// Output of the action, usually JSON.
+	Output               []byte   `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InvokeResponse) Reset()         { *m = InvokeResponse{} }
+func (m *InvokeResponse) String() string { return proto.CompactTextString(m) }
+func (*InvokeResponse) ProtoMessage()    {}
+func (*InvokeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{1}
+}
+
+func (m *InvokeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvokeResponse.Unmarshal(m, b)
+}
+func (m *InvokeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvokeResponse.Marshal(b, m, deterministic)
+}
+func (m *InvokeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvokeResponse.Merge(m, src)
+}
+func (m *InvokeResponse) XXX_Size() int {
+	return xxx_messageInfo_InvokeResponse.Size(m)
+}
+func (m *InvokeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvokeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvokeResponse proto.InternalMessageInfo
+
+func (m *InvokeResponse) GetOutput() []byte {
+	if m != nil {
+		return m.Output
+	}
+	return nil
+}
+
+type ListActionsRequest struct {
+	// Only lists the actions of this module when set.
+	Module               string   `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListActionsRequest) Reset()         { *m = ListActionsRequest{} }
+func (m *ListActionsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListActionsRequest) ProtoMessage()    {}
+func (*ListActionsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{2}
+}
+
+func (m *ListActionsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListActionsRequest.Unmarshal(m, b)
+}
+func (m *ListActionsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListActionsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListActionsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListActionsRequest.Merge(m, src)
+}
+func (m *ListActionsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListActionsRequest.Size(m)
+}
+func (m *ListActionsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListActionsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListActionsRequest proto.InternalMessageInfo
+
+func (m *ListActionsRequest) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
+}
+
+type ListActionsResponse struct {
+	Actions              []*Action `protobuf:"bytes,1,rep,name=actions,proto3" json:"actions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *ListActionsResponse) Reset()         { *m = ListActionsResponse{} }
+func (m *ListActionsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListActionsResponse) ProtoMessage()    {}
+func (*ListActionsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{3}
+}
+
+func (m *ListActionsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListActionsResponse.Unmarshal(m, b)
+}
+func (m *ListActionsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListActionsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListActionsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListActionsResponse.Merge(m, src)
+}
+func (m *ListActionsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListActionsResponse.Size(m)
+}
+func (m *ListActionsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListActionsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListActionsResponse proto.InternalMessageInfo
+
+func (m *ListActionsResponse) GetActions() []*Action {
+	if m != nil {
+		return m.Actions
+	}
+	return nil
+}
+
+type Action struct {
+	Module   string `protobuf:"bytes,1,opt,name=module,proto3" json:"module,omitempty"`
+	Name     string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	ReadOnly bool   `protobuf:"varint,3,opt,name=read_only,json=readOnly,proto3" json:"read_only,omitempty"`
+	// JSON schema of the arguments, as returned by management/actions_available.
+	SchemaJson           []byte   `protobuf:"bytes,4,opt,name=schema_json,json=schemaJson,proto3" json:"schema_json,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Action) Reset()         { *m = Action{} }
+func (m *Action) String() string { return proto.CompactTextString(m) }
+func (*Action) ProtoMessage()    {}
+func (*Action) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{4}
+}
+
+func (m *Action) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Action.Unmarshal(m, b)
+}
+func (m *Action) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Action.Marshal(b, m, deterministic)
+}
+func (m *Action) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Action.Merge(m, src)
+}
+func (m *Action) XXX_Size() int {
+	return xxx_messageInfo_Action.Size(m)
+}
+func (m *Action) XXX_DiscardUnknown() {
+	xxx_messageInfo_Action.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Action proto.InternalMessageInfo
+
+func (m *Action) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
+}
+
+func (m *Action) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Action) GetReadOnly() bool {
+	if m != nil {
+		return m.ReadOnly
+	}
+	return false
+}
+
+func (m *Action) GetSchemaJson() []byte {
+	if m != nil {
+		return m.SchemaJson
+	}
+	return nil
+}
+
+type EventsRequest struct {
+	// Topic patterns of the events, e.g. "task/*". All events are streamed when empty.
+	Topics []string `protobuf:"bytes,1,rep,name=topics,proto3" json:"topics,omitempty"`
+	// Replays the retained events published after this ID, to resume a stream.
+	LastEventId          uint64   `protobuf:"varint,2,opt,name=last_event_id,json=lastEventId,proto3" json:"last_event_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EventsRequest) Reset()         { *m = EventsRequest{} }
+func (m *EventsRequest) String() string { return proto.CompactTextString(m) }
+func (*EventsRequest) ProtoMessage()    {}
+func (*EventsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{5}
+}
+
+func (m *EventsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EventsRequest.Unmarshal(m, b)
+}
+func (m *EventsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EventsRequest.Marshal(b, m, deterministic)
+}
+func (m *EventsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EventsRequest.Merge(m, src)
+}
+func (m *EventsRequest) XXX_Size() int {
+	return xxx_messageInfo_EventsRequest.Size(m)
+}
+func (m *EventsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_EventsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EventsRequest proto.InternalMessageInfo
+
+func (m *EventsRequest) GetTopics() []string {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+func (m *EventsRequest) GetLastEventId() uint64 {
+	if m != nil {
+		return m.LastEventId
+	}
+	return 0
+}
+
+type Event struct {
+	Id    uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Topic string `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	// RFC 3339 timestamp.
+	Time      string `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	RequestId string `protobuf:"bytes,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// JSON object of the event data.
+	DataJson             []byte   `protobuf:"bytes,5,opt,name=data_json,json=dataJson,proto3" json:"data_json,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+func (*Event) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{6}
+}
+
+func (m *Event) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Event.Unmarshal(m, b)
+}
+func (m *Event) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Event.Marshal(b, m, deterministic)
+}
+func (m *Event) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Event.Merge(m, src)
+}
+func (m *Event) XXX_Size() int {
+	return xxx_messageInfo_Event.Size(m)
+}
+func (m *Event) XXX_DiscardUnknown() {
+	xxx_messageInfo_Event.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Event proto.InternalMessageInfo
+
+func (m *Event) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Event) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *Event) GetTime() string {
+	if m != nil {
+		return m.Time
+	}
+	return ""
+}
+
+func (m *Event) GetRequestId() string {
+	if m != nil {
+		return m.RequestId
+	}
+	return ""
+}
+
+func (m *Event) GetDataJson() []byte {
+	if m != nil {
+		return m.DataJson
+	}
+	return nil
+}
+
+type LogsRequest struct {
+	// Minimum level of the streamed entries, "info" by default.
+	Level                string   `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LogsRequest) Reset()         { *m = LogsRequest{} }
+func (m *LogsRequest) String() string { return proto.CompactTextString(m) }
+func (*LogsRequest) ProtoMessage()    {}
+func (*LogsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{7}
+}
+
+func (m *LogsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LogsRequest.Unmarshal(m, b)
+}
+func (m *LogsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LogsRequest.Marshal(b, m, deterministic)
+}
+func (m *LogsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogsRequest.Merge(m, src)
+}
+func (m *LogsRequest) XXX_Size() int {
+	return xxx_messageInfo_LogsRequest.Size(m)
+}
+func (m *LogsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogsRequest proto.InternalMessageInfo
+
+func (m *LogsRequest) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+type LogEntry struct {
+	// RFC 3339 timestamp.
+	Time    string `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Level   string `protobuf:"bytes,2,opt,name=level,proto3" json:"level,omitempty"`
+	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// JSON object of the structured fields of the entry.
+	FieldsJson           []byte   `protobuf:"bytes,4,opt,name=fields_json,json=fieldsJson,proto3" json:"fields_json,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LogEntry) Reset()         { *m = LogEntry{} }
+func (m *LogEntry) String() string { return proto.CompactTextString(m) }
+func (*LogEntry) ProtoMessage()    {}
+func (*LogEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_eda176c14a575e62, []int{8}
+}
+
+func (m *LogEntry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LogEntry.Unmarshal(m, b)
+}
+func (m *LogEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LogEntry.Marshal(b, m, deterministic)
+}
+func (m *LogEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogEntry.Merge(m, src)
+}
+func (m *LogEntry) XXX_Size() int {
+	return xxx_messageInfo_LogEntry.Size(m)
+}
+func (m *LogEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogEntry proto.InternalMessageInfo
+
+func (m *LogEntry) GetTime() string {
+	if m != nil {
+		return m.Time
+	}
+	return ""
+}
+
+func (m *LogEntry) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+func (m *LogEntry) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *LogEntry) GetFieldsJson() []byte {
+	if m != nil {
+		return m.FieldsJson
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*InvokeRequest)(nil), "orc.v1.InvokeRequest")
+	proto.RegisterType((*InvokeResponse)(nil), "orc.v1.InvokeResponse")
+	proto.RegisterType((*ListActionsRequest)(nil), "orc.v1.ListActionsRequest")
+	proto.RegisterType((*ListActionsResponse)(nil), "orc.v1.ListActionsResponse")
+	proto.RegisterType((*Action)(nil), "orc.v1.Action")
+	proto.RegisterType((*EventsRequest)(nil), "orc.v1.EventsRequest")
+	proto.RegisterType((*Event)(nil), "orc.v1.Event")
+	proto.RegisterType((*LogsRequest)(nil), "orc.v1.LogsRequest")
+	proto.RegisterType((*LogEntry)(nil), "orc.v1.LogEntry")
+}
+
+func init() { proto.RegisterFile("orc.proto", fileDescriptor_eda176c14a575e62) }
+
+var fileDescriptor_eda176c14a575e62 = []byte{
+	// 531 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x54, 0xcd, 0x8a, 0xdb, 0x3c,
+	0x14, 0xc5, 0x89, 0xe3, 0x89, 0x6f, 0x26, 0xe1, 0x43, 0x33, 0xdf, 0x60, 0x32, 0x94, 0x06, 0x77,
+	0xe3, 0xd2, 0x92, 0xa4, 0xd3, 0x45, 0x97, 0xa5, 0x85, 0x29, 0xa4, 0x0d, 0x0c, 0x68, 0xd9, 0x4d,
+	0x50, 0x2c, 0x4d, 0x46, 0xad, 0x2d, 0xa5, 0x92, 0x12, 0xc8, 0xa2, 0x0f, 0xdc, 0xb7, 0x28, 0xfa,
+	0x71, 0x26, 0xee, 0x0f, 0xdd, 0xe9, 0x1c, 0xc9, 0xf7, 0x9e, 0x73, 0xee, 0xc5, 0x90, 0x4a, 0x55,
+	0x4e, 0xb7, 0x4a, 0x1a, 0x89, 0x12, 0x7b, 0xdc, 0xbf, 0xca, 0x15, 0x0c, 0x17, 0x62, 0x2f, 0xbf,
+	0x32, 0xcc, 0xbe, 0xed, 0x98, 0x36, 0xe8, 0x0a, 0x92, 0x5a, 0xd2, 0x5d, 0xc5, 0xb2, 0x68, 0x12,
+	0x15, 0x29, 0x0e, 0xc8, 0xf2, 0xa4, 0x34, 0x5c, 0x8a, 0xac, 0xe3, 0x79, 0x8f, 0xd0, 0x35, 0xa4,
+	0x44, 0x6d, 0xf4, 0xea, 0x8b, 0x96, 0x22, 0xeb, 0x4e, 0xa2, 0xe2, 0x1c, 0xf7, 0x2d, 0xf1, 0x51,
+	0x4b, 0x81, 0x2e, 0xa1, 0x47, 0xf4, 0x41, 0x94, 0x59, 0x3c, 0x89, 0x8a, 0x3e, 0xf6, 0x20, 0x2f,
+	0x60, 0xd4, 0xf4, 0xd4, 0x5b, 0x29, 0xb4, 0x2b, 0x2e, 0x77, 0x66, 0xbb, 0x33, 0xae, 0xe9, 0x39,
+	0x0e, 0x28, 0x7f, 0x09, 0x68, 0xc9, 0xb5, 0x79, 0xe7, 0x5a, 0xe9, 0x7f, 0x48, 0xcc, 0xdf, 0xc2,
+	0x45, 0xeb, 0x75, 0x28, 0x5e, 0xc0, 0x99, 0xd7, 0xaa, 0xb3, 0x68, 0xd2, 0x2d, 0x06, 0x37, 0xa3,
+	0xa9, 0x37, 0x3f, 0xf5, 0x2f, 0x71, 0x73, 0x9d, 0x2b, 0x48, 0x3c, 0xf5, 0xd7, 0x14, 0x10, 0xc4,
+	0x82, 0xd4, 0x2c, 0x64, 0xe0, 0xce, 0x36, 0x01, 0xc5, 0x08, 0x5d, 0x49, 0x51, 0x1d, 0x5c, 0x02,
+	0x7d, 0xdc, 0xb7, 0xc4, 0x9d, 0xa8, 0x0e, 0xe8, 0x29, 0x0c, 0x74, 0xf9, 0xc0, 0x6a, 0xe2, 0x03,
+	0x8a, 0x9d, 0x3d, 0xf0, 0x94, 0x8d, 0x28, 0xff, 0x04, 0xc3, 0xdb, 0x3d, 0x13, 0xe6, 0xd4, 0x9d,
+	0x91, 0x5b, 0x5e, 0x7a, 0xb5, 0x29, 0x0e, 0x08, 0xe5, 0x30, 0xac, 0x88, 0x36, 0x2b, 0x66, 0x5f,
+	0xaf, 0x38, 0x75, 0x1a, 0x62, 0x3c, 0xb0, 0xa4, 0xab, 0xb0, 0xa0, 0xf9, 0x77, 0xe8, 0xb9, 0x23,
+	0x1a, 0x41, 0x87, 0x53, 0xa7, 0x3d, 0xc6, 0x1d, 0x4e, 0xed, 0x20, 0x5c, 0x99, 0x20, 0xdc, 0x03,
+	0xeb, 0xc6, 0xf0, 0x9a, 0x39, 0xd1, 0x29, 0x76, 0x67, 0xf4, 0x04, 0x40, 0x79, 0x25, 0xb6, 0x47,
+	0xec, 0x6e, 0xd2, 0xc0, 0x2c, 0xa8, 0x35, 0x4b, 0x89, 0x09, 0x6e, 0x7a, 0x7e, 0xdc, 0x96, 0x70,
+	0x5e, 0x9e, 0xc1, 0x60, 0x29, 0x37, 0x47, 0x27, 0x97, 0xd0, 0xab, 0xd8, 0x9e, 0x55, 0x21, 0x43,
+	0x0f, 0x72, 0x09, 0xfd, 0xa5, 0xdc, 0xdc, 0x0a, 0xa3, 0x0e, 0x47, 0x01, 0xd1, 0x89, 0x80, 0xe3,
+	0x57, 0x9d, 0x93, 0xaf, 0x50, 0x06, 0x67, 0x35, 0xd3, 0x9a, 0x6c, 0x1a, 0xb5, 0x0d, 0xb4, 0x09,
+	0xdf, 0x73, 0x56, 0x51, 0xdd, 0x4a, 0xd8, 0x53, 0x56, 0xd5, 0xcd, 0x8f, 0x08, 0xba, 0x77, 0xaa,
+	0x44, 0x6f, 0x20, 0xf1, 0x6b, 0x87, 0xfe, 0x6f, 0x16, 0xa0, 0xb5, 0xfa, 0xe3, 0xab, 0x5f, 0xe9,
+	0xb0, 0x40, 0x1f, 0x60, 0x70, 0xb2, 0x57, 0x68, 0xdc, 0x3c, 0xfb, 0x7d, 0x35, 0xc7, 0xd7, 0x7f,
+	0xbc, 0x0b, 0x75, 0xe6, 0x90, 0xf8, 0x51, 0x3f, 0x0a, 0x68, 0x8d, 0x7e, 0x3c, 0x6c, 0xd1, 0xf3,
+	0x08, 0xcd, 0x20, 0xb6, 0x81, 0xa2, 0x8b, 0x63, 0xd9, 0xc7, 0x78, 0xc7, 0xff, 0x9d, 0x90, 0x2e,
+	0xce, 0x79, 0xf4, 0xfe, 0xc5, 0xe7, 0xe7, 0x1b, 0x6e, 0x1e, 0x76, 0xeb, 0x69, 0x29, 0xeb, 0x19,
+	0x25, 0x55, 0x25, 0x15, 0x27, 0xf5, 0x4c, 0xaa, 0x72, 0xc6, 0x85, 0x61, 0xea, 0x9e, 0x94, 0x4c,
+	0x5b, 0xb8, 0x5d, 0xaf, 0x13, 0xf7, 0x2b, 0x78, 0xfd, 0x73, 0x00, 0x37, 0x09, 0x93, 0x61, 0x17,
+	0x04, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// OrcClient is the client API for Orc service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type OrcClient interface {
+	// Invoke runs a module action, e.g. task/start.
+	Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error)
+	// ListActions returns the actions the caller is allowed to invoke.
+	ListActions(ctx context.Context, in *ListActionsRequest, opts ...grpc.CallOption) (*ListActionsResponse, error)
+	// Events streams the server events, requiring the events/subscribe scope. Only
+	// the events of the modules the caller is allowed to call are streamed.
+	Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (Orc_EventsClient, error)
+	// Logs streams the server logs, requiring the logs/stream scope.
+	Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (Orc_LogsClient, error)
+}
+
+type orcClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewOrcClient(cc *grpc.ClientConn) OrcClient {
+	return &orcClient{cc}
+}
+
+func (c *orcClient) Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error) {
+	out := new(InvokeResponse)
+	err := c.cc.Invoke(ctx, "/orc.v1.Orc/Invoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orcClient) ListActions(ctx context.Context, in *ListActionsRequest, opts ...grpc.CallOption) (*ListActionsResponse, error) {
+	out := new(ListActionsResponse)
+	err := c.cc.Invoke(ctx, "/orc.v1.Orc/ListActions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orcClient) Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (Orc_EventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Orc_serviceDesc.Streams[0], "/orc.v1.Orc/Events", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orcEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Orc_EventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type orcEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *orcEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orcClient) Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (Orc_LogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Orc_serviceDesc.Streams[1], "/orc.v1.Orc/Logs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orcLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Orc_LogsClient interface {
+	Recv() (*LogEntry, error)
+	grpc.ClientStream
+}
+
+type orcLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *orcLogsClient) Recv() (*LogEntry, error) {
+	m := new(LogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// OrcServer is the server API for Orc service.
+type OrcServer interface {
+	// Invoke runs a module action, e.g. task/start.
+	Invoke(context.Context, *InvokeRequest) (*InvokeResponse, error)
+	// ListActions returns the actions the caller is allowed to invoke.
+	ListActions(context.Context, *ListActionsRequest) (*ListActionsResponse, error)
+	// Events streams the server events, requiring the events/subscribe scope. Only
+	// the events of the modules the caller is allowed to call are streamed.
+	Events(*EventsRequest, Orc_EventsServer) error
+	// Logs streams the server logs, requiring the logs/stream scope.
+	Logs(*LogsRequest, Orc_LogsServer) error
+}
+
+func RegisterOrcServer(s *grpc.Server, srv OrcServer) {
+	s.RegisterService(&_Orc_serviceDesc, srv)
+}
+
+func _Orc_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrcServer).Invoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/orc.v1.Orc/Invoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrcServer).Invoke(ctx, req.(*InvokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orc_ListActions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListActionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrcServer).ListActions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/orc.v1.Orc/ListActions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrcServer).ListActions(ctx, req.(*ListActionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orc_Events_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrcServer).Events(m, &orcEventsServer{stream})
+}
+
+type Orc_EventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type orcEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *orcEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Orc_Logs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrcServer).Logs(m, &orcLogsServer{stream})
+}
+
+type Orc_LogsServer interface {
+	Send(*LogEntry) error
+	grpc.ServerStream
+}
+
+type orcLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *orcLogsServer) Send(m *LogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Orc_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "orc.v1.Orc",
+	HandlerType: (*OrcServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Invoke",
+			Handler:    _Orc_Invoke_Handler,
+		},
+		{
+			MethodName: "ListActions",
+			Handler:    _Orc_ListActions_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Events",
+			Handler:       _Orc_Events_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Logs",
+			Handler:       _Orc_Logs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "orc.proto",
+}
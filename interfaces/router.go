@@ -0,0 +1,231 @@
+package interfaces
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dalloriam/orc/apierr"
+)
+
+// endpoint is a path served by a module, with one handler per HTTP method.
+type endpoint struct {
+	// segments of the path relative to the module. Segments in braces are parameters.
+	segments []string
+	handlers map[string]http.HandlerFunc
+}
+
+// match returns the parameters of the path if it matches the endpoint.
+func (e *endpoint) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(e.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range e.segments {
+		if name, ok := paramName(seg); ok {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[name] = segments[i]
+		} else if seg != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// literals returns the number of segments that are not parameters.
+// Endpoints with more literal segments take precedence, so that actions win over resource routes.
+func (e *endpoint) literals() int {
+	n := 0
+	for _, seg := range e.segments {
+		if _, ok := paramName(seg); !ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (e *endpoint) allowed() string {
+	methods := make([]string, 0, len(e.handlers))
+	for method := range e.handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func paramName(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+func splitPath(p string) []string {
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
+
+// router dispatches the requests of the modules to their endpoints.
+// It also keeps the actions of the modules, for the transports that invoke them by name.
+type router struct {
+	mu      sync.RWMutex
+	modules map[string][]*endpoint
+	actions map[string][]Action
+}
+
+func newRouter() *router {
+	return &router{
+		modules: make(map[string][]*endpoint),
+		actions: make(map[string][]Action),
+	}
+}
+
+// addHandler adds the handler of a method on a path to the endpoints.
+// Adding an existing method and path again replaces its handler.
+func addHandler(endpoints []*endpoint, method, path string, handler http.HandlerFunc) []*endpoint {
+	segments := splitPath(path)
+	for _, e := range endpoints {
+		if strings.Join(e.segments, "/") == strings.Join(segments, "/") {
+			e.handlers[method] = handler
+			return endpoints
+		}
+	}
+
+	return append(endpoints, &endpoint{
+		segments: segments,
+		handlers: map[string]http.HandlerFunc{method: handler},
+	})
+}
+
+// setModule replaces all the endpoints and actions of a module at once, so that
+// requests never observe a partially registered module.
+func (rt *router) setModule(moduleName string, endpoints []*endpoint, actions []Action) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.modules[moduleName] = endpoints
+	rt.actions[moduleName] = actions
+}
+
+// removeModule removes the endpoints and actions of a module.
+func (rt *router) removeModule(moduleName string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	delete(rt.modules, moduleName)
+	delete(rt.actions, moduleName)
+}
+
+// action returns an action of a module.
+func (rt *router) action(moduleName, actionName string) (Action, bool) {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	for _, action := range rt.actions[moduleName] {
+		if action.Name == actionName {
+			return action, true
+		}
+	}
+	return Action{}, false
+}
+
+// listActions returns the actions of all the modules, sorted by module and name.
+func (rt *router) listActions() []Action {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	var actions []Action
+	for _, moduleActions := range rt.actions {
+		actions = append(actions, moduleActions...)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].Module != actions[j].Module {
+			return actions[i].Module < actions[j].Module
+		}
+		return actions[i].Name < actions[j].Name
+	})
+	return actions
+}
+
+// moduleNames returns the sorted names of the routed modules.
+func (rt *router) moduleNames() []string {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	names := make([]string, 0, len(rt.modules))
+	for name := range rt.modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// routes returns whether the router serves the path, which it does for all the
+// paths under the prefix of a registered module.
+func (rt *router) routes(path string) (string, bool) {
+	moduleName := splitPath(path)[0]
+
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	_, ok := rt.modules[moduleName]
+	return moduleName, ok && strings.HasPrefix(path, "/"+moduleName+"/")
+}
+
+// dispatch serves a request to the endpoint of the module matching its path and method.
+func (rt *router) dispatch(moduleName string, w http.ResponseWriter, r *http.Request) {
+	// Split the escaped path, so that parameters may contain encoded slashes.
+	rawSegments := splitPath(strings.TrimPrefix(r.URL.EscapedPath(), "/"+moduleName+"/"))
+	segments := make([]string, len(rawSegments))
+	for i, raw := range rawSegments {
+		seg, err := url.PathUnescape(raw)
+		if err != nil {
+			writeError(w, apierr.InvalidArgument("invalid path: %s", r.URL.Path))
+			return
+		}
+		segments[i] = seg
+	}
+
+	rt.mu.RLock()
+	var (
+		best       *endpoint
+		bestParams map[string]string
+		handler    http.HandlerFunc
+		allowed    string
+	)
+	for _, e := range rt.modules[moduleName] {
+		if params, ok := e.match(segments); ok && (best == nil || e.literals() > best.literals()) {
+			best, bestParams = e, params
+		}
+	}
+	if best != nil {
+		handler = best.handlers[r.Method]
+		allowed = best.allowed()
+	}
+	rt.mu.RUnlock()
+
+	if best == nil {
+		writeError(w, apierr.NotFound("unknown route: %s", r.URL.Path))
+		return
+	}
+
+	if handler == nil {
+		w.Header().Set("Allow", allowed)
+		writeError(w, apierr.MethodNotAllowed("method %s not allowed on %s, use %s", r.Method, r.URL.Path, allowed))
+		return
+	}
+
+	handler(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, bestParams)))
+}
+
+type paramsKey struct{}
+
+// pathParams returns the parameters extracted from the path of the request.
+func pathParams(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params
+}
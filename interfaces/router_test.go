@@ -0,0 +1,95 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dalloriam/orc/schema"
+)
+
+func TestServer_Routes(t *testing.T) {
+	var gotAction string
+	var gotArgs map[string]interface{}
+	execute := func(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+		gotAction, gotArgs = actionName, data
+		return []byte(`{"message": "OK"}`), nil
+	}
+
+	key := schema.Field{Name: "key", Type: schema.TypeString, Required: true}
+	srv := NewServer(nil)
+	srv.RegisterModule("routetest", []Action{
+		{
+			Module:  "routetest",
+			Name:    "get",
+			Schema:  schema.NewReadOnly(key, schema.Field{Name: "limit", Type: schema.TypeInteger}),
+			Routes:  []schema.Route{{Method: http.MethodGet, Path: "{key}"}},
+			Execute: execute,
+		},
+		{
+			Module:  "routetest",
+			Name:    "set",
+			Schema:  schema.New(key, schema.Field{Name: "val", Type: schema.TypeAny, Required: true}),
+			Routes:  []schema.Route{{Method: http.MethodPut, Path: "{key}", Body: "val"}},
+			Execute: execute,
+		},
+	})
+
+	type testCase struct {
+		name   string
+		method string
+		path   string
+		body   string
+
+		wantStatus int
+		wantAction string
+		wantArgs   map[string]interface{}
+		wantAllow  string
+	}
+
+	cases := []testCase{
+		{"post action", http.MethodPost, "/routetest/set", `{"key": "a", "val": 1}`, http.StatusOK, "set", map[string]interface{}{"key": "a", "val": float64(1)}, ""},
+		{"get read-only action", http.MethodGet, "/routetest/get?key=a&limit=10&async=0", "", http.StatusOK, "get", map[string]interface{}{"key": "a", "limit": int64(10)}, ""},
+		{"get read-only action without arguments", http.MethodGet, "/routetest/get", "", http.StatusOK, "get", nil, ""},
+		{"invalid query parameter", http.MethodGet, "/routetest/get?key=a&limit=many", "", http.StatusBadRequest, "", nil, ""},
+		{"get mutating action", http.MethodGet, "/routetest/set?key=a", "", http.StatusMethodNotAllowed, "", nil, "POST"},
+		{"delete action", http.MethodDelete, "/routetest/get", "", http.StatusMethodNotAllowed, "", nil, "GET, POST"},
+		{"get resource", http.MethodGet, "/routetest/some%2Fkey", "", http.StatusOK, "get", map[string]interface{}{"key": "some/key"}, ""},
+		{"put resource", http.MethodPut, "/routetest/a", `{"nested": true}`, http.StatusOK, "set", map[string]interface{}{"key": "a", "val": map[string]interface{}{"nested": true}}, ""},
+		{"delete resource", http.MethodDelete, "/routetest/a", "", http.StatusMethodNotAllowed, "", nil, "GET, PUT"},
+		{"unknown route", http.MethodGet, "/routetest/a/b", "", http.StatusNotFound, "", nil, ""},
+		{"body too large", http.MethodPost, "/routetest/set", `{"key": "` + strings.Repeat("a", maxBodySize) + `"}`, http.StatusBadRequest, "", nil, ""},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			gotAction, gotArgs = "", nil
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(tCase.method, tCase.path, strings.NewReader(tCase.body)))
+
+			if rec.Code != tCase.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tCase.wantStatus, rec.Code, rec.Body.String())
+			}
+
+			if gotAction != tCase.wantAction || !reflect.DeepEqual(gotArgs, tCase.wantArgs) {
+				t.Errorf("expected %s%v, got %s%v", tCase.wantAction, tCase.wantArgs, gotAction, gotArgs)
+			}
+
+			if allow := rec.Header().Get("Allow"); allow != tCase.wantAllow {
+				t.Errorf("expected Allow: %s, got %s", tCase.wantAllow, allow)
+			}
+
+			if rec.Code != http.StatusOK {
+				var envelope map[string]interface{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil || envelope["error"] == nil {
+					t.Errorf("expected an error envelope, got %s", rec.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package interfaces
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/request"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// CORS settings of the responses to allowed origins.
+const (
+	corsAllowMethods  = "GET, POST, PUT, DELETE, OPTIONS"
+	corsAllowHeaders  = "Authorization, Content-Type, " + request.IDHeader
+	corsExposeHeaders = request.IDHeader
+	corsMaxAge        = "600"
+)
+
+// Server serves the actions of the modules over HTTP. It owns its routes, so that
+// modules can be registered and removed at runtime, and several servers can coexist.
+//
+// Requests go through the access log, panic recovery and CORS middlewares, then
+// to the module routes, which authenticate the caller, or to the other handlers.
+// gRPC calls are served by the gRPC service once enabled, see EnableGRPC.
+type Server struct {
+	authenticator *auth.Authenticator
+
+	routes  *router
+	mux     *http.ServeMux
+	handler http.Handler
+
+	corsMu      sync.RWMutex
+	corsOrigins []string
+
+	// streams is closed to end the event and log streams.
+	streams      chan struct{}
+	streamsClose sync.Once
+
+	// grpc serves the gRPC calls once enabled.
+	grpc *grpc.Server
+}
+
+// NewServer returns a server authenticating the callers of actions with the authenticator.
+// Authentication is disabled if the authenticator is nil or has no tokens.
+func NewServer(authenticator *auth.Authenticator) *Server {
+	s := &Server{
+		authenticator: authenticator,
+		routes:        newRouter(),
+		mux:           http.NewServeMux(),
+		streams:       make(chan struct{}),
+	}
+	s.handler = AccessLog(recoverer(s.cors(http.HandlerFunc(s.route))))
+
+	return s
+}
+
+// ServeHTTP serves a request through the middleware chain.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Handle registers a handler outside of the module routes, e.g. the health check.
+// Module routes take precedence over these handlers.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers a handler function outside of the module routes.
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// RegisterModule serves the actions of a module under /{module}/, replacing all
+// the routes previously registered for it, so that reloaded modules lose the
+// actions they no longer define.
+func (s *Server) RegisterModule(moduleName string, actions []Action) {
+	var endpoints []*endpoint
+	for _, action := range actions {
+		endpoints = s.endpoints(endpoints, action)
+	}
+
+	s.routes.setModule(moduleName, endpoints, actions)
+}
+
+// RemoveModule stops serving the actions of a module.
+func (s *Server) RemoveModule(moduleName string) {
+	s.routes.removeModule(moduleName)
+}
+
+// Modules returns the sorted names of the registered modules.
+func (s *Server) Modules() []string {
+	return s.routes.moduleNames()
+}
+
+// CloseStreams ends the event and log streams, which would otherwise hold up the graceful
+// shutdown of the HTTP servers.
+func (s *Server) CloseStreams() {
+	s.streamsClose.Do(func() {
+		close(s.streams)
+	})
+}
+
+// SetCORSOrigins sets the origins allowed to call the API from a browser.
+// The "*" origin allows any origin. CORS is disabled when no origins are set.
+func (s *Server) SetCORSOrigins(origins []string) {
+	s.corsMu.Lock()
+	defer s.corsMu.Unlock()
+	s.corsOrigins = append([]string{}, origins...)
+}
+
+func (s *Server) corsAllowed(origin string) bool {
+	s.corsMu.RLock()
+	defer s.corsMu.RUnlock()
+
+	for _, allowed := range s.corsOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	if s.grpc != nil && isGRPC(r) {
+		s.grpc.ServeHTTP(w, r)
+		return
+	}
+
+	if moduleName, ok := s.routes.routes(r.URL.Path); ok {
+		s.routes.dispatch(moduleName, w, r)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// cors answers the preflight requests of the allowed origins, and allows them to
+// read the responses. Requests from other origins are served without CORS headers,
+// which browsers enforce.
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !s.corsAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoverer converts the panics of handlers into internal errors, logging their stack trace.
+func recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// Raised on purpose to abort the response.
+				panic(v)
+			}
+
+			request.Log(r.Context()).WithFields(logrus.Fields{
+				"path":  r.URL.Path,
+				"panic": fmt.Sprint(v),
+				"stack": string(debug.Stack()),
+			}).Error("recovered from panic serving request")
+			writeError(w, apierr.Internal("internal error"))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
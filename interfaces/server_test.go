@@ -0,0 +1,116 @@
+package interfaces
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func okAction(moduleName, actionName string) Action {
+	return Action{
+		Module: moduleName,
+		Name:   actionName,
+		Execute: func(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+			return []byte(`{"message": "OK"}`), nil
+		},
+	}
+}
+
+func serve(srv *Server, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_RegisterModule(t *testing.T) {
+	srv := NewServer(nil)
+	srv.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	srv.RegisterModule("mod", []Action{okAction("mod", "first"), okAction("mod", "second")})
+
+	if rec := serve(srv, http.MethodPost, "/mod/second", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected registered action to be served, got %d", rec.Code)
+	}
+
+	// Registering the module again drops the actions it no longer defines.
+	srv.RegisterModule("mod", []Action{okAction("mod", "first")})
+
+	if rec := serve(srv, http.MethodPost, "/mod/second", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected dropped action to be unknown, got %d", rec.Code)
+	}
+
+	if rec := serve(srv, http.MethodPost, "/mod/first", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected kept action to be served, got %d", rec.Code)
+	}
+
+	srv.RemoveModule("mod")
+
+	if rec := serve(srv, http.MethodPost, "/mod/first", nil); rec.Code != http.StatusTeapot {
+		t.Errorf("expected removed module to fall through to the other handlers, got %d", rec.Code)
+	}
+
+	if modules := srv.Modules(); len(modules) != 0 {
+		t.Errorf("expected no modules, got %v", modules)
+	}
+}
+
+func TestServer_Independent(t *testing.T) {
+	first, second := NewServer(nil), NewServer(nil)
+	first.RegisterModule("mod", []Action{okAction("mod", "action")})
+
+	if rec := serve(second, http.MethodPost, "/mod/action", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected servers not to share routes, got %d", rec.Code)
+	}
+}
+
+func TestServer_Recovery(t *testing.T) {
+	srv := NewServer(nil)
+	srv.RegisterModule("mod", []Action{{
+		Module: "mod",
+		Name:   "panic",
+		Execute: func(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+			var m map[string]string
+			m["nil"] = "map"
+			return nil, nil
+		},
+	}})
+
+	if rec := serve(srv, http.MethodPost, "/mod/panic", nil); rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected panic to be converted to an internal error, got %d", rec.Code)
+	}
+}
+
+func TestServer_CORS(t *testing.T) {
+	srv := NewServer(nil)
+	srv.RegisterModule("mod", []Action{okAction("mod", "action")})
+
+	preflight := http.Header{
+		"Origin":                        {"http://dashboard.local"},
+		"Access-Control-Request-Method": {"POST"},
+	}
+
+	rec := serve(srv, http.MethodOptions, "/mod/action", preflight)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected CORS to be disabled by default")
+	}
+
+	srv.SetCORSOrigins([]string{"http://dashboard.local"})
+
+	rec = serve(srv, http.MethodOptions, "/mod/action", preflight)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "http://dashboard.local" {
+		t.Errorf("expected preflight to be allowed, got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = serve(srv, http.MethodPost, "/mod/action", http.Header{"Origin": {"http://evil.local"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected other origins not to be allowed, got %v", rec.Header())
+	}
+}
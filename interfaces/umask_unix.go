@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package interfaces
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// umaskMu serializes the changes of the umask, which is shared by the whole process.
+var umaskMu sync.Mutex
+
+// withFileMode runs fn with a umask creating the files with at most the permissions of mode,
+// so that they are never accessible beyond them, not even until they are chmod'd.
+func withFileMode(mode os.FileMode, fn func() error) error {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+
+	old := syscall.Umask(int(0777 &^ mode.Perm()))
+	defer syscall.Umask(old)
+
+	return fn()
+}
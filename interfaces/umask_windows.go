@@ -0,0 +1,8 @@
+package interfaces
+
+import "os"
+
+// withFileMode runs fn, windows has no umask.
+func withFileMode(mode os.FileMode, fn func() error) error {
+	return fn()
+}
@@ -0,0 +1,395 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/schema"
+	"github.com/mitchellh/mapstructure"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ModuleName is the name under which the job module is registered.
+	ModuleName = "job"
+
+	actionStatus = "status"
+	actionResult = "result"
+	actionCancel = "cancel"
+	actionList   = "list"
+)
+
+// Status is the state of a job.
+type Status string
+
+// Job states.
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Finished returns whether the job is done running.
+func (s Status) Finished() bool {
+	return s != StatusRunning
+}
+
+// Job is an action running in the background.
+type Job struct {
+	ID        string `json:"id"`
+	Module    string `json:"module"`
+	Action    string `json:"action"`
+	Status    Status `json:"status"`
+	Caller    string `json:"caller"`
+	RequestID string `json:"request_id,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	Error *apierr.Error `json:"error,omitempty"`
+
+	result []byte
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Done returns a channel closed when the job finishes.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// RunFunc runs the action of a job. It recovers the panics of the action, which
+// must then fail with an internal error.
+type RunFunc func(ctx context.Context) ([]byte, error)
+
+type idPayload struct {
+	ID string `mapstructure:"id"`
+}
+
+type listPayload struct {
+	Status Status `mapstructure:"status"`
+}
+
+// Manager runs jobs and keeps track of their results.
+// Finished jobs are forgotten once the retention period expires.
+type Manager struct {
+	mu        sync.RWMutex
+	jobs      map[string]*Job
+	retention time.Duration
+
+	// Events receives a job/finished event for every job.
+	Events *events.Bus
+
+	running   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewManager returns a job manager retaining finished jobs for the retention period.
+func NewManager(retention time.Duration) *Manager {
+	return &Manager{
+		jobs:      make(map[string]*Job),
+		retention: retention,
+	}
+}
+
+// SetRetention changes the retention period of finished jobs.
+func (m *Manager) SetRetention(retention time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retention = retention
+}
+
+// Stateful returns true, jobs only exist in the memory of the manager.
+func (m *Manager) Stateful() bool { return true }
+
+// Name returns the name of the job module.
+func (m *Manager) Name() string { return ModuleName }
+
+// Actions returns the actions supported by the module.
+func (m *Manager) Actions() []string {
+	return []string{actionStatus, actionResult, actionCancel, actionList}
+}
+
+// ActionSchema returns the arguments of a job action.
+func (m *Manager) ActionSchema(actionName string) schema.Schema {
+	id := schema.Field{Name: "id", Type: schema.TypeString, Required: true, Description: "ID of the job"}
+
+	switch actionName {
+	case actionList:
+		return schema.NewReadOnly(schema.Field{
+			Name:        "status",
+			Type:        schema.TypeString,
+			Enum:        []interface{}{string(StatusRunning), string(StatusSucceeded), string(StatusFailed), string(StatusCanceled)},
+			Description: "Only list the jobs in this state",
+		})
+	case actionCancel:
+		return schema.New(id)
+	}
+	return schema.NewReadOnly(id)
+}
+
+// ActionRoutes exposes the jobs as resources: GET and DELETE /job/{id}, and GET /job/{id}/result.
+func (m *Manager) ActionRoutes(actionName string) []schema.Route {
+	switch actionName {
+	case actionStatus:
+		return []schema.Route{{Method: http.MethodGet, Path: "{id}"}}
+	case actionResult:
+		return []schema.Route{{Method: http.MethodGet, Path: "{id}/result"}}
+	case actionCancel:
+		return []schema.Route{{Method: http.MethodDelete, Path: "{id}"}}
+	}
+	return nil
+}
+
+// Submit starts running the action in the background and returns the new job.
+// The job keeps the request ID and caller of ctx, but is not canceled along with it.
+func (m *Manager) Submit(ctx context.Context, moduleName, actionName string, run RunFunc) *Job {
+	jobCtx, cancel := context.WithCancel(request.Detach(ctx))
+
+	j := &Job{
+		ID:        request.NewID(),
+		Module:    moduleName,
+		Action:    actionName,
+		Status:    StatusRunning,
+		Caller:    request.Caller(ctx),
+		RequestID: request.ID(ctx),
+		CreatedAt: time.Now().UTC(),
+
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.purge()
+	m.jobs[j.ID] = j
+	m.mu.Unlock()
+
+	ctxLog := request.Log(ctx).WithFields(logrus.Fields{
+		"module": ModuleName,
+		"job":    j.ID,
+	})
+	ctxLog.Infof("starting job: %s/%s", moduleName, actionName)
+
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		defer cancel()
+
+		out, err := run(jobCtx)
+		m.finish(j, out, err, jobCtx.Err() == context.Canceled)
+
+		snapshot := m.Snapshot(j)
+		ctxLog.Infof("job %s", snapshot.Status)
+
+		data := map[string]interface{}{
+			"id":     snapshot.ID,
+			"module": snapshot.Module,
+			"action": snapshot.Action,
+			"status": string(snapshot.Status),
+		}
+		if snapshot.Error != nil {
+			data["error"] = snapshot.Error.Message
+		}
+		m.Events.Publish(jobCtx, events.TopicJobFinished, data)
+	}()
+
+	return j
+}
+
+func (m *Manager) finish(j *Job, out []byte, err error, canceled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	j.FinishedAt = &now
+
+	switch {
+	case canceled:
+		j.Status = StatusCanceled
+		j.Error = apierr.Canceled("job %s was canceled", j.ID)
+	case err != nil:
+		j.Status = StatusFailed
+		j.Error = apierr.From(err)
+	default:
+		j.Status = StatusSucceeded
+		j.result = out
+	}
+
+	close(j.done)
+}
+
+// purge forgets the finished jobs past their retention period.
+// The caller must hold the write lock.
+func (m *Manager) purge() {
+	cutoff := time.Now().Add(-m.retention)
+	for id, j := range m.jobs {
+		if j.Status.Finished() && j.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// Snapshot returns a copy of the job safe for reading.
+func (m *Manager) Snapshot(j *Job) Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return *j
+}
+
+// allowed returns whether the caller of ctx can access the job. Callers only see
+// their own jobs, unless granted the wildcard scope. Unauthenticated requests and
+// the actions invoked by ORC itself are not restricted.
+func allowed(ctx context.Context, j *Job) bool {
+	scopes := request.Scopes(ctx)
+	if scopes == nil || j.Caller == request.Caller(ctx) {
+		return true
+	}
+
+	for _, scope := range scopes {
+		if scope == auth.WildcardScope {
+			return true
+		}
+	}
+	return false
+}
+
+// getJob returns a job of the caller. The jobs of other callers are reported as
+// unknown, so that their IDs can't be probed.
+func (m *Manager) getJob(ctx context.Context, id string) (*Job, error) {
+	if id == "" {
+		return nil, apierr.InvalidArgument("job id not specified")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.purge()
+
+	j, ok := m.jobs[id]
+	if !ok || !allowed(ctx, j) {
+		return nil, apierr.NotFound("unknown job: %s", id).WithDetail("id", id)
+	}
+
+	return j, nil
+}
+
+func (m *Manager) status(ctx context.Context, id string) ([]byte, error) {
+	j, err := m.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := m.Snapshot(j)
+	return json.Marshal(&snapshot)
+}
+
+func (m *Manager) result(ctx context.Context, id string) ([]byte, error) {
+	j, err := m.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := m.Snapshot(j)
+	switch snapshot.Status {
+	case StatusRunning:
+		return nil, apierr.Conflict("job %s is still running", id).WithDetail("id", id)
+	case StatusSucceeded:
+		return snapshot.result, nil
+	default:
+		return nil, snapshot.Error
+	}
+}
+
+func (m *Manager) cancelJob(ctx context.Context, id string) ([]byte, error) {
+	j, err := m.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Snapshot(j).Status.Finished() {
+		return nil, apierr.Conflict("job %s already finished", id).WithDetail("id", id)
+	}
+
+	// Jobs ignoring the cancellation keep running, the caller doesn't wait past
+	// its own deadline for them.
+	j.cancel()
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	snapshot := m.Snapshot(j)
+	return json.Marshal(&snapshot)
+}
+
+func (m *Manager) list(ctx context.Context, status Status) ([]byte, error) {
+	m.mu.Lock()
+	m.purge()
+
+	jobs := []Job{}
+	for _, j := range m.jobs {
+		if (status == "" || j.Status == status) && allowed(ctx, j) {
+			jobs = append(jobs, *j)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].CreatedAt.Before(jobs[k].CreatedAt)
+	})
+
+	return json.Marshal(map[string]interface{}{"jobs": jobs})
+}
+
+// Execute executes a job action.
+func (m *Manager) Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+	if actionName == actionList {
+		var args listPayload
+		if err := mapstructure.Decode(data, &args); err != nil {
+			return nil, apierr.InvalidArgument("invalid arguments: %s", err.Error())
+		}
+		return m.list(ctx, args.Status)
+	}
+
+	var args idPayload
+	if err := mapstructure.Decode(data, &args); err != nil {
+		return nil, apierr.InvalidArgument("invalid arguments: %s", err.Error())
+	}
+
+	switch actionName {
+	case actionStatus:
+		return m.status(ctx, args.ID)
+	case actionResult:
+		return m.result(ctx, args.ID)
+	case actionCancel:
+		return m.cancelJob(ctx, args.ID)
+	}
+
+	return nil, apierr.NotFound("unknown action: %s", actionName)
+}
+
+// Close cancels the running jobs and waits for them to finish.
+func (m *Manager) Close() error {
+	m.closeOnce.Do(func() {
+		m.mu.RLock()
+		for _, j := range m.jobs {
+			if !j.Status.Finished() {
+				j.cancel()
+			}
+		}
+		m.mu.RUnlock()
+	})
+
+	m.running.Wait()
+	return nil
+}
@@ -0,0 +1,242 @@
+package job_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/job"
+	"github.com/dalloriam/orc/request"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logrus.SetLevel(logrus.PanicLevel)
+}
+
+func execute(m *job.Manager, action, id string) ([]byte, error) {
+	return m.Execute(context.Background(), action, map[string]interface{}{"id": id})
+}
+
+func TestManager_Submit(t *testing.T) {
+	type testCase struct {
+		name string
+		run  job.RunFunc
+
+		expectedStatus job.Status
+		expectedResult string
+		expectedCode   apierr.Code
+	}
+
+	cases := []testCase{
+		{
+			name:           "success",
+			run:            func(ctx context.Context) ([]byte, error) { return []byte(`{"message": "OK"}`), nil },
+			expectedStatus: job.StatusSucceeded,
+			expectedResult: `{"message": "OK"}`,
+		},
+		{
+			name:           "classified failure",
+			run:            func(ctx context.Context) ([]byte, error) { return nil, apierr.NotFound("unknown task: hello") },
+			expectedStatus: job.StatusFailed,
+			expectedCode:   apierr.CodeNotFound,
+		},
+		{
+			name:           "unclassified failure",
+			run:            func(ctx context.Context) ([]byte, error) { return nil, errors.New("something terrible happened") },
+			expectedStatus: job.StatusFailed,
+			expectedCode:   apierr.CodeInternal,
+		},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			m := job.NewManager(time.Hour)
+			ctx := request.WithCaller(request.WithID(context.Background(), "abcd"), "deploy")
+
+			j := m.Submit(ctx, "task", "start", tCase.run)
+			<-j.Done()
+
+			out, err := execute(m, "status", j.ID)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+
+			var status job.Job
+			if err := json.Unmarshal(out, &status); err != nil {
+				t.Fatalf("invalid status: %s", out)
+			}
+
+			if status.Status != tCase.expectedStatus {
+				t.Errorf("expected status %s, got %s", tCase.expectedStatus, status.Status)
+			}
+
+			if status.Caller != "deploy" || status.RequestID != "abcd" {
+				t.Errorf("job lost the request context: %s", out)
+			}
+
+			result, err := execute(m, "result", j.ID)
+			if tCase.expectedCode == "" {
+				if err != nil || string(result) != tCase.expectedResult {
+					t.Errorf("expected result %s, got %s (err=%v)", tCase.expectedResult, result, err)
+				}
+				return
+			}
+
+			if apiErr, ok := err.(*apierr.Error); !ok || apiErr.Code != tCase.expectedCode {
+				t.Errorf("expected error code %s, got err=%v", tCase.expectedCode, err)
+			}
+		})
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	m := job.NewManager(time.Hour)
+
+	j := m.Submit(context.Background(), "slow", "nap", func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	if _, err := execute(m, "result", j.ID); err == nil || err.(*apierr.Error).Code != apierr.CodeConflict {
+		t.Errorf("expected a conflict while the job is running, got err=%v", err)
+	}
+
+	if _, err := execute(m, "cancel", j.ID); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if m.Snapshot(j).Status != job.StatusCanceled {
+		t.Errorf("expected job to be canceled, got %s", m.Snapshot(j).Status)
+	}
+
+	if _, err := execute(m, "cancel", j.ID); err == nil || err.(*apierr.Error).Code != apierr.CodeConflict {
+		t.Errorf("expected a conflict when canceling a finished job, got err=%v", err)
+	}
+}
+
+func TestManager_Retention(t *testing.T) {
+	m := job.NewManager(0)
+
+	j := m.Submit(context.Background(), "keyval", "list", func(ctx context.Context) ([]byte, error) { return nil, nil })
+	<-j.Done()
+
+	_, err := execute(m, "status", j.ID)
+	if apiErr, ok := err.(*apierr.Error); !ok || apiErr.Code != apierr.CodeNotFound {
+		t.Errorf("expected expired job to be forgotten, got err=%v", err)
+	}
+}
+
+func TestManager_List(t *testing.T) {
+	m := job.NewManager(time.Hour)
+
+	done := m.Submit(context.Background(), "keyval", "list", func(ctx context.Context) ([]byte, error) { return nil, nil })
+	<-done.Done()
+
+	running := m.Submit(context.Background(), "slow", "nap", func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	defer m.Close()
+
+	out, err := m.Execute(context.Background(), "list", map[string]interface{}{"status": "running"})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	var resp struct {
+		Jobs []job.Job `json:"jobs"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid list: %s", out)
+	}
+
+	if len(resp.Jobs) != 1 || resp.Jobs[0].ID != running.ID {
+		t.Errorf("expected only the running job, got %s", out)
+	}
+}
+
+func TestManager_Caller(t *testing.T) {
+	type testCase struct {
+		name   string
+		caller string
+		scopes []string
+
+		expectedVisible bool
+	}
+
+	m := job.NewManager(time.Hour)
+	defer m.Close()
+
+	owner := request.WithScopes(request.WithCaller(context.Background(), "deploy"), []string{"task/*", "job/*"})
+	j := m.Submit(owner, "slow", "nap", func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	cases := []testCase{
+		{"owner", "deploy", []string{"job/status", "job/list"}, true},
+		{"other caller", "ci", []string{"job/*"}, false},
+		{"wildcard scope", "admin", []string{"*"}, true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			ctx := request.WithScopes(request.WithCaller(context.Background(), tCase.caller), tCase.scopes)
+
+			_, err := m.Execute(ctx, "status", map[string]interface{}{"id": j.ID})
+			if tCase.expectedVisible && err != nil {
+				t.Errorf("expected the job to be visible, got %s", err.Error())
+			}
+			if apiErr, ok := err.(*apierr.Error); !tCase.expectedVisible && (!ok || apiErr.Code != apierr.CodeNotFound) {
+				t.Errorf("expected the job to be hidden, got err=%v", err)
+			}
+
+			out, err := m.Execute(ctx, "list", nil)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			var resp struct {
+				Jobs []job.Job `json:"jobs"`
+			}
+			if err := json.Unmarshal(out, &resp); err != nil {
+				t.Fatalf("invalid list: %s", out)
+			}
+			if (len(resp.Jobs) == 1) != tCase.expectedVisible {
+				t.Errorf("expected visible: %v, got %s", tCase.expectedVisible, out)
+			}
+		})
+	}
+
+	other := request.WithScopes(request.WithCaller(context.Background(), "ci"), []string{"job/*"})
+	if _, err := m.Execute(other, "cancel", map[string]interface{}{"id": j.ID}); err == nil {
+		t.Errorf("expected other callers not to cancel the job")
+	}
+	if m.Snapshot(j).Status != job.StatusRunning {
+		t.Errorf("expected the job to keep running, got %s", m.Snapshot(j).Status)
+	}
+}
+
+func TestManager_CancelDeadline(t *testing.T) {
+	m := job.NewManager(time.Hour)
+
+	release := make(chan struct{})
+	j := m.Submit(context.Background(), "slow", "stubborn", func(ctx context.Context) ([]byte, error) {
+		<-release
+		return nil, nil
+	})
+	defer func() {
+		close(release)
+		m.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := m.Execute(ctx, "cancel", map[string]interface{}{"id": j.ID}); err != context.DeadlineExceeded {
+		t.Errorf("expected the cancellation to give up at the deadline, got err=%v", err)
+	}
+}
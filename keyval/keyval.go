@@ -1,9 +1,18 @@
 package keyval
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/metrics"
+	"github.com/dalloriam/orc/schema"
 )
 
 const (
@@ -15,9 +24,23 @@ const (
 	keyvalActionList  = "list"
 )
 
+var storeSize = metrics.NewGaugeVec("orc_keyval_keys", "Number of keys in the key/value store.")
+
+func init() {
+	metrics.MustRegister(storeSize)
+	storeSize.WithLabelValues().Set(0)
+}
+
 // Module manages a Key/Value store.
 type Module struct {
+	mu          sync.RWMutex
 	keyvalStore map[string]interface{}
+
+	stateFile string
+
+	// Events receives the keyval/set and keyval/cleared events. Values are left
+	// out of the events, subscribers read them with the get action.
+	Events *events.Bus
 }
 
 // NewModule initializes the key/value store.
@@ -25,6 +48,57 @@ func NewModule() *Module {
 	return &Module{keyvalStore: make(map[string]interface{})}
 }
 
+// NewPersistentModule initializes a key/value store backed by a state file.
+// The store is restored from the file if it exists, and saved to it on Close.
+func NewPersistentModule(stateFile string) (*Module, error) {
+	m := NewModule()
+	m.stateFile = stateFile
+
+	data, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &m.keyvalStore); err != nil {
+		return nil, fmt.Errorf("invalid keyval state file %s: %s", stateFile, err.Error())
+	}
+
+	if m.keyvalStore == nil {
+		m.keyvalStore = make(map[string]interface{})
+	}
+	storeSize.WithLabelValues().Set(float64(len(m.keyvalStore)))
+
+	return m, nil
+}
+
+// Close persists the store to its state file, if any.
+func (m *Module) Close() error {
+	if m.stateFile == "" {
+		return nil
+	}
+
+	m.mu.RLock()
+	data, err := json.Marshal(m.keyvalStore)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state file behind.
+	tmpFile := m.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, m.stateFile)
+}
+
+// Stateful returns true, the store lives in memory and in its state file.
+func (m *Module) Stateful() bool { return true }
+
 // Name returns the name of the keyval module.
 func (m *Module) Name() string { return keyvalModuleName }
 
@@ -33,39 +107,88 @@ func (m *Module) Actions() []string {
 	return []string{keyvalActionGet, keyvalActionSet, keyvalActionClear, keyvalActionList}
 }
 
+// ActionSchema returns the arguments of a key/val action.
+func (m *Module) ActionSchema(actionName string) schema.Schema {
+	key := schema.Field{Name: "key", Type: schema.TypeString, Required: true, Description: "Name of the key"}
+
+	switch actionName {
+	case keyvalActionGet:
+		return schema.NewReadOnly(key)
+	case keyvalActionClear:
+		return schema.New(key)
+	case keyvalActionSet:
+		return schema.New(key, schema.Field{Name: "val", Type: schema.TypeAny, Required: true, Description: "Value to store"})
+	case keyvalActionList:
+		return schema.NewReadOnly()
+	}
+
+	return schema.New()
+}
+
+// ActionRoutes exposes the keys as resources: GET, PUT and DELETE /keyval/keys/{key}.
+// The keys are served under their own prefix, so that keys named after actions
+// remain reachable. The body of PUT requests is the JSON value of the key.
+func (m *Module) ActionRoutes(actionName string) []schema.Route {
+	switch actionName {
+	case keyvalActionGet:
+		return []schema.Route{{Method: http.MethodGet, Path: "keys/{key}"}}
+	case keyvalActionSet:
+		return []schema.Route{{Method: http.MethodPut, Path: "keys/{key}", Body: "val"}}
+	case keyvalActionClear:
+		return []schema.Route{{Method: http.MethodDelete, Path: "keys/{key}"}}
+	}
+	return nil
+}
+
 // Execute executes a key/val action.
-func (m *Module) Execute(actionName string, data map[string]interface{}) ([]byte, error) {
+func (m *Module) Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
 	if actionName == keyvalActionList {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
 		return json.Marshal(map[string]interface{}{"values": m.keyvalStore})
 	}
 
 	keyRaw, ok := data["key"]
 
 	if !ok {
-		return nil, errors.New("key not specified")
+		return nil, apierr.InvalidArgument("key not specified")
 	}
 
-	key := keyRaw.(string)
+	key, ok := keyRaw.(string)
+	if !ok {
+		return nil, apierr.InvalidArgument("key must be a string").WithDetail("key", keyRaw)
+	}
 	val, valOk := data["val"]
 
 	switch actionName {
 	case keyvalActionSet:
 		if !valOk {
-			return nil, errors.New("cannot set, no value specified. use 'val'")
+			return nil, apierr.InvalidArgument("cannot set, no value specified. use 'val'")
 		}
+		m.mu.Lock()
 		m.keyvalStore[key] = val
+		storeSize.WithLabelValues().Set(float64(len(m.keyvalStore)))
+		m.mu.Unlock()
+		m.Events.Publish(ctx, events.TopicKeySet, map[string]interface{}{"key": key})
 		return json.Marshal(map[string]string{"message": "OK"})
 
 	case keyvalActionGet:
-		if returnVal, rOk := m.keyvalStore[key]; rOk {
+		m.mu.RLock()
+		returnVal, rOk := m.keyvalStore[key]
+		m.mu.RUnlock()
+		if rOk {
 			return json.Marshal(map[string]interface{}{"value": returnVal})
 		}
-		return nil, fmt.Errorf("unknown key: %s", key)
+		return nil, apierr.NotFound("unknown key: %s", key).WithDetail("key", key)
 
 	case keyvalActionClear:
+		m.mu.Lock()
 		delete(m.keyvalStore, key)
+		storeSize.WithLabelValues().Set(float64(len(m.keyvalStore)))
+		m.mu.Unlock()
+		m.Events.Publish(ctx, events.TopicKeyCleared, map[string]interface{}{"key": key})
 		return json.Marshal(map[string]string{"message": "OK"})
 	}
 
-	return nil, fmt.Errorf("unknown action: %s", actionName)
+	return nil, apierr.NotFound("unknown action: %s", actionName)
 }
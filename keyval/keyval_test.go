@@ -1,7 +1,11 @@
 package keyval_test
 
 import (
+	"context"
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/dalloriam/orc/keyval"
@@ -57,6 +61,7 @@ func TestModule_Execute(t *testing.T) {
 	cases := []testCase{
 		{"fails when action unknown", "random", map[string]interface{}{"key": "hello"}, nil, true},
 		{"fails when key not specified", "get", map[string]interface{}{}, nil, true},
+		{"fails when key is not a string", "get", map[string]interface{}{"key": 42.0}, nil, true},
 		{"fails when key doesnt exist", "get", map[string]interface{}{"key": "hello"}, nil, true},
 		{"sets keys correctly", "set", map[string]interface{}{"key": "hello", "val": "world"}, map[string]interface{}{"message": "OK"}, false},
 		{"fails when setting empty", "set", map[string]interface{}{"key": "hello"}, nil, true},
@@ -68,7 +73,7 @@ func TestModule_Execute(t *testing.T) {
 	for _, tCase := range cases {
 		t.Run(tCase.name, func(t *testing.T) {
 
-			out, err := m.Execute(tCase.action, tCase.data)
+			out, err := m.Execute(context.Background(), tCase.action, tCase.data)
 
 			if (err != nil) != tCase.wantErr {
 				t.Errorf("expected err: %v, got err=%v", tCase.wantErr, err)
@@ -101,3 +106,53 @@ func TestModule_Execute(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPersistentModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orc_keyval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stateFile := path.Join(dir, "keyval.json")
+
+	m, err := keyval.NewPersistentModule(stateFile)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if _, err := m.Execute(context.Background(), "set", map[string]interface{}{"key": "hello", "val": "world"}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	restored, err := keyval.NewPersistentModule(stateFile)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	out, err := restored.Execute(context.Background(), "get", map[string]interface{}{"key": "hello"})
+	if err != nil {
+		t.Fatalf("expected restored key, got %s", err.Error())
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatalf("module returned invalid JSON")
+	}
+
+	if parsed["value"] != "world" {
+		t.Errorf("expected restored value to be world, got %v", parsed["value"])
+	}
+
+	if err := ioutil.WriteFile(stateFile, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keyval.NewPersistentModule(stateFile); err == nil {
+		t.Errorf("expected error when loading corrupted state file")
+	}
+}
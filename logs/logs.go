@@ -0,0 +1,150 @@
+package logs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ModuleName and StreamAction form the scope granting access to the log stream,
+	// e.g. "logs/stream".
+	ModuleName   = "logs"
+	StreamAction = "stream"
+
+	subscriptionBuffer = 256
+)
+
+// Entry is a line of the server logs.
+type Entry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Subscription receives the log entries at or above its level.
+type Subscription struct {
+	broadcaster *Broadcaster
+	level       logrus.Level
+	entries     chan Entry
+
+	closeOnce sync.Once
+}
+
+// Entries returns the channel of the entries. It is closed when the subscription is closed.
+func (s *Subscription) Entries() <-chan Entry {
+	return s.entries
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.broadcaster.unsubscribe(s)
+	})
+}
+
+// Broadcaster is a logger hook sending the log entries to the subscribers.
+// Like the event bus, it never blocks the logger: entries are dropped for the
+// subscribers that don't keep up.
+type Broadcaster struct {
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+	closed        bool
+}
+
+// NewBroadcaster returns a broadcaster without subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscriptions: make(map[*Subscription]struct{})}
+}
+
+// Levels returns the levels of the entries received by the hook.
+func (b *Broadcaster) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire sends an entry to the subscribers. It is called by the logger.
+func (b *Broadcaster) Fire(entry *logrus.Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.subscriptions) == 0 {
+		return nil
+	}
+
+	e := Entry{
+		Time:    entry.Time.UTC(),
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+	}
+	if len(entry.Data) > 0 {
+		e.Fields = make(map[string]interface{}, len(entry.Data))
+		for key, val := range entry.Data {
+			// Errors don't survive JSON encoding.
+			if err, ok := val.(error); ok {
+				val = err.Error()
+			}
+			e.Fields[key] = val
+		}
+	}
+
+	for sub := range b.subscriptions {
+		if entry.Level > sub.level {
+			continue
+		}
+
+		select {
+		case sub.entries <- e:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns a subscription to the entries at or above the level.
+func (b *Broadcaster) Subscribe(level logrus.Level) *Subscription {
+	sub := &Subscription{
+		broadcaster: b,
+		level:       level,
+		entries:     make(chan Entry, subscriptionBuffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.entries)
+		return sub
+	}
+
+	b.subscriptions[sub] = struct{}{}
+	return sub
+}
+
+// Close ends all the subscriptions. Subscriptions made after Close are ended right away.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscriptions {
+		b.remove(sub)
+	}
+}
+
+func (b *Broadcaster) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscriptions[sub]; ok {
+		b.remove(sub)
+	}
+}
+
+// remove ends a subscription. The caller must hold the lock.
+func (b *Broadcaster) remove(sub *Subscription) {
+	delete(b.subscriptions, sub)
+	close(sub.entries)
+}
@@ -0,0 +1,56 @@
+package logs_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dalloriam/orc/logs"
+	"github.com/sirupsen/logrus"
+)
+
+func newLogger(b *logs.Broadcaster) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.SetLevel(logrus.DebugLevel)
+	logger.AddHook(b)
+	return logger
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := logs.NewBroadcaster()
+	logger := newLogger(b)
+
+	warnings := b.Subscribe(logrus.WarnLevel)
+	all := b.Subscribe(logrus.DebugLevel)
+
+	logger.Debug("starting")
+	logger.WithError(errors.New("disk full")).WithField("task", "backup").Warn("task failed")
+
+	select {
+	case entry := <-warnings.Entries():
+		if entry.Message != "task failed" || entry.Level != "warning" {
+			t.Errorf("unexpected entry: %+v", entry)
+		}
+		if entry.Fields["task"] != "backup" || entry.Fields["error"] != "disk full" {
+			t.Errorf("unexpected fields: %v", entry.Fields)
+		}
+	default:
+		t.Fatalf("expected a warning")
+	}
+
+	if len(all.Entries()) != 2 {
+		t.Errorf("expected 2 entries at debug level, got %d", len(all.Entries()))
+	}
+
+	warnings.Close()
+	logger.Warn("ignored")
+	if _, ok := <-warnings.Entries(); ok {
+		t.Errorf("expected closed subscription to receive nothing")
+	}
+
+	b.Close()
+	if _, ok := <-b.Subscribe(logrus.InfoLevel).Entries(); ok {
+		t.Errorf("expected subscriptions made after close to be ended")
+	}
+}
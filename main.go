@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"flag"
+	"os"
 
+	"github.com/dalloriam/orc/config"
 	"github.com/dalloriam/orc/version"
 	"github.com/genuinetools/pkg/cli"
 	"github.com/sirupsen/logrus"
@@ -19,16 +21,30 @@ func main() {
 	p.GitCommit = version.GITCOMMIT
 	p.Version = version.VERSION
 
+	s := &settings{}
+
 	p.Commands = []cli.Command{
 		&cliCommand{},
-		&serverCommand{},
+		&configCommand{settings: s},
+		&contextCommand{},
+		&runCommand{settings: s},
+		&serverCommand{settings: s},
+		&shellCommand{},
+		&shellinitCommand{settings: s},
 	}
 
 	p.FlagSet = flag.NewFlagSet("orc", flag.ExitOnError)
 
 	p.Before = func(ctx context.Context) error {
-		// Set the log level.
-		logrus.SetLevel(logrus.DebugLevel)
+		// The server configuration is only loaded by the commands needing it, which
+		// apply its log level. The others only honor the one of the environment.
+		if val := os.Getenv(config.EnvLogLevel); val != "" {
+			level, err := logrus.ParseLevel(val)
+			if err != nil {
+				return err
+			}
+			logrus.SetLevel(level)
+		}
 
 		return nil
 	}
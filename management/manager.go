@@ -1,42 +1,221 @@
 package management
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/schema"
+	"github.com/dalloriam/orc/supervisor"
 )
 
 const (
 	managementModName = "manage"
 
 	getActionsAction = "actions_available"
+	getModulesAction = "modules"
+	getHistoryAction = "history"
+
+	// HistorySize is the number of runs kept in the history.
+	HistorySize = 100
 )
 
+// Kinds of modules.
+const (
+	KindBuiltin = "builtin"
+	KindPlugin  = "plugin"
+)
+
+// ModuleStatus describes a registered module.
+type ModuleStatus struct {
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Actions []string `json:"actions"`
+
+	// Stateful is set for the modules keeping state across actions, which is
+	// not shared with the modules of "orc run".
+	Stateful bool `json:"stateful,omitempty"`
+
+	// Disabled is set when the module was disabled after repeated panics.
+	Disabled bool `json:"disabled"`
+}
+
+// Run is a past execution of an action.
+type Run struct {
+	Module     string        `json:"module"`
+	Action     string        `json:"action"`
+	Caller     string        `json:"caller,omitempty"`
+	RequestID  string        `json:"request_id,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	DurationMs float64       `json:"duration_ms"`
+	Error      *apierr.Error `json:"error,omitempty"`
+}
+
 // Module manages an ORC instance.
 type Module struct {
+	mu        sync.RWMutex
 	actionMap map[string][]string
+	schemas   map[string]map[string]schema.Schema
+	kinds     map[string]string
+	stateful  map[string]bool
+
+	// history holds the last runs, oldest first.
+	history []Run
+
+	// Supervisor reports the modules disabled after repeated panics.
+	Supervisor *supervisor.Supervisor
 }
 
 // NewModule returns a new management module.
 func NewModule() *Module {
 	return &Module{
 		actionMap: make(map[string][]string),
+		schemas:   make(map[string]map[string]schema.Schema),
+		kinds:     make(map[string]string),
+		stateful:  make(map[string]bool),
 	}
 }
 
+// Stateful returns true, the history only exists in the memory of the module.
+func (m *Module) Stateful() bool { return true }
+
 // Name returns the name of the management module.
 func (m *Module) Name() string { return managementModName }
 
 // Actions returns the actions defined by the management module.
 func (m *Module) Actions() []string {
-	return []string{getActionsAction}
+	return []string{getActionsAction, getModulesAction, getHistoryAction}
 }
 
-func (m *Module) getActions() ([]byte, error) {
+// ActionSchema returns the arguments of a management action.
+func (m *Module) ActionSchema(actionName string) schema.Schema {
+	if actionName == getActionsAction {
+		return schema.NewReadOnly(schema.Field{
+			Name:        "detailed",
+			Type:        schema.TypeBoolean,
+			Default:     false,
+			Description: "Include the argument schema of every action",
+		})
+	}
+	if actionName == getModulesAction || actionName == getHistoryAction {
+		return schema.NewReadOnly()
+	}
+	return schema.New()
+}
+
+func (m *Module) getActions(detailed bool) ([]byte, error) {
+	if detailed {
+		return json.Marshal(m.Schemas())
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return json.Marshal(m.actionMap)
 }
 
+// Schemas returns the argument schemas of the registered actions, indexed by module and action name.
+func (m *Module) Schemas() map[string]map[string]schema.Schema {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	detailedMap := make(map[string]map[string]schema.Schema)
+	for moduleName, actions := range m.actionMap {
+		detailedMap[moduleName] = make(map[string]schema.Schema)
+		for _, action := range actions {
+			actionSchema := m.schemas[moduleName][action]
+			if actionSchema.Arguments == nil {
+				actionSchema.Arguments = []schema.Field{}
+			}
+			detailedMap[moduleName][action] = actionSchema
+		}
+	}
+
+	return detailedMap
+}
+
+// Modules returns the status of the registered modules, sorted by name.
+func (m *Module) Modules() []ModuleStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	modules := []ModuleStatus{}
+	for moduleName, actions := range m.actionMap {
+		kind := m.kinds[moduleName]
+		if kind == "" {
+			kind = KindBuiltin
+		}
+
+		modules = append(modules, ModuleStatus{
+			Name:     moduleName,
+			Kind:     kind,
+			Actions:  append([]string{}, actions...),
+			Stateful: m.stateful[moduleName],
+			Disabled: m.Supervisor.Disabled(moduleName),
+		})
+	}
+
+	sort.Slice(modules, func(i, k int) bool {
+		return modules[i].Name < modules[k].Name
+	})
+
+	return modules
+}
+
+// RecordRun adds a run to the history, forgetting the oldest runs past HistorySize.
+func (m *Module) RecordRun(run Run) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.history = append(m.history, run)
+	if len(m.history) > HistorySize {
+		m.history = append([]Run{}, m.history[len(m.history)-HistorySize:]...)
+	}
+}
+
+// History returns the last runs, most recent first.
+func (m *Module) History() []Run {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	runs := make([]Run, 0, len(m.history))
+	for i := len(m.history) - 1; i >= 0; i-- {
+		runs = append(runs, m.history[i])
+	}
+	return runs
+}
+
+// Reset forgets all registered actions. The history is kept.
+func (m *Module) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.actionMap = make(map[string][]string)
+	m.schemas = make(map[string]map[string]schema.Schema)
+	m.kinds = make(map[string]string)
+	m.stateful = make(map[string]bool)
+}
+
+// SetKind declares the kind of a module, modules are builtins by default.
+func (m *Module) SetKind(moduleName, kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kinds[moduleName] = kind
+}
+
+// SetStateful declares that a module keeps state across actions.
+func (m *Module) SetStateful(moduleName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stateful[moduleName] = true
+}
+
 // RegisterAction adds the action to the manager.
 func (m *Module) RegisterAction(moduleName, action string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if _, ok := m.actionMap[moduleName]; !ok {
 		m.actionMap[moduleName] = []string{}
 	}
@@ -44,11 +223,28 @@ func (m *Module) RegisterAction(moduleName, action string) {
 	m.actionMap[moduleName] = append(m.actionMap[moduleName], action)
 }
 
+// SetSchema declares the arguments of a registered action.
+func (m *Module) SetSchema(moduleName, action string, actionSchema schema.Schema) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.schemas[moduleName]; !ok {
+		m.schemas[moduleName] = make(map[string]schema.Schema)
+	}
+
+	m.schemas[moduleName][action] = actionSchema
+}
+
 // Execute executes a management action.
-func (m *Module) Execute(actionName string, data map[string]interface{}) ([]byte, error) {
+func (m *Module) Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
 	switch actionName {
 	case getActionsAction:
-		return m.getActions()
+		detailed, _ := data["detailed"].(bool)
+		return m.getActions(detailed)
+	case getModulesAction:
+		return json.Marshal(map[string]interface{}{"modules": m.Modules()})
+	case getHistoryAction:
+		return json.Marshal(map[string]interface{}{"runs": m.History()})
 	}
-	return nil, fmt.Errorf("unknown action: %s", actionName)
+	return nil, apierr.NotFound("unknown action: %s", actionName)
 }
@@ -1,13 +1,22 @@
 package management_test
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/dalloriam/orc/management"
+	"github.com/dalloriam/orc/schema"
+	"github.com/dalloriam/orc/supervisor"
+	"github.com/sirupsen/logrus"
 )
 
+func init() {
+	logrus.SetLevel(logrus.PanicLevel)
+}
+
 const (
 	actionActionsAvailable = "actions_available"
 	moduleName             = "manage"
@@ -95,7 +104,7 @@ func TestModule_Execute(t *testing.T) {
 				}
 			}
 
-			actualBytes, err := mod.Execute(tCase.actionName, nil)
+			actualBytes, err := mod.Execute(context.Background(), tCase.actionName, nil)
 
 			if (err != nil) != tCase.wantErr {
 				t.Errorf("expected err==nil: %v, got err=%v instead", tCase.wantErr, err)
@@ -127,3 +136,72 @@ func TestModule_Execute(t *testing.T) {
 		})
 	}
 }
+
+func TestModule_ExecuteDetailed(t *testing.T) {
+	mod := management.NewModule()
+	mod.RegisterAction("keyval", "get")
+	mod.RegisterAction("keyval", "list")
+	mod.SetSchema("keyval", "get", schema.New(schema.Field{Name: "key", Type: schema.TypeString, Required: true}))
+
+	actualBytes, err := mod.Execute(context.Background(), actionActionsAvailable, map[string]interface{}{"detailed": true})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	var actual map[string]map[string]schema.Schema
+	if err := json.Unmarshal(actualBytes, &actual); err != nil {
+		t.Fatalf("execute returned invalid JSON: %s", actualBytes)
+	}
+
+	expected := map[string]map[string]schema.Schema{
+		"keyval": {
+			"get":  schema.New(schema.Field{Name: "key", Type: schema.TypeString, Required: true}),
+			"list": schema.New(),
+		},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected output [%v], got [%v] instead", expected, actual)
+	}
+}
+
+func TestModule_Modules(t *testing.T) {
+	sup := supervisor.New(supervisor.Policy{MaxPanics: 1, Window: time.Minute})
+	func() {
+		defer sup.Recover(context.Background(), "broken", "test")
+		panic("boom")
+	}()
+
+	mod := management.NewModule()
+	mod.Supervisor = sup
+	mod.RegisterAction("keyval", "get")
+	mod.RegisterAction("broken", "run")
+	mod.SetKind("broken", management.KindPlugin)
+	mod.SetStateful("keyval")
+
+	expected := []management.ModuleStatus{
+		{Name: "broken", Kind: management.KindPlugin, Actions: []string{"run"}, Disabled: true},
+		{Name: "keyval", Kind: management.KindBuiltin, Actions: []string{"get"}, Stateful: true},
+	}
+
+	if actual := mod.Modules(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected modules %v, got %v", expected, actual)
+	}
+}
+
+func TestModule_History(t *testing.T) {
+	mod := management.NewModule()
+
+	for i := 0; i < management.HistorySize+10; i++ {
+		mod.RecordRun(management.Run{Module: "keyval", Action: "set", DurationMs: float64(i)})
+	}
+
+	runs := mod.History()
+	if len(runs) != management.HistorySize {
+		t.Fatalf("expected %d runs, got %d", management.HistorySize, len(runs))
+	}
+
+	if runs[0].DurationMs != float64(management.HistorySize+9) || runs[len(runs)-1].DurationMs != 10 {
+		t.Errorf("expected the most recent runs first, got %v ... %v", runs[0], runs[len(runs)-1])
+	}
+}
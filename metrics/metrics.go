@@ -0,0 +1,423 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are the default histogram buckets, suited to request latencies in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector is a family of metrics that can be exposed.
+type Collector interface {
+	// Name returns the name of the metric family.
+	Name() string
+
+	// Write writes the family in the text exposition format.
+	Write(w io.Writer) error
+}
+
+// Registry holds the exposed collectors.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register adds the collectors to the registry. Names must be unique.
+func (r *Registry) Register(collectors ...Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range collectors {
+		if _, ok := r.collectors[c.Name()]; ok {
+			return fmt.Errorf("duplicate metric: %s", c.Name())
+		}
+		r.collectors[c.Name()] = c
+	}
+
+	return nil
+}
+
+// MustRegister adds the collectors to the registry and panics on duplicate names.
+func (r *Registry) MustRegister(collectors ...Collector) {
+	if err := r.Register(collectors...); err != nil {
+		panic(err)
+	}
+}
+
+// WriteText writes all the collectors, sorted by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]Collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	buf := bufio.NewWriter(w)
+	for _, c := range collectors {
+		if err := c.Write(buf); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+// Handler serves the registry in the text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteText(w)
+	})
+}
+
+// DefaultRegistry is the registry used by the ORC components.
+var DefaultRegistry = NewRegistry()
+
+// MustRegister adds the collectors to the default registry.
+func MustRegister(collectors ...Collector) {
+	DefaultRegistry.MustRegister(collectors...)
+}
+
+// Handler serves the default registry.
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// desc holds the description shared by the metrics of a family.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) Name() string { return d.name }
+
+func (d *desc) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+	return err
+}
+
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+// labelString renders the labels of a sample, with optional extra label pairs.
+func (d *desc) labelString(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, d.labels[i], escapeLabel(v)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelKey joins label values into a map key.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func splitKey(key string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	return strings.SplitN(key, "\xff", n)
+}
+
+func sortedKeys(m map[string]*value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// value is a float updated under the lock of its family.
+type value struct {
+	v float64
+}
+
+// vec is a family of float values indexed by label values.
+type vec struct {
+	desc
+
+	mu     sync.Mutex
+	values map[string]*value
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{
+		desc:   desc{name: name, help: help, kind: kind, labels: labels},
+		values: make(map[string]*value),
+	}
+}
+
+func (v *vec) get(labelValues []string) *value {
+	v.checkLabels(labelValues)
+	key := labelKey(labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	val, ok := v.values[key]
+	if !ok {
+		val = &value{}
+		v.values[key] = val
+	}
+	return val
+}
+
+func (v *vec) update(val *value, fn func(float64) float64) {
+	v.mu.Lock()
+	val.v = fn(val.v)
+	v.mu.Unlock()
+}
+
+// Write writes the family in the text exposition format.
+func (v *vec) Write(w io.Writer) error {
+	if err := v.writeHeader(w); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, key := range sortedKeys(v.values) {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", v.name, v.labelString(splitKey(key, len(v.labels))), formatFloat(v.values[key].v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CounterVec is a family of counters partitioned by labels.
+type CounterVec struct {
+	*vec
+}
+
+// NewCounterVec returns a new counter family.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, "counter", labels)}
+}
+
+// WithLabelValues returns the counter of the label values, in the order of the labels.
+func (c *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return &Counter{vec: c.vec, val: c.get(labelValues)}
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	vec *vec
+	val *value
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increments the counter. Negative values are ignored.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.vec.update(c.val, func(v float64) float64 { return v + delta })
+}
+
+// GaugeVec is a family of gauges partitioned by labels.
+type GaugeVec struct {
+	*vec
+}
+
+// NewGaugeVec returns a new gauge family.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newVec(name, help, "gauge", labels)}
+}
+
+// WithLabelValues returns the gauge of the label values, in the order of the labels.
+func (g *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return &Gauge{vec: g.vec, val: g.get(labelValues)}
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	vec *vec
+	val *value
+}
+
+// Set sets the gauge.
+func (g *Gauge) Set(v float64) {
+	g.vec.update(g.val, func(float64) float64 { return v })
+}
+
+// Add adds the delta to the gauge.
+func (g *Gauge) Add(delta float64) {
+	g.vec.update(g.val, func(v float64) float64 { return v + delta })
+}
+
+// Inc increments the gauge by one.
+func (g *Gauge) Inc() { g.Add(1) }
+
+// Dec decrements the gauge by one.
+func (g *Gauge) Dec() { g.Add(-1) }
+
+// GaugeFunc is a gauge whose value is computed when collected.
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc returns a gauge collecting the value returned by fn.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{desc: desc{name: name, help: help, kind: "gauge"}, fn: fn}
+}
+
+// Write writes the gauge in the text exposition format.
+func (g *GaugeFunc) Write(w io.Writer) error {
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+	return err
+}
+
+// HistogramVec is a family of histograms partitioned by labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+
+	mu         sync.Mutex
+	histograms map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec returns a new histogram family. Nil buckets default to DefBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &HistogramVec{
+		desc:       desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets:    sorted,
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// Observe adds an observation to the histogram of the label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+	key := labelKey(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.histograms[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = hist
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+		}
+	}
+	hist.sum += v
+	hist.count++
+}
+
+// Write writes the family in the text exposition format.
+func (h *HistogramVec) Write(w io.Writer) error {
+	if err := h.writeHeader(w); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.histograms))
+	for k := range h.histograms {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hist := h.histograms[key]
+		values := splitKey(key, len(h.labels))
+
+		for i, upper := range h.buckets {
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(values, "le", formatFloat(upper)), hist.counts[i]); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, h.labelString(values, "le", "+Inf"), hist.count,
+			h.name, h.labelString(values), formatFloat(hist.sum),
+			h.name, h.labelString(values), hist.count,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
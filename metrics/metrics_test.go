@@ -0,0 +1,79 @@
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dalloriam/orc/metrics"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	requests := metrics.NewCounterVec("orc_test_requests_total", "Requests handled.", "module", "action")
+	running := metrics.NewGaugeVec("orc_test_running", "Running things.")
+	latency := metrics.NewHistogramVec("orc_test_duration_seconds", "Latency.", []float64{1, 0.1}, "module")
+	reg.MustRegister(requests, running, latency)
+
+	requests.WithLabelValues("keyval", "get").Inc()
+	requests.WithLabelValues("keyval", "get").Add(2)
+	requests.WithLabelValues("task", `say "hi"`).Inc()
+	running.WithLabelValues().Set(3)
+	running.WithLabelValues().Dec()
+	latency.Observe(0.05, "keyval")
+	latency.Observe(0.5, "keyval")
+	latency.Observe(5, "keyval")
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"# HELP orc_test_duration_seconds Latency.",
+		"# TYPE orc_test_duration_seconds histogram",
+		`orc_test_duration_seconds_bucket{module="keyval",le="0.1"} 1`,
+		`orc_test_duration_seconds_bucket{module="keyval",le="1"} 2`,
+		`orc_test_duration_seconds_bucket{module="keyval",le="+Inf"} 3`,
+		`orc_test_duration_seconds_sum{module="keyval"} 5.55`,
+		`orc_test_duration_seconds_count{module="keyval"} 3`,
+		"# HELP orc_test_requests_total Requests handled.",
+		"# TYPE orc_test_requests_total counter",
+		`orc_test_requests_total{module="keyval",action="get"} 3`,
+		`orc_test_requests_total{module="task",action="say \"hi\""} 1`,
+		"# HELP orc_test_running Running things.",
+		"# TYPE orc_test_running gauge",
+		"orc_test_running 2",
+		"",
+	}, "\n")
+
+	if buf.String() != expected {
+		t.Errorf("unexpected exposition:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	if err := reg.Register(metrics.NewGaugeVec("orc_test", "Test.")); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if err := reg.Register(metrics.NewCounterVec("orc_test", "Test.")); err == nil {
+		t.Errorf("expected duplicate metric to be rejected")
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	var buf bytes.Buffer
+	if err := metrics.DefaultRegistry.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"go_goroutines", "go_memstats_alloc_bytes", "process_start_time_seconds"} {
+		if !strings.Contains(buf.String(), "\n"+name+" ") {
+			t.Errorf("expected runtime metric %s to be exposed", name)
+		}
+	}
+}
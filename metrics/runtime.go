@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the USER_HZ of Linux, used to convert /proc CPU times to seconds.
+const clockTicks = 100
+
+var processStart = time.Now()
+
+func init() {
+	DefaultRegistry.MustRegister(
+		NewGaugeFunc("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", func() float64 {
+			return float64(processStart.UnixNano()) / 1e9
+		}),
+		NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+			return float64(runtime.NumGoroutine())
+		}),
+		&goInfo{desc: desc{name: "go_info", help: "Information about the Go environment.", kind: "gauge"}},
+		&memStats{desc: desc{name: "go_memstats", kind: "gauge"}},
+		&procStats{desc: desc{name: "process", kind: "gauge"}},
+	)
+}
+
+type goInfo struct {
+	desc
+}
+
+func (g *goInfo) Write(w io.Writer) error {
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "go_info{version=\"%s\"} 1\n", escapeLabel(runtime.Version()))
+	return err
+}
+
+// memStats exposes the Go memory statistics.
+type memStats struct {
+	desc
+}
+
+func (m *memStats) Write(w io.Writer) error {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return writeGauges(w, []gauge{
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", "gauge", float64(stats.Alloc)},
+		{"go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", "counter", float64(stats.TotalAlloc)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from system.", "gauge", float64(stats.Sys)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", "gauge", float64(stats.HeapInuse)},
+		{"go_memstats_heap_objects", "Number of allocated objects.", "gauge", float64(stats.HeapObjects)},
+		{"go_memstats_gc_cpu_fraction", "The fraction of this program's available CPU time used by the GC since the program started.", "gauge", stats.GCCPUFraction},
+		{"go_memstats_last_gc_time_seconds", "Number of seconds since 1970 of last garbage collection.", "gauge", float64(stats.LastGC) / 1e9},
+		{"go_gc_cycles_total", "Number of completed GC cycles.", "counter", float64(stats.NumGC)},
+	})
+}
+
+// procStats exposes the process statistics read from /proc.
+// Nothing is exposed on systems without procfs.
+type procStats struct {
+	desc
+}
+
+func (p *procStats) Write(w io.Writer) error {
+	var gauges []gauge
+
+	if stat, err := ioutil.ReadFile("/proc/self/stat"); err == nil {
+		// Fields are counted after the command name, which may contain spaces.
+		fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+		if len(fields) > 21 {
+			utime, _ := strconv.ParseFloat(fields[11], 64)
+			stime, _ := strconv.ParseFloat(fields[12], 64)
+			vsize, _ := strconv.ParseFloat(fields[20], 64)
+			rss, _ := strconv.ParseFloat(fields[21], 64)
+
+			gauges = append(gauges,
+				gauge{"process_cpu_seconds_total", "Total user and system CPU time spent in seconds.", "counter", (utime + stime) / clockTicks},
+				gauge{"process_virtual_memory_bytes", "Virtual memory size in bytes.", "gauge", vsize},
+				gauge{"process_resident_memory_bytes", "Resident memory size in bytes.", "gauge", rss * float64(os.Getpagesize())},
+			)
+		}
+	}
+
+	if fds, err := ioutil.ReadDir("/proc/self/fd"); err == nil {
+		gauges = append(gauges, gauge{"process_open_fds", "Number of open file descriptors.", "gauge", float64(len(fds))})
+	}
+
+	return writeGauges(w, gauges)
+}
+
+type gauge struct {
+	name string
+	help string
+	kind string
+	val  float64
+}
+
+func writeGauges(w io.Writer, gauges []gauge) error {
+	for _, g := range gauges {
+		d := desc{name: g.name, help: g.help, kind: g.kind}
+		if err := d.writeHeader(w); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.val)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,10 +1,59 @@
 package main
 
+import (
+	"context"
+	"time"
+
+	"github.com/dalloriam/orc/schema"
+)
+
 // Module represents an abstract task handler.
 // Modules can be both a wrapped plugin or an internal module.
 type Module interface {
 	Name() string
 	Actions() []string
 
-	Execute(actionName string, data map[string]interface{}) ([]byte, error)
+	// Execute runs an action. The context carries the deadline of the action,
+	// the request ID and the identity of the caller, see the request package.
+	Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error)
+}
+
+// TimeoutProvider is implemented by modules defining default timeouts for their actions.
+// A zero duration means the action uses the configured default.
+type TimeoutProvider interface {
+	ActionTimeout(actionName string) time.Duration
+}
+
+// SchemaProvider is implemented by modules declaring the arguments of their actions.
+// Payloads are validated against the schema before being dispatched to the module.
+type SchemaProvider interface {
+	ActionSchema(actionName string) schema.Schema
+}
+
+// AsyncProvider is implemented by modules declaring actions that always run as background jobs.
+type AsyncProvider interface {
+	ActionAsync(actionName string) bool
+}
+
+// RouteProvider is implemented by modules exposing actions on resource-style routes,
+// in addition to /{module}/{action}.
+type RouteProvider interface {
+	ActionRoutes(actionName string) []schema.Route
+}
+
+// Closer is implemented by modules that must release resources or persist state on shutdown.
+type Closer interface {
+	Close() error
+}
+
+// StateHolder is implemented by modules keeping state across actions, in memory or
+// in the state directory. The state belongs to the process running the module: the
+// modules of "orc run" work on their own copy, apart from a running daemon.
+type StateHolder interface {
+	Stateful() bool
+}
+
+// Reloader is implemented by modules able to reload their definitions in place.
+type Reloader interface {
+	Reload() error
 }
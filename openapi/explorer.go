@@ -0,0 +1,175 @@
+package openapi
+
+// ExplorerHTML is a self-contained page rendering the OpenAPI document of the
+// server and calling its actions. It loads no external resources, so that it
+// works on offline and air-gapped hosts.
+const ExplorerHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>ORC API explorer</title>
+<style>
+  body { font-family: sans-serif; margin: 0; background: #f5f5f5; color: #222; }
+  header { background: #222; color: #eee; padding: 12px 24px; display: flex; align-items: center; gap: 16px; }
+  header h1 { font-size: 18px; margin: 0; flex: 1; }
+  header input { width: 320px; padding: 4px; }
+  main { max-width: 960px; margin: 0 auto; padding: 16px; }
+  h2 { border-bottom: 1px solid #ccc; padding-bottom: 4px; }
+  details { background: #fff; border: 1px solid #ddd; border-radius: 4px; margin: 8px 0; }
+  summary { cursor: pointer; padding: 8px 12px; font-family: monospace; }
+  summary .method { background: #2a7; color: #fff; padding: 2px 6px; border-radius: 3px; margin-right: 8px; }
+  form { padding: 8px 12px 12px; }
+  label { display: block; margin: 8px 0 2px; font-size: 13px; }
+  label .req { color: #c33; }
+  label .desc { color: #777; }
+  input[type=text], input[type=number], select, textarea { width: 100%; box-sizing: border-box; padding: 4px; font-family: monospace; }
+  pre { background: #272822; color: #f8f8f2; padding: 8px; overflow: auto; border-radius: 3px; }
+  .status-ok { color: #2a7; }
+  .status-err { color: #c33; }
+  #error { color: #c33; }
+</style>
+</head>
+<body>
+<header>
+  <h1 id="title">ORC API explorer</h1>
+  <a id="spec" href="../openapi.json" style="color:#9cf">openapi.json</a>
+  <input id="token" type="password" placeholder="Bearer token (optional)">
+</header>
+<main>
+  <p id="error"></p>
+  <div id="modules"></div>
+</main>
+<script>
+(function () {
+  var specURL = "../openapi.json";
+  var tokenInput = document.getElementById("token");
+  tokenInput.value = localStorage.getItem("orc-token") || "";
+  tokenInput.addEventListener("change", function () {
+    localStorage.setItem("orc-token", tokenInput.value);
+  });
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") { node.textContent = attrs[k]; } else { node.setAttribute(k, attrs[k]); }
+    });
+    (children || []).forEach(function (c) { node.appendChild(c); });
+    return node;
+  }
+
+  function input(name, prop) {
+    if (prop.enum) {
+      var select = el("select", { name: name }, [el("option", { value: "", text: "" })]);
+      prop.enum.forEach(function (v) { select.appendChild(el("option", { value: JSON.stringify(v), text: String(v) })); });
+      if (prop.default !== undefined) { select.value = JSON.stringify(prop.default); }
+      return select;
+    }
+    switch (prop.type) {
+      case "boolean":
+        var checkbox = el("input", { type: "checkbox", name: name });
+        checkbox.checked = prop.default === true;
+        return checkbox;
+      case "integer":
+      case "number":
+        return el("input", { type: "number", name: name, step: prop.type === "integer" ? "1" : "any", value: prop.default !== undefined ? prop.default : "" });
+      case "string":
+        return el("input", { type: "text", name: name, value: prop.default !== undefined ? prop.default : "" });
+      default:
+        return el("textarea", { name: name, rows: "2", placeholder: "JSON value" });
+    }
+  }
+
+  function readValue(field, prop) {
+    if (prop.enum) { return field.value === "" ? undefined : JSON.parse(field.value); }
+    switch (prop.type) {
+      case "boolean": return field.checked;
+      case "integer":
+      case "number": return field.value === "" ? undefined : Number(field.value);
+      case "string": return field.value === "" ? undefined : field.value;
+      default:
+        if (field.value.trim() === "") { return undefined; }
+        try { return JSON.parse(field.value); } catch (e) { return field.value; }
+    }
+  }
+
+  function operation(path, op) {
+    var body = op.requestBody.content["application/json"].schema;
+    var props = body.properties || {};
+    var required = body.required || [];
+    var form = el("form");
+
+    Object.keys(props).forEach(function (name) {
+      var prop = props[name];
+      var label = el("label", { text: name + " " }, [
+        el("span", { "class": "req", text: required.indexOf(name) >= 0 ? "* " : "" }),
+        el("span", { "class": "desc", text: (prop.type || "any") + (prop.description ? " - " + prop.description : "") })
+      ]);
+      form.appendChild(label);
+      form.appendChild(input(name, prop));
+    });
+
+    var async = el("input", { type: "checkbox", name: "async" });
+    form.appendChild(el("label", { text: "run as background job " }, [async]));
+    form.appendChild(el("button", { type: "submit", text: "Send" }));
+    var status = el("p");
+    var output = el("pre", { text: "" });
+    output.style.display = "none";
+    form.appendChild(status);
+    form.appendChild(output);
+
+    form.addEventListener("submit", function (evt) {
+      evt.preventDefault();
+      var payload = {};
+      Object.keys(props).forEach(function (name) {
+        var value = readValue(form.elements[name], props[name]);
+        if (value !== undefined) { payload[name] = value; }
+      });
+
+      var headers = { "Content-Type": "application/json" };
+      if (tokenInput.value) { headers["Authorization"] = "Bearer " + tokenInput.value; }
+
+      fetch(".." + path + (async.checked ? "?async=1" : ""), { method: "POST", headers: headers, body: JSON.stringify(payload) })
+        .then(function (resp) {
+          return resp.text().then(function (text) {
+            status.textContent = resp.status + " " + resp.statusText;
+            status.className = resp.ok ? "status-ok" : "status-err";
+            try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) {}
+            output.textContent = text;
+            output.style.display = "block";
+          });
+        })
+        .catch(function (err) {
+          status.textContent = String(err);
+          status.className = "status-err";
+        });
+    });
+
+    return el("details", {}, [
+      el("summary", {}, [el("span", { "class": "method", text: "POST" }), document.createTextNode(path)]),
+      form
+    ]);
+  }
+
+  fetch(specURL)
+    .then(function (resp) { return resp.json(); })
+    .then(function (spec) {
+      document.getElementById("title").textContent = spec.info.title + " " + spec.info.version;
+      var modules = document.getElementById("modules");
+      var byTag = {};
+      Object.keys(spec.paths).sort().forEach(function (path) {
+        var op = spec.paths[path].post;
+        (byTag[op.tags[0]] = byTag[op.tags[0]] || []).push(operation(path, op));
+      });
+      spec.tags.forEach(function (tag) {
+        modules.appendChild(el("h2", { text: tag.name }));
+        (byTag[tag.name] || []).forEach(function (node) { modules.appendChild(node); });
+      });
+    })
+    .catch(function (err) {
+      document.getElementById("error").textContent = "Cannot load " + specURL + ": " + err;
+    });
+})();
+</script>
+</body>
+</html>
+`
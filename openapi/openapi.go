@@ -0,0 +1,280 @@
+package openapi
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/schema"
+)
+
+// Well-known paths of the specification and of the explorer.
+const (
+	SpecPath     = "/openapi.json"
+	ExplorerPath = "/docs/"
+
+	specVersion = "3.0.3"
+
+	errorSchemaRef = "#/components/schemas/Error"
+)
+
+// Document is an OpenAPI 3 document.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Tags       []Tag                 `json:"tags"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Tag groups the operations of a module.
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem holds the operations of a path.
+type PathItem struct {
+	Get  *Operation `json:"get,omitempty"`
+	Post *Operation `json:"post,omitempty"`
+}
+
+// Operation describes a module action.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Tags        []string             `json:"tags"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the payload of an operation.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema, as supported by OpenAPI.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+// Components holds the reusable parts of the document.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes the authentication of the API.
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// Options customizes the generated document.
+type Options struct {
+	Title   string
+	Version string
+
+	// Authenticated declares the bearer token authentication on every operation.
+	Authenticated bool
+}
+
+// Generate builds the OpenAPI document of the registered actions, indexed by module name.
+func Generate(opts Options, actions map[string]map[string]schema.Schema) *Document {
+	doc := &Document{
+		OpenAPI: specVersion,
+		Info: Info{
+			Title:       opts.Title,
+			Description: "Every action is exposed as POST /{module}/{action} and accepts a JSON object of arguments. Read-only actions are also exposed as GET, with the arguments passed as query parameters.",
+			Version:     opts.Version,
+		},
+		Tags:  []Tag{},
+		Paths: make(map[string]*PathItem),
+		Components: Components{
+			Schemas: map[string]*Schema{"Error": errorSchema()},
+		},
+	}
+
+	if opts.Authenticated {
+		doc.Components.SecuritySchemes = map[string]*SecurityScheme{
+			"bearer": {Type: "http", Scheme: "bearer"},
+		}
+		doc.Security = []map[string][]string{{"bearer": {}}}
+	}
+
+	moduleNames := make([]string, 0, len(actions))
+	for moduleName := range actions {
+		moduleNames = append(moduleNames, moduleName)
+	}
+	sort.Strings(moduleNames)
+
+	for _, moduleName := range moduleNames {
+		doc.Tags = append(doc.Tags, Tag{Name: moduleName})
+
+		for actionName, actionSchema := range actions[moduleName] {
+			item := &PathItem{Post: operation(moduleName, actionName, actionSchema)}
+			if actionSchema.ReadOnly {
+				item.Get = queryOperation(moduleName, actionName, actionSchema)
+			}
+			doc.Paths[fmt.Sprintf("/%s/%s", moduleName, actionName)] = item
+		}
+	}
+
+	return doc
+}
+
+func operation(moduleName, actionName string, actionSchema schema.Schema) *Operation {
+	op := &Operation{
+		OperationID: fmt.Sprintf("%s_%s", moduleName, actionName),
+		Summary:     fmt.Sprintf("Execute %s/%s", moduleName, actionName),
+		Tags:        []string{moduleName},
+		Parameters: []Parameter{{
+			Name:        "async",
+			In:          "query",
+			Description: "Run the action as a background job and return the job",
+			Schema:      &Schema{Type: "boolean"},
+		}},
+		RequestBody: &RequestBody{
+			Content: map[string]*MediaType{
+				"application/json": {Schema: argumentsSchema(actionSchema)},
+			},
+		},
+		Responses: map[string]*Response{
+			"200": {
+				Description: "Output of the action",
+				Content: map[string]*MediaType{
+					"application/json": {Schema: &Schema{Type: "object"}},
+				},
+			},
+			"default": {
+				Description: "Error",
+				Content: map[string]*MediaType{
+					"application/json": {Schema: &Schema{Ref: errorSchemaRef}},
+				},
+			},
+		},
+	}
+
+	for _, f := range actionSchema.Arguments {
+		if f.Required {
+			op.RequestBody.Required = true
+		}
+	}
+
+	return op
+}
+
+// queryOperation describes a read-only action served on GET, taking its arguments as query parameters.
+func queryOperation(moduleName, actionName string, actionSchema schema.Schema) *Operation {
+	op := operation(moduleName, actionName, actionSchema)
+	op.OperationID = fmt.Sprintf("%s_%s_get", moduleName, actionName)
+	op.RequestBody = nil
+
+	for _, f := range actionSchema.Arguments {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        f.Name,
+			In:          "query",
+			Description: f.Description,
+			Required:    f.Required,
+			Schema: &Schema{
+				Type:    jsonType(f.Type),
+				Enum:    f.Enum,
+				Default: f.Default,
+			},
+		})
+	}
+
+	return op
+}
+
+func argumentsSchema(actionSchema schema.Schema) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: true,
+	}
+
+	for _, f := range actionSchema.Arguments {
+		s.Properties[f.Name] = &Schema{
+			Type:        jsonType(f.Type),
+			Description: f.Description,
+			Enum:        f.Enum,
+			Default:     f.Default,
+		}
+		if f.Required {
+			s.Required = append(s.Required, f.Name)
+		}
+	}
+
+	return s
+}
+
+// jsonType returns the JSON schema type of an argument type.
+// Arguments of any type are left untyped.
+func jsonType(t schema.Type) string {
+	if t == schema.TypeAny {
+		return ""
+	}
+	return string(t)
+}
+
+func errorSchema() *Schema {
+	return &Schema{
+		Type:     "object",
+		Required: []string{"error"},
+		Properties: map[string]*Schema{
+			"error": {
+				Type:     "object",
+				Required: []string{"code", "message"},
+				Properties: map[string]*Schema{
+					"code": {
+						Type: "string",
+						Enum: []interface{}{
+							apierr.CodeInvalidArgument, apierr.CodeNotFound, apierr.CodeMethodNotAllowed, apierr.CodeConflict,
+							apierr.CodeUnauthenticated, apierr.CodePermissionDenied, apierr.CodeUnavailable,
+							apierr.CodeDeadlineExceeded, apierr.CodeCanceled, apierr.CodeInternal,
+						},
+					},
+					"message": {Type: "string"},
+					"details": {Type: "object", AdditionalProperties: true},
+				},
+			},
+		},
+	}
+}
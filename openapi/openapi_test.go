@@ -0,0 +1,96 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dalloriam/orc/openapi"
+	"github.com/dalloriam/orc/schema"
+)
+
+var testActions = map[string]map[string]schema.Schema{
+	"keyval": {
+		"get": schema.NewReadOnly(schema.Field{Name: "key", Type: schema.TypeString, Required: true}),
+		"set": schema.New(
+			schema.Field{Name: "key", Type: schema.TypeString, Required: true},
+			schema.Field{Name: "val", Type: schema.TypeAny, Required: true},
+		),
+		"list": schema.New(),
+	},
+	"job": {
+		"list": schema.New(schema.Field{Name: "status", Type: schema.TypeString, Enum: []interface{}{"running", "failed"}}),
+	},
+}
+
+func TestGenerate(t *testing.T) {
+	doc := openapi.Generate(openapi.Options{Title: "ORC", Version: "v1"}, testActions)
+
+	if doc.OpenAPI != "3.0.3" || doc.Info.Version != "v1" {
+		t.Errorf("unexpected document header: %s %v", doc.OpenAPI, doc.Info)
+	}
+
+	if len(doc.Paths) != 4 {
+		t.Errorf("expected 4 paths, got %d", len(doc.Paths))
+	}
+
+	expectedTags := []openapi.Tag{{Name: "job"}, {Name: "keyval"}}
+	if !reflect.DeepEqual(doc.Tags, expectedTags) {
+		t.Errorf("expected tags %v, got %v", expectedTags, doc.Tags)
+	}
+
+	op := doc.Paths["/keyval/set"].Post
+	if op == nil || op.OperationID != "keyval_set" {
+		t.Fatalf("missing operation for /keyval/set")
+	}
+
+	body := op.RequestBody.Content["application/json"].Schema
+	if !op.RequestBody.Required || !reflect.DeepEqual(body.Required, []string{"key", "val"}) {
+		t.Errorf("expected key and val to be required, got %v", body.Required)
+	}
+
+	if body.Properties["key"].Type != "string" || body.Properties["val"].Type != "" {
+		t.Errorf("unexpected property types: %v", body.Properties)
+	}
+
+	if doc.Paths["/keyval/set"].Get != nil {
+		t.Errorf("expected /keyval/set not to be served on GET")
+	}
+
+	get := doc.Paths["/keyval/get"].Get
+	if get == nil || get.RequestBody != nil {
+		t.Fatalf("expected /keyval/get to be served on GET without body, got %v", get)
+	}
+
+	if len(get.Parameters) != 2 || get.Parameters[1].Name != "key" || !get.Parameters[1].Required {
+		t.Errorf("expected key to be a required query parameter, got %v", get.Parameters)
+	}
+
+	if doc.Paths["/keyval/list"].Post.RequestBody.Required {
+		t.Errorf("expected request body of /keyval/list to be optional")
+	}
+
+	if enum := doc.Paths["/job/list"].Post.RequestBody.Content["application/json"].Schema.Properties["status"].Enum; len(enum) != 2 {
+		t.Errorf("expected enum to be exposed, got %v", enum)
+	}
+
+	if doc.Security != nil {
+		t.Errorf("expected no security requirement when authentication is disabled")
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Errorf("document cannot be encoded: %s", err.Error())
+	}
+}
+
+func TestGenerate_Authenticated(t *testing.T) {
+	doc := openapi.Generate(openapi.Options{Title: "ORC", Version: "v1", Authenticated: true}, testActions)
+
+	if doc.Components.SecuritySchemes["bearer"] == nil {
+		t.Errorf("expected bearer security scheme")
+	}
+
+	if len(doc.Security) != 1 {
+		t.Errorf("expected a global security requirement, got %v", doc.Security)
+	}
+}
@@ -1,38 +1,128 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os/exec"
 	"path"
-
+	"sync"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/config"
+	"github.com/dalloriam/orc/dashboard"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/hook"
+	"github.com/dalloriam/orc/interfaces"
+	"github.com/dalloriam/orc/job"
 	"github.com/dalloriam/orc/keyval"
+	"github.com/dalloriam/orc/logs"
 	"github.com/dalloriam/orc/management"
+	"github.com/dalloriam/orc/metrics"
+	"github.com/dalloriam/orc/openapi"
 	"github.com/dalloriam/orc/plugins"
+	"github.com/dalloriam/orc/registry"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/schema"
+	"github.com/dalloriam/orc/supervisor"
 	"github.com/dalloriam/orc/task"
+	"github.com/dalloriam/orc/tracing"
 	"github.com/dalloriam/orc/version"
+	"github.com/dalloriam/orc/webhook"
+	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
 )
 
-type registrarFunc func(string, string, func(string, map[string]interface{}) ([]byte, error))
+const (
+	keyvalStateFile   = "keyval.json"
+	registryStateFile = "registry.json"
+
+	// webhookDeadLetterFile logs the webhook deliveries that failed for good.
+	webhookDeadLetterFile = "webhook-dead-letters.jsonl"
+
+	metricsPath = "/metrics"
+)
 
 // Orc is the root orchestrator component.
 type Orc struct {
-	taskDirectory   string
-	pluginDirectory string
+	cfg *config.Config
+
+	server *interfaces.Server
+
+	// events dispatches what happens on the server to the subscribers of the event stream.
+	events *events.Bus
+
+	// logs streams the server logs to the gRPC clients.
+	logs *logs.Broadcaster
 
-	registrar registrarFunc
+	// hooks invokes actions on behalf of the inbound hooks of the configuration.
+	hooks *hook.Handler
+
+	// webhooks posts the events to the webhooks of the configuration.
+	webhooks *webhook.Dispatcher
+
+	// supervisor recovers the panics of the modules, and disables those that keep panicking.
+	supervisor *supervisor.Supervisor
+
+	mu            sync.RWMutex
+	modules       map[string]Module
+	builtins      []Module
+	managementMod *management.Module
+	jobs          *job.Manager
+
+	servers []*http.Server
+
+	// standalone is set when the actions are run by "orc run" rather than served.
+	standalone bool
 }
 
 // New initializes the component according to config.
-func New(taskDefinitionDirectory, pluginDirectory string, actionRegistrar registrarFunc) (*Orc, error) {
+// The actions of the modules are served by the server.
+func New(cfg *config.Config, server *interfaces.Server) (*Orc, error) {
+	return newOrc(cfg, server, false)
+}
+
+// NewStandalone initializes the component to run actions in-process, for "orc run".
+// The task images are not pulled, and the tasks of a running daemon are neither
+// managed nor stopped on shutdown. The HTTP routes, inbound hooks and webhooks of
+// the configuration are left out.
+func NewStandalone(cfg *config.Config) (*Orc, error) {
+	return newOrc(cfg, interfaces.NewServer(nil), true)
+}
+
+func newOrc(cfg *config.Config, server *interfaces.Server, standalone bool) (*Orc, error) {
 	log.Infof("[ORC %s @ %s]", version.VERSION, version.GITCOMMIT)
 	o := &Orc{
-		registrar:       actionRegistrar,
-		taskDirectory:   taskDefinitionDirectory,
-		pluginDirectory: pluginDirectory,
+		cfg:        cfg,
+		server:     server,
+		events:     events.NewBus(),
+		logs:       logs.NewBroadcaster(),
+		standalone: standalone,
+	}
+
+	// "orc run" never listens, so it serves no routes and runs no inbound hooks.
+	if !standalone {
+		server.SetCORSOrigins(cfg.CORSOrigins)
+		server.HandleFunc("/", o.healthCheck)
+		server.HandleFunc(openapi.SpecPath, o.openAPISpec)
+		server.HandleFunc(openapi.ExplorerPath, o.explorer)
+		server.HandleFunc(dashboard.Path, o.dashboard)
+		server.Handle(metricsPath, metrics.Handler())
+		server.Handle(events.Path, server.EventsHandler(o.events))
+		if cfg.GRPC {
+			server.EnableGRPC(o.events, o.logs)
+		}
+
+		o.hooks = hook.NewHandler(o.invoke)
+		if err := o.hooks.SetHooks(cfg.Hooks); err != nil {
+			return nil, err
+		}
+		server.Handle(hook.Path, o.hooks)
 	}
 
 	if err := o.initModules(); err != nil {
@@ -42,44 +132,378 @@ func New(taskDefinitionDirectory, pluginDirectory string, actionRegistrar regist
 	return o, nil
 }
 
+// Handler returns the HTTP handler serving ORC.
+func (o *Orc) Handler() http.Handler {
+	return o.server
+}
+
+// Logs returns the broadcaster streaming the logs to the gRPC clients.
+// It receives the entries of the loggers it is added to as a hook.
+func (o *Orc) Logs() *logs.Broadcaster {
+	return o.logs
+}
+
 func (o *Orc) initModules() error {
 	log.Info("looking for modules...")
-	taskMod, err := task.NewController(o.taskDirectory, true)
+	policy, err := supervisionPolicy(o.cfg)
 	if err != nil {
 		return err
 	}
+	o.supervisor = supervisor.New(policy)
 
-	managementMod := management.NewModule()
+	var taskSettings task.Settings
+	if err := mapstructure.Decode(o.cfg.ModuleSettings(task.ModuleName), &taskSettings); err != nil {
+		return fmt.Errorf("invalid %s module settings: %s", task.ModuleName, err.Error())
+	}
+
+	initializeTasks := !taskSettings.SkipInitialization && !o.standalone
+	taskMod, err := task.NewController(o.cfg.TaskDirectory, initializeTasks, !o.standalone)
+	if err != nil {
+		return err
+	}
+	taskMod.StopTasksOnClose = taskSettings.StopOnExit && !o.standalone
+	taskMod.Supervisor = o.supervisor
+	taskMod.Events = o.events
+
+	o.managementMod = management.NewModule()
+	o.managementMod.Supervisor = o.supervisor
+
+	jobRetention, err := o.cfg.JobRetentionPeriod()
+	if err != nil {
+		return err
+	}
+	o.jobs = job.NewManager(jobRetention)
+	o.jobs.Events = o.events
+
+	keyValMod, err := keyval.NewPersistentModule(path.Join(o.cfg.StateDirectory, keyvalStateFile))
+	if err != nil {
+		return err
+	}
+	keyValMod.Events = o.events
 
-	keyValMod := keyval.NewModule()
+	var registrySettings registry.Settings
+	if err := mapstructure.Decode(o.cfg.ModuleSettings(registry.ModuleName), &registrySettings); err != nil {
+		return fmt.Errorf("invalid %s module settings: %s", registry.ModuleName, err.Error())
+	}
 
-	modules := []Module{taskMod, managementMod, keyValMod}
+	registryMod, err := registry.NewPersistentModule(registrySettings, path.Join(o.cfg.StateDirectory, registryStateFile))
+	if err != nil {
+		return err
+	}
+	registryMod.Events = o.events
+	registryMod.Watch(o.events)
+
+	// The tasks hooked into at startup publish no task/started event. "orc run" hooks
+	// into no task, its copy of the services of the tasks is left as is.
+	if !o.standalone {
+		registryMod.Reconcile(context.Background(), taskMod.RunningTaskPorts())
+	}
+
+	o.builtins = []Module{taskMod, o.managementMod, keyValMod, registryMod, o.jobs}
+
+	// The webhooks of the daemon are left out of "orc run", whose local or CI runs
+	// would otherwise be posted to them.
+	if !o.standalone {
+		o.webhooks = webhook.NewDispatcher(o.events, path.Join(o.cfg.StateDirectory, webhookDeadLetterFile))
+		if err := o.webhooks.SetRules(o.cfg.Webhooks); err != nil {
+			return err
+		}
+	}
 
 	plugins, err := o.loadPlugins()
 	if err != nil {
 		return err
 	}
 
-	modules = append(modules, plugins...)
+	o.registerModules(append(o.builtins, plugins...))
+
+	return nil
+}
+
+// Reload applies a new configuration, reloading the task definitions and the plugins.
+// Modules disabled after repeated panics are enabled again.
+func (o *Orc) Reload(cfg *config.Config) error {
+	jobRetention, err := cfg.JobRetentionPeriod()
+	if err != nil {
+		return err
+	}
+
+	policy, err := supervisionPolicy(cfg)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	o.cfg = cfg
+	o.mu.Unlock()
+
+	o.server.SetCORSOrigins(cfg.CORSOrigins)
+	o.jobs.SetRetention(jobRetention)
+	if o.webhooks != nil {
+		if err := o.webhooks.SetRules(cfg.Webhooks); err != nil {
+			return err
+		}
+	}
+	if o.hooks != nil {
+		if err := o.hooks.SetHooks(cfg.Hooks); err != nil {
+			return err
+		}
+	}
+
+	for _, mod := range o.builtins {
+		if reloader, ok := mod.(Reloader); ok {
+			if err := reloader.Reload(); err != nil {
+				return fmt.Errorf("error reloading module %s: %s", mod.Name(), err.Error())
+			}
+		}
+	}
+
+	plugins, err := o.loadPlugins()
+	if err != nil {
+		return err
+	}
+
+	o.registerModules(append(o.builtins, plugins...))
+
+	o.supervisor.SetPolicy(policy)
+	o.supervisor.Reset()
+
+	return nil
+}
+
+func supervisionPolicy(cfg *config.Config) (supervisor.Policy, error) {
+	window, err := cfg.PanicWindowPeriod()
+	if err != nil {
+		return supervisor.Policy{}, err
+	}
+
+	return supervisor.Policy{MaxPanics: cfg.Supervision.MaxPanics, Window: window}, nil
+}
+
+// registerModules serves the actions of the modules.
+// Modules that were previously registered and are no longer part of the set are removed.
+func (o *Orc) registerModules(modules []Module) {
+	moduleMap := make(map[string]Module)
+	o.managementMod.Reset()
 
 	for _, mod := range modules {
 		n := mod.Name()
+		moduleMap[n] = mod
 
+		var actions []interfaces.Action
 		for _, act := range mod.Actions() {
-			o.registrar(n, act, mod.Execute)
-			managementMod.RegisterAction(n, act)
+			actions = append(actions, interfaces.Action{
+				Module:  n,
+				Name:    act,
+				Schema:  actionSchema(mod, act),
+				Routes:  actionRoutes(mod, act),
+				Execute: o.executor(n),
+			})
+			o.managementMod.RegisterAction(n, act)
+			o.managementMod.SetSchema(n, act, actionSchema(mod, act))
+		}
+		if _, ok := mod.(*plugins.PluginManifest); ok {
+			o.managementMod.SetKind(n, management.KindPlugin)
+		}
+		if isStateful(mod) {
+			o.managementMod.SetStateful(n)
+		}
+		o.server.RegisterModule(n, actions)
+	}
+
+	o.mu.Lock()
+	previous := o.modules
+	o.modules = moduleMap
+	o.mu.Unlock()
+
+	for name := range previous {
+		if _, ok := moduleMap[name]; !ok {
+			log.Infof("module removed: %s", name)
+			o.server.RemoveModule(name)
+			o.events.Publish(context.Background(), events.TopicModuleRemoved, map[string]interface{}{"module": name})
 		}
 	}
 
 	log.Infof("module loading complete: %d modules active", len(modules))
+}
+
+// executor dispatches actions to the module currently registered under moduleName,
+// so that reloaded modules replace their previous version.
+// Actions are submitted as jobs when requested by the caller or declared async by the module.
+// Their panics are recovered by the supervisor, and the actions of disabled modules are rejected.
+func (o *Orc) executor(moduleName string) func(context.Context, string, map[string]interface{}) ([]byte, error) {
+	return func(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+		o.mu.RLock()
+		mod, ok := o.modules[moduleName]
+		cfg := o.cfg
+		o.mu.RUnlock()
+
+		if !ok {
+			return nil, apierr.NotFound("unknown module: %s", moduleName)
+		}
+
+		if err := o.supervisor.Check(moduleName); err != nil {
+			return nil, err
+		}
+
+		// Validate before submitting jobs, so that invalid payloads are rejected right away.
+		data, err := actionSchema(mod, actionName).Validate(data)
+		if err != nil {
+			return nil, err
+		}
 
+		// Jobs would be lost when "orc run" exits, so it runs the actions right away.
+		async := moduleName != job.ModuleName && !o.standalone && (request.Async(ctx) || isAsync(mod, actionName))
+
+		readOnly := actionSchema(mod, actionName).ReadOnly
+		run := func(ctx context.Context) ([]byte, error) {
+			started := time.Now()
+			if !readOnly {
+				o.events.Publish(ctx, events.TopicActionInvoked, map[string]interface{}{
+					"module": moduleName,
+					"action": actionName,
+					"caller": request.Caller(ctx),
+				})
+			}
+
+			out, err := o.supervisor.Call(ctx, moduleName, func() ([]byte, error) {
+				return execute(ctx, cfg, mod, actionName, data, async)
+			})
+
+			// Read-only actions are left out of the history and events, which would
+			// otherwise be flooded by the polling of the dashboard.
+			if !readOnly {
+				o.recordRun(ctx, moduleName, actionName, started, err)
+			}
+			return out, err
+		}
+
+		if async {
+			j := o.jobs.Submit(ctx, moduleName, actionName, run)
+
+			snapshot := o.jobs.Snapshot(j)
+			return json.Marshal(map[string]interface{}{"job": &snapshot})
+		}
+
+		return run(ctx)
+	}
+}
+
+// invoke runs an action on behalf of the inbound hooks.
+func (o *Orc) invoke(ctx context.Context, moduleName, actionName string, data map[string]interface{}) ([]byte, error) {
+	return o.executor(moduleName)(ctx, actionName, data)
+}
+
+// recordRun adds a run to the history and announces its completion.
+func (o *Orc) recordRun(ctx context.Context, moduleName, actionName string, started time.Time, err error) {
+	run := management.Run{
+		Module:     moduleName,
+		Action:     actionName,
+		Caller:     request.Caller(ctx),
+		RequestID:  request.ID(ctx),
+		StartedAt:  started.UTC(),
+		DurationMs: float64(time.Since(started)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		run.Error = apierr.From(err)
+	}
+
+	o.managementMod.RecordRun(run)
+
+	data := map[string]interface{}{
+		"module":      moduleName,
+		"action":      actionName,
+		"caller":      run.Caller,
+		"duration_ms": run.DurationMs,
+	}
+	if run.Error != nil {
+		data["error"] = run.Error
+	}
+	o.events.Publish(ctx, events.TopicActionCompleted, data)
+}
+
+// execute runs the action within its timeout, the one of the jobs if async is set.
+func execute(ctx context.Context, cfg *config.Config, mod Module, actionName string, data map[string]interface{}, async bool) ([]byte, error) {
+	timeout, err := actionTimeout(cfg, mod, actionName, async)
+	if err != nil {
+		return nil, apierr.Internal("%s", err.Error())
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ctx, span := tracing.Start(ctx, mod.Name()+"/"+actionName, tracing.KindInternal)
+	span.SetAttribute("orc.module", mod.Name())
+	span.SetAttribute("orc.action", actionName)
+	defer span.End()
+
+	out, err := mod.Execute(ctx, actionName, data)
+	if err != nil {
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			err = apierr.DeadlineExceeded("action %s/%s timed out after %s", mod.Name(), actionName, timeout)
+		case context.Canceled:
+			err = apierr.Canceled("action %s/%s was canceled", mod.Name(), actionName)
+		}
+		span.SetError(err)
+	}
+
+	return out, err
+}
+
+func actionSchema(mod Module, actionName string) schema.Schema {
+	if provider, ok := mod.(SchemaProvider); ok {
+		return provider.ActionSchema(actionName)
+	}
+	return schema.New()
+}
+
+func actionRoutes(mod Module, actionName string) []schema.Route {
+	if provider, ok := mod.(RouteProvider); ok {
+		return provider.ActionRoutes(actionName)
+	}
 	return nil
 }
 
+func isStateful(mod Module) bool {
+	holder, ok := mod.(StateHolder)
+	return ok && holder.Stateful()
+}
+
+func isAsync(mod Module, actionName string) bool {
+	provider, ok := mod.(AsyncProvider)
+	return ok && provider.ActionAsync(actionName)
+}
+
+// actionTimeout returns the timeout of an action. Timeouts set in the configuration
+// take precedence over the module defaults, which take precedence over the global
+// default: the job timeout for the actions running as background jobs, the action
+// timeout for the others.
+func actionTimeout(cfg *config.Config, mod Module, actionName string, async bool) (time.Duration, error) {
+	timeout, ok, err := cfg.TimeoutOverride(mod.Name(), actionName)
+	if err != nil || ok {
+		return timeout, err
+	}
+
+	if provider, ok := mod.(TimeoutProvider); ok {
+		if timeout := provider.ActionTimeout(actionName); timeout > 0 {
+			return timeout, nil
+		}
+	}
+
+	if async {
+		return cfg.DefaultJobTimeout()
+	}
+	return cfg.DefaultTimeout()
+}
+
 func (o *Orc) registerPlugin(pluginFile string) (Module, error) {
 	// Fetch the manifest from the executable.
 	cmd := exec.Command(pluginFile, "manifest")
-	cmd.Dir = o.pluginDirectory
+	cmd.Dir = o.cfg.PluginDirectory
 
 	rawData, err := cmd.CombinedOutput()
 	if err != nil {
@@ -91,15 +515,19 @@ func (o *Orc) registerPlugin(pluginFile string) (Module, error) {
 		return nil, err
 	}
 
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest for plugin %s: %s", manifest.Name(), err.Error())
+	}
+
 	if manifest.Init.Command != "" {
 		log.Infof("executing init command for plugin: %s", manifest.Name())
-		if _, err := manifest.Init.Execute(nil); err != nil {
+		if _, err := manifest.Init.Execute(context.Background(), nil); err != nil {
 			return nil, err
 		}
 	}
 
 	for actionName, action := range manifest.ActionMap {
-		action.PluginDir = o.pluginDirectory
+		action.PluginDir = o.cfg.PluginDirectory
 		manifest.ActionMap[actionName] = action
 	}
 
@@ -110,7 +538,7 @@ func (o *Orc) registerPlugin(pluginFile string) (Module, error) {
 
 func (o *Orc) loadPlugins() ([]Module, error) {
 	log.Info("looking for plugins...")
-	files, err := ioutil.ReadDir(o.pluginDirectory)
+	files, err := ioutil.ReadDir(o.cfg.PluginDirectory)
 	if err != nil {
 		return nil, err
 	}
@@ -118,7 +546,7 @@ func (o *Orc) loadPlugins() ([]Module, error) {
 	var modules []Module
 
 	for _, f := range files {
-		pluginPath := path.Join(o.pluginDirectory, f.Name())
+		pluginPath := path.Join(o.cfg.PluginDirectory, f.Name())
 		log.Infof("found plugin: %s", pluginPath)
 
 		mod, err := o.registerPlugin(pluginPath)
@@ -128,6 +556,10 @@ func (o *Orc) loadPlugins() ([]Module, error) {
 		}
 
 		modules = append(modules, mod)
+		o.events.Publish(context.Background(), events.TopicPluginLoaded, map[string]interface{}{
+			"plugin":  mod.Name(),
+			"actions": mod.Actions(),
+		})
 	}
 
 	log.Infof("plugin search complete: %d plugins loaded", len(modules))
@@ -136,17 +568,167 @@ func (o *Orc) loadPlugins() ([]Module, error) {
 }
 
 func (o *Orc) healthCheck(w http.ResponseWriter, r *http.Request) {
+	// The health check is registered on "/", which also catches unknown routes.
+	if r.URL.Path != "/" {
+		apierr.Write(w, apierr.NotFound("unknown route: %s", r.URL.Path))
+		return
+	}
+
 	w.Header().Add("Content-Type", "application/json")
 
-	x, _ := json.Marshal(map[string]string{"health": "OK"})
+	health := map[string]interface{}{"health": "OK"}
+	if disabled := o.supervisor.DisabledModules(); len(disabled) > 0 {
+		health["disabled_modules"] = disabled
+	}
+
+	x, _ := json.Marshal(health)
 
 	w.Write(x)
 }
 
-// Serve starts the ORC server on the specified host & port.
-func (o *Orc) Serve(host string, port int) error {
-	addr := fmt.Sprintf("%s:%d", host, port)
-	log.Infof("ORC listening on %s", addr)
-	http.HandleFunc("/", o.healthCheck)
-	return http.ListenAndServe(addr, nil)
+// openAPISpec serves the OpenAPI document of the registered actions.
+func (o *Orc) openAPISpec(w http.ResponseWriter, r *http.Request) {
+	o.mu.RLock()
+	cfg := o.cfg
+	o.mu.RUnlock()
+
+	tokens, err := cfg.Tokens()
+	if err != nil {
+		apierr.Write(w, apierr.Internal("%s", err.Error()))
+		return
+	}
+
+	doc := openapi.Generate(openapi.Options{
+		Title:         "ORC",
+		Version:       version.VERSION,
+		Authenticated: len(tokens) > 0,
+	}, o.managementMod.Schemas())
+
+	w.Header().Add("Content-Type", "application/json")
+	x, _ := json.Marshal(doc)
+	w.Write(x)
+}
+
+func (o *Orc) explorer(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != openapi.ExplorerPath {
+		apierr.Write(w, apierr.NotFound("unknown route: %s", r.URL.Path))
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(openapi.ExplorerHTML))
+}
+
+func (o *Orc) dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != dashboard.Path {
+		apierr.Write(w, apierr.NotFound("unknown route: %s", r.URL.Path))
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboard.HTML))
+}
+
+// Serve starts the ORC server on the specified listeners.
+// It blocks until one of the listeners fails or the server is shut down.
+func (o *Orc) Serve(listeners []interfaces.Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("no listeners configured")
+	}
+
+	var opened []net.Listener
+	for _, l := range listeners {
+		ln, err := l.Listen()
+		if err != nil {
+			for _, openedLn := range opened {
+				openedLn.Close()
+			}
+			return fmt.Errorf("error opening listener %s: %s", l, err.Error())
+		}
+		opened = append(opened, ln)
+	}
+
+	errChan := make(chan error, len(opened))
+
+	o.mu.Lock()
+	for i, ln := range opened {
+		srv := &http.Server{Handler: o.server.ListenerHandler(listeners[i])}
+		o.servers = append(o.servers, srv)
+
+		log.Infof("ORC listening on %s", listeners[i])
+		go func(ln net.Listener) {
+			errChan <- srv.Serve(ln)
+		}(ln)
+	}
+	o.mu.Unlock()
+
+	for range opened {
+		if err := <-errChan; err != http.ErrServerClosed {
+			// The other listeners would keep serving with nothing left to stop them.
+			o.mu.RLock()
+			for _, srv := range o.servers {
+				srv.Close()
+			}
+			o.mu.RUnlock()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Shutdown gracefully stops the server. In-flight requests are drained until
+// the context expires, then the modules are closed.
+func (o *Orc) Shutdown(ctx context.Context) error {
+	o.mu.RLock()
+	servers := o.servers
+	modules := o.modules
+	o.mu.RUnlock()
+
+	var firstErr error
+
+	// Event streams never complete on their own.
+	o.server.CloseStreams()
+
+	log.Info("draining in-flight requests...")
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Running jobs are canceled first, as they may still use the other modules.
+	if err := o.jobs.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+
+	for name, mod := range modules {
+		if name == job.ModuleName {
+			continue
+		}
+
+		closer, ok := mod.(Closer)
+		if !ok {
+			continue
+		}
+
+		log.Infof("closing module: %s", name)
+		if err := closer.Close(); err != nil {
+			log.Errorf("error closing module %s: %s", name, err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	// The webhooks get the events published while closing the modules.
+	if o.webhooks != nil {
+		if err := o.webhooks.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	o.events.Close()
+	o.logs.Close()
+
+	return firstErr
 }
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/client"
+	"github.com/dalloriam/orc/config"
+	"github.com/dalloriam/orc/dashboard"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/hook"
+	"github.com/dalloriam/orc/interfaces"
+	"github.com/dalloriam/orc/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logrus.SetLevel(logrus.PanicLevel)
+}
+
+// newTestOrc starts ORC on a test server, with empty task and plugin directories.
+func newTestOrc(t *testing.T) (*Orc, *httptest.Server, func()) {
+	homeDir, err := ioutil.TempDir("", "orc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default(homeDir)
+	for _, dir := range []string{cfg.TaskDirectory, cfg.PluginDirectory, cfg.StateDirectory} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	o, err := New(cfg, interfaces.NewServer(nil))
+	if err != nil {
+		os.RemoveAll(homeDir)
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(o.Handler())
+
+	return o, srv, func() {
+		srv.Close()
+		os.RemoveAll(homeDir)
+	}
+}
+
+func call(t *testing.T, method, url, body string) (int, map[string]interface{}) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp.StatusCode, decoded
+}
+
+func TestOrc_Serve(t *testing.T) {
+	_, srv, cleanup := newTestOrc(t)
+	defer cleanup()
+
+	if status, body := call(t, http.MethodGet, srv.URL+"/", ""); status != http.StatusOK || body["health"] != "OK" {
+		t.Errorf("unexpected health check: %d %v", status, body)
+	}
+
+	if status, _ := call(t, http.MethodPut, srv.URL+"/keyval/keys/greeting", `"hello"`); status != http.StatusOK {
+		t.Errorf("expected key to be set, got %d", status)
+	}
+
+	if status, body := call(t, http.MethodGet, srv.URL+"/keyval/keys/greeting", ""); status != http.StatusOK || body["value"] != "hello" {
+		t.Errorf("unexpected value: %d %v", status, body)
+	}
+
+	// Keys named after actions are reachable.
+	call(t, http.MethodPut, srv.URL+"/keyval/keys/list", `"not an action"`)
+	if status, body := call(t, http.MethodGet, srv.URL+"/keyval/keys/list", ""); status != http.StatusOK || body["value"] != "not an action" {
+		t.Errorf("unexpected value of the list key: %d %v", status, body)
+	}
+
+	// Services named after actions are reachable.
+	if status, _ := call(t, http.MethodPost, srv.URL+"/registry/register", `{"name": "list", "lan": "192.168.1.20"}`); status != http.StatusOK {
+		t.Errorf("expected service to be registered, got %d", status)
+	}
+	if status, body := call(t, http.MethodGet, srv.URL+"/registry/services/list", ""); status != http.StatusOK || body["address"] != "192.168.1.20" {
+		t.Errorf("unexpected resolution of the list service: %d %v", status, body)
+	}
+	if status, _ := call(t, http.MethodDelete, srv.URL+"/registry/services/list", ""); status != http.StatusOK {
+		t.Errorf("expected service to be deregistered, got %d", status)
+	}
+
+	if status, _ := call(t, http.MethodGet, srv.URL+"/keyval/set", ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("expected mutating action to reject GET, got %d", status)
+	}
+
+	if status, _ := call(t, http.MethodPost, srv.URL+"/nope/nothing", ""); status != http.StatusNotFound {
+		t.Errorf("expected unknown module to be not found, got %d", status)
+	}
+}
+
+func TestOrc_Independent(t *testing.T) {
+	_, first, cleanupFirst := newTestOrc(t)
+	defer cleanupFirst()
+
+	_, second, cleanupSecond := newTestOrc(t)
+	defer cleanupSecond()
+
+	call(t, http.MethodPut, first.URL+"/keyval/keys/owner", `"first"`)
+
+	if status, _ := call(t, http.MethodGet, second.URL+"/keyval/keys/owner", ""); status != http.StatusNotFound {
+		t.Errorf("expected instances not to share state, got %d", status)
+	}
+}
+
+type panickingModule struct{}
+
+func (panickingModule) Name() string      { return "panicky" }
+func (panickingModule) Actions() []string { return []string{"boom"} }
+
+func (panickingModule) Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+	panic("boom")
+}
+
+func TestOrc_Supervision(t *testing.T) {
+	o, srv, cleanup := newTestOrc(t)
+	defer cleanup()
+
+	o.registerModules(append(o.builtins, panickingModule{}))
+
+	for i := 0; i < config.DefaultMaxPanics; i++ {
+		if status, _ := call(t, http.MethodPost, srv.URL+"/panicky/boom", ""); status != http.StatusInternalServerError {
+			t.Fatalf("expected panic to be an internal error, got %d", status)
+		}
+	}
+
+	if status, _ := call(t, http.MethodPost, srv.URL+"/panicky/boom", ""); status != http.StatusServiceUnavailable {
+		t.Errorf("expected module to be disabled, got %d", status)
+	}
+
+	if _, body := call(t, http.MethodGet, srv.URL+"/", ""); body["disabled_modules"] == nil {
+		t.Errorf("expected health check to report the disabled module, got %v", body)
+	}
+
+	if status, _ := call(t, http.MethodGet, srv.URL+"/keyval/list", ""); status != http.StatusOK {
+		t.Errorf("expected other modules to keep working, got %d", status)
+	}
+}
+
+// slowModule runs its single action as a background job.
+type slowModule struct{}
+
+func (slowModule) Name() string                 { return "slow" }
+func (slowModule) Actions() []string            { return []string{"sleep"} }
+func (slowModule) ActionAsync(name string) bool { return true }
+
+func (slowModule) Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+	select {
+	case <-time.After(100 * time.Millisecond):
+		return []byte(`{"message": "OK"}`), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestOrc_JobTimeout(t *testing.T) {
+	o, srv, cleanup := newTestOrc(t)
+	defer cleanup()
+
+	o.cfg.ActionTimeout = "20ms"
+	o.registerModules(append(o.builtins, slowModule{}))
+
+	c, err := client.New(client.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Jobs outlive the action timeout, the job timeout applies instead.
+	for _, tCase := range []struct {
+		jobTimeout string
+		wantErr    bool
+	}{{"0", false}, {"50ms", true}} {
+		o.cfg.JobTimeout = tCase.jobTimeout
+
+		out, err := c.Call("slow", "sleep", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var submitted struct {
+			Job struct {
+				ID string `json:"id"`
+			} `json:"job"`
+		}
+		if err := json.Unmarshal(out, &submitted); err != nil || submitted.Job.ID == "" {
+			t.Fatalf("expected a job, got %s", out)
+		}
+
+		_, err = c.Wait(context.Background(), submitted.Job.ID, 10*time.Millisecond)
+		if (err != nil) != tCase.wantErr {
+			t.Errorf("job timeout %s: expected error %v, got %v", tCase.jobTimeout, tCase.wantErr, err)
+		}
+	}
+}
+
+func TestOrc_Dashboard(t *testing.T) {
+	_, srv, cleanup := newTestOrc(t)
+	defer cleanup()
+
+	resp, err := http.Get(srv.URL + dashboard.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("expected the dashboard page, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// The panels of the dashboard are fed by read-only actions.
+	for _, path := range []string{"/manage/modules", "/task/list", "/keyval/list", "/manage/history", "/job/list"} {
+		if status, _ := call(t, http.MethodGet, srv.URL+path, ""); status != http.StatusOK {
+			t.Errorf("expected GET %s to succeed, got %d", path, status)
+		}
+	}
+
+	call(t, http.MethodPut, srv.URL+"/keyval/keys/greeting", `"hello"`)
+	call(t, http.MethodGet, srv.URL+"/keyval/keys/greeting", "")
+
+	_, body := call(t, http.MethodGet, srv.URL+"/manage/history", "")
+	runs, _ := body["runs"].([]interface{})
+	if len(runs) != 1 || runs[0].(map[string]interface{})["action"] != "set" {
+		t.Errorf("expected only the mutating action in the history, got %v", runs)
+	}
+}
+
+func TestOrc_Events(t *testing.T) {
+	_, srv, cleanup := newTestOrc(t)
+	defer cleanup()
+
+	c, err := client.New(client.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan events.Event, 10)
+	go c.Events(ctx, []string{"keyval", "action/completed"}, 0, func(evt events.Event) error {
+		received <- evt
+		return nil
+	})
+
+	// The key is set once the stream is established.
+	for i := 0; ; i++ {
+		call(t, http.MethodPut, srv.URL+"/keyval/keys/greeting", `"hello"`)
+
+		select {
+		case evt := <-received:
+			if evt.Topic != events.TopicKeySet || evt.Data["key"] != "greeting" || evt.RequestID == "" {
+				t.Errorf("unexpected event: %+v", evt)
+			}
+			if evt := <-received; evt.Topic != events.TopicActionCompleted || evt.Data["action"] != "set" {
+				t.Errorf("unexpected event: %+v", evt)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+			if i == 20 {
+				t.Fatalf("no event received")
+			}
+		}
+	}
+}
+
+func TestOrc_Hooks(t *testing.T) {
+	o, srv, cleanup := newTestOrc(t)
+	defer cleanup()
+
+	err := o.hooks.SetHooks([]hook.Hook{{
+		Name:      "push",
+		Module:    "keyval",
+		Action:    "set",
+		Secret:    "hunter2",
+		Filter:    map[string]interface{}{"ref": "refs/heads/main"},
+		Arguments: map[string]interface{}{"key": "last_commit"},
+		Mappings:  map[string]string{"val": "head_commit.id"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(payload, signature string) int {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/hooks/push", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(webhook.SignatureHeader, signature)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	payload := `{"ref": "refs/heads/main", "head_commit": {"id": "abc123"}}`
+	if status := post(payload, webhook.Sign("wrong", []byte(payload))); status != http.StatusUnauthorized {
+		t.Errorf("expected bad signature to be rejected, got %d", status)
+	}
+
+	if status := post(payload, webhook.Sign("hunter2", []byte(payload))); status != http.StatusOK {
+		t.Errorf("expected hook to be triggered, got %d", status)
+	}
+
+	if status, body := call(t, http.MethodGet, srv.URL+"/keyval/keys/last_commit", ""); status != http.StatusOK || body["value"] != "abc123" {
+		t.Errorf("unexpected value: %d %v", status, body)
+	}
+}
+
+func TestOrc_TaskHook(t *testing.T) {
+	type testCase struct {
+		name      string
+		arguments map[string]interface{}
+
+		expectedStatus int
+	}
+
+	cases := []testCase{
+		// The task directory is empty, the arguments are valid but the task is unknown.
+		{"documented arguments", map[string]interface{}{"name": "deploy"}, http.StatusNotFound},
+		{"missing task name", map[string]interface{}{"task_name": "deploy"}, http.StatusBadRequest},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			o, srv, cleanup := newTestOrc(t)
+			defer cleanup()
+
+			err := o.hooks.SetHooks([]hook.Hook{{
+				Name:      "deploy",
+				Module:    "task",
+				Action:    "start",
+				Secret:    "hunter2",
+				Signature: hook.SignatureGitHub,
+				Arguments: tCase.arguments,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload := `{"ref": "refs/heads/main"}`
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/hooks/deploy", strings.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Hub-Signature-256", webhook.Sign("hunter2", []byte(payload)))
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tCase.expectedStatus {
+				t.Errorf("expected status %d, got %d", tCase.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package output renders the results of the actions for the command line clients.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dalloriam/orc/hook"
+)
+
+// Output formats of the results.
+const (
+	FormatPretty = "pretty"
+	FormatJSON   = "json"
+	FormatYAML   = "yaml"
+	FormatTable  = "table"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatJSON, FormatPretty, FormatTable, FormatYAML}
+
+// Valid returns whether the output format is supported.
+func Valid(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Write renders a result in the output format. Results that can't be shown as a
+// table are rendered as pretty JSON.
+func Write(w io.Writer, format string, result interface{}) error {
+	switch format {
+	case FormatJSON:
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+
+	case FormatYAML:
+		_, err := fmt.Fprintln(w, strings.Join(yamlLines(result), "\n"))
+		return err
+
+	case FormatTable:
+		if header, rows, ok := table(result); ok {
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+			for _, row := range rows {
+				fmt.Fprintln(tw, strings.Join(row, "\t"))
+			}
+			return tw.Flush()
+		}
+	}
+
+	if str, ok := result.(string); ok {
+		_, err := fmt.Fprintln(w, str)
+		return err
+	}
+
+	data, err := json.MarshalIndent(result, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// WriteRaw renders the values of a query one per line: strings as is, and other
+// values as JSON.
+func WriteRaw(w io.Writer, values []interface{}) error {
+	for _, val := range values {
+		if str, ok := val.(string); ok {
+			if _, err := fmt.Fprintln(w, str); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query returns the values at a jq-like path of the result, e.g. ".tasks[0].name".
+// The path "." is the whole result, and [] iterates over the elements of an array,
+// e.g. ".tasks[].name" returns the name of every task.
+func Query(result interface{}, path string) ([]interface{}, error) {
+	if path == "" || (path[0] != '.' && path[0] != '$') {
+		return nil, fmt.Errorf("invalid query: %s, expected a path such as .field[0]", path)
+	}
+
+	values := []interface{}{result}
+	for i, part := range strings.Split(path, "[]") {
+		expr := "$"
+		if rest := strings.TrimPrefix(strings.TrimPrefix(part, "$"), "."); rest != "" {
+			expr += "." + rest
+		}
+
+		var next []interface{}
+		for _, val := range values {
+			if i > 0 {
+				arr, ok := val.([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("cannot iterate over %s in query: %s", kind(val), path)
+				}
+				for _, item := range arr {
+					found, ok := hook.Extract(item, expr)
+					if !ok {
+						return nil, fmt.Errorf("no value at %s", path)
+					}
+					next = append(next, found)
+				}
+				continue
+			}
+
+			found, ok := hook.Extract(val, expr)
+			if !ok {
+				return nil, fmt.Errorf("no value at %s", path)
+			}
+			next = append(next, found)
+		}
+		values = next
+	}
+
+	return values, nil
+}
+
+func kind(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+	return "a number"
+}
+
+// table returns the rows of a result that can be shown as a table: lists, objects
+// of scalars, and objects holding a single list such as {"tasks": [...]}.
+func table(result interface{}) ([]string, [][]string, bool) {
+	if obj, ok := result.(map[string]interface{}); ok && len(obj) == 1 {
+		for _, val := range obj {
+			if list, ok := val.([]interface{}); ok {
+				result = list
+			}
+		}
+	}
+
+	switch v := result.(type) {
+	case []interface{}:
+		columns := make(map[string]bool)
+		for _, item := range v {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				rows := make([][]string, 0, len(v))
+				for _, item := range v {
+					rows = append(rows, []string{cell(item)})
+				}
+				return []string{"value"}, rows, true
+			}
+			for key := range obj {
+				columns[key] = true
+			}
+		}
+
+		header := make([]string, 0, len(columns))
+		for key := range columns {
+			header = append(header, key)
+		}
+		sort.Strings(header)
+
+		rows := make([][]string, 0, len(v))
+		for _, item := range v {
+			obj := item.(map[string]interface{})
+			row := make([]string, len(header))
+			for i, key := range header {
+				if val, ok := obj[key]; ok {
+					row[i] = cell(val)
+				}
+			}
+			rows = append(rows, row)
+		}
+		return header, rows, true
+
+	case map[string]interface{}:
+		rows := make([][]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			rows = append(rows, []string{key, cell(v[key])})
+		}
+		return []string{"key", "value"}, rows, true
+	}
+
+	return nil, nil, false
+}
+
+// cell renders a value in a table, nested values as JSON.
+func cell(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(val)
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,104 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dalloriam/orc/output"
+)
+
+func decode(t *testing.T, data string) interface{} {
+	var val interface{}
+	if err := json.Unmarshal([]byte(data), &val); err != nil {
+		t.Fatal(err)
+	}
+	return val
+}
+
+func TestWrite(t *testing.T) {
+	type testCase struct {
+		name   string
+		format string
+		result string
+
+		expected string
+	}
+
+	cases := []testCase{
+		{"json", output.FormatJSON, `{"b": [1, 2], "a": "x"}`, "{\"a\":\"x\",\"b\":[1,2]}\n"},
+		{"pretty", output.FormatPretty, `{"a": 1}`, "{\n\t\"a\": 1\n}\n"},
+		{"pretty string", output.FormatPretty, `"hello"`, "hello\n"},
+		{
+			"yaml",
+			output.FormatYAML,
+			`{"name": "web", "replicas": 2, "enabled": true, "tags": ["a", "true"], "env": {}, "ports": [{"host": 80, "url": "http://x"}], "note": null}`,
+			"enabled: true\nenv: {}\nname: web\nnote: null\nports:\n  - host: 80\n    url: \"http://x\"\nreplicas: 2\ntags:\n  - a\n  - \"true\"\n",
+		},
+		{"yaml scalar", output.FormatYAML, `"1.5"`, "\"1.5\"\n"},
+		{"table of objects", output.FormatTable, `{"tasks": [{"name": "web", "replicas": 2}, {"name": "db"}]}`, "NAME  REPLICAS\nweb   2\ndb    \n"},
+		{"table of values", output.FormatTable, `["a", {"b": 1}]`, "VALUE\na\n{\"b\":1}\n"},
+		{"table of object", output.FormatTable, `{"key": "k", "value": 3}`, "KEY    VALUE\nkey    k\nvalue  3\n"},
+		{"table fallback", output.FormatTable, `42`, "42\n"},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := output.Write(&buf, tCase.format, decode(t, tCase.result)); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if buf.String() != tCase.expected {
+				t.Errorf("expected %q, got %q", tCase.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	type testCase struct {
+		name string
+		path string
+
+		expected []interface{}
+		wantErr  bool
+	}
+
+	result := decode(t, `{"value": "hello", "tasks": [{"name": "web", "ports": [80]}, {"name": "db", "ports": [5432]}]}`)
+
+	cases := []testCase{
+		{"whole result", ".", []interface{}{result}, false},
+		{"field", ".value", []interface{}{"hello"}, false},
+		{"root prefix", "$.value", []interface{}{"hello"}, false},
+		{"index", ".tasks[1].name", []interface{}{"db"}, false},
+		{"iteration", ".tasks[].name", []interface{}{"web", "db"}, false},
+		{"nested iteration", ".tasks[].ports[]", []interface{}{float64(80), float64(5432)}, false},
+		{"missing field", ".missing", nil, true},
+		{"iteration over object", ".tasks[0][]", nil, true},
+		{"invalid path", "value", nil, true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			actual, err := output.Query(result, tCase.path)
+			if (err != nil) != tCase.wantErr {
+				t.Fatalf("expected error=%v, got %v", tCase.wantErr, err)
+			}
+			if !reflect.DeepEqual(actual, tCase.expected) {
+				t.Errorf("expected %v, got %v", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWriteRaw(t *testing.T) {
+	var buf bytes.Buffer
+	if err := output.WriteRaw(&buf, []interface{}{"web", float64(2), map[string]interface{}{"a": true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "web\n2\n{\"a\":true}\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,99 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLines renders a JSON value as YAML, in block style.
+func yamlLines(val interface{}) []string {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return []string{"{}"}
+		}
+
+		var lines []string
+		for _, key := range sortedKeys(v) {
+			child := v[key]
+			if !yamlBlock(child) {
+				lines = append(lines, yamlString(key)+": "+yamlScalar(child))
+				continue
+			}
+
+			lines = append(lines, yamlString(key)+":")
+			for _, line := range yamlLines(child) {
+				lines = append(lines, "  "+line)
+			}
+		}
+		return lines
+
+	case []interface{}:
+		if len(v) == 0 {
+			return []string{"[]"}
+		}
+
+		var lines []string
+		for _, item := range v {
+			for i, line := range yamlLines(item) {
+				if i == 0 {
+					lines = append(lines, "- "+line)
+				} else {
+					lines = append(lines, "  "+line)
+				}
+			}
+		}
+		return lines
+	}
+
+	return []string{yamlScalar(val)}
+}
+
+// yamlBlock returns whether the value is rendered on its own lines.
+func yamlBlock(val interface{}) bool {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	}
+	return false
+}
+
+func yamlScalar(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return yamlString(v)
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	}
+	// Other numbers, which aren't produced by JSON decoding.
+	return fmt.Sprint(val)
+}
+
+// yamlString quotes the strings that would otherwise be read as another type, or
+// that contain characters with a meaning in YAML.
+func yamlString(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, "\n\t\"'\\#:{}[],&*!|>%@`") || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "?") {
+		return strconv.Quote(s)
+	}
+
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
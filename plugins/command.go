@@ -2,11 +2,17 @@ package plugins
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"os/exec"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/schema"
 )
 
 // CommandType regroups the supported command types.
@@ -25,11 +31,23 @@ type Command struct {
 	Arguments []string    `json:"arguments"`
 	Block     bool        `json:"block"`
 
+	// Timeout is the default timeout of the command, e.g. "5m".
+	Timeout string `json:"timeout,omitempty"`
+
+	// Async runs the command as a background job.
+	Async bool `json:"async,omitempty"`
+
+	// Schema declares the arguments accepted by the command.
+	Schema schema.Schema `json:"schema,omitempty"`
+
 	PluginDir string `json:"plugin_dir,omitempty"`
 }
 
 // Execute executes a shell command and returns the output.
-func (c Command) Execute(userArguments map[string]interface{}) (map[string]interface{}, error) {
+// Blocking commands are killed when the context is done. Shell commands receive the
+// request context through ORC_REQUEST_ID, ORC_CALLER, ORC_DEADLINE and TRACEPARENT.
+// Network commands receive it through the X-Request-ID, X-Orc-Caller and Traceparent headers.
+func (c Command) Execute(ctx context.Context, userArguments map[string]interface{}) (map[string]interface{}, error) {
 
 	if c.Type == Shell {
 		var totalArguments []string
@@ -43,15 +61,16 @@ func (c Command) Execute(userArguments map[string]interface{}) (map[string]inter
 			totalArguments = c.Arguments
 		}
 		cmd := exec.Command(c.Command, totalArguments...)
+		cmd.Env = append(os.Environ(), request.Environ(ctx)...)
 
 		if c.PluginDir != "" {
 			cmd.Dir = c.PluginDir
 		}
 
 		if c.Block {
-			outBytes, err := cmd.CombinedOutput()
+			outBytes, err := combinedOutput(ctx, cmd)
 			if err != nil {
-				return nil, err
+				return nil, commandError(err, outBytes)
 			}
 			var responseData map[string]interface{}
 			if err := json.Unmarshal(outBytes, &responseData); err != nil {
@@ -59,22 +78,38 @@ func (c Command) Execute(userArguments map[string]interface{}) (map[string]inter
 			}
 			return responseData, nil
 		}
-		return nil, cmd.Start()
+		// Background commands outlive the request.
+		if err := cmd.Start(); err != nil {
+			return nil, commandError(err, nil)
+		}
+		return nil, nil
 
 	} else if c.Type == Network {
-		// TODO: Handle error.
-		bodyBytes, _ := json.Marshal(userArguments)
+		bodyBytes, err := json.Marshal(userArguments)
+		if err != nil {
+			return nil, apierr.InvalidArgument("cannot encode arguments: %s", err.Error())
+		}
 
-		resp, err := http.Post(c.Command, "application/json", bytes.NewBuffer(bodyBytes))
+		req, err := http.NewRequest(http.MethodPost, c.Command, bytes.NewBuffer(bodyBytes))
 		if err != nil {
-			return nil, err
+			return nil, apierr.Internal("invalid plugin URL: %s", err.Error())
+		}
+		req.Header.Set("Content-Type", "application/json")
+		request.SetHeaders(ctx, req.Header)
+
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, apierr.Unavailable("plugin unreachable: %s", err.Error())
 		}
+		defer resp.Body.Close()
 
-		// TODO: Handle error.
-		respBody, _ := ioutil.ReadAll(resp.Body)
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, apierr.Unavailable("error reading plugin response: %s", err.Error())
+		}
 
 		if resp.StatusCode != 200 {
-			return nil, errors.New(string(respBody))
+			return nil, apierr.Parse(resp.StatusCode, respBody)
 		}
 
 		var responseData map[string]interface{}
@@ -85,6 +120,28 @@ func (c Command) Execute(userArguments map[string]interface{}) (map[string]inter
 		return responseData, nil
 
 	} else {
-		return nil, errors.New("not implemented")
+		return nil, apierr.Internal("command type not implemented: %s", c.Type)
+	}
+}
+
+// ParseTimeout parses the timeout of the command. A command without timeout returns 0.
+func (c Command) ParseTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.Timeout)
+}
+
+// commandError classifies the error returned when running a shell command.
+func commandError(err error, output []byte) error {
+	if _, ok := err.(*exec.Error); ok {
+		// The executable could not be found or run.
+		return apierr.Unavailable("cannot run plugin command: %s", err.Error())
+	}
+
+	apiErr := apierr.Internal("plugin command failed: %s", err.Error())
+	if len(output) > 0 {
+		apiErr = apiErr.WithDetail("output", string(output))
 	}
+	return apiErr
 }
@@ -0,0 +1,15 @@
+package plugins
+
+import "github.com/dalloriam/orc/metrics"
+
+var (
+	commandDuration = metrics.NewHistogramVec(
+		"orc_plugin_command_duration_seconds", "Time taken to execute plugin commands.",
+		[]float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300}, "plugin", "action",
+	)
+	commandFailures = metrics.NewCounterVec("orc_plugin_command_failures_total", "Number of plugin commands that failed.", "plugin", "action")
+)
+
+func init() {
+	metrics.MustRegister(commandDuration, commandFailures)
+}
@@ -1,8 +1,15 @@
 package plugins
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/schema"
+	"github.com/sirupsen/logrus"
 )
 
 // PluginManifest represents a plugin declaration.
@@ -26,11 +33,50 @@ func (p *PluginManifest) Actions() []string {
 	return actions
 }
 
+// Validate ensures the actions declared by the manifest are usable.
+func (p *PluginManifest) Validate() error {
+	for actionName, action := range p.ActionMap {
+		if _, err := action.ParseTimeout(); err != nil {
+			return fmt.Errorf("invalid timeout for action %s: %s", actionName, err.Error())
+		}
+		if err := action.Schema.Check(); err != nil {
+			return fmt.Errorf("invalid schema for action %s: %s", actionName, err.Error())
+		}
+	}
+	return nil
+}
+
+// ActionTimeout returns the timeout declared by the action, if any.
+func (p *PluginManifest) ActionTimeout(actionName string) time.Duration {
+	timeout, _ := p.ActionMap[actionName].ParseTimeout()
+	return timeout
+}
+
+// ActionSchema returns the arguments declared by the action.
+func (p *PluginManifest) ActionSchema(actionName string) schema.Schema {
+	return p.ActionMap[actionName].Schema
+}
+
+// ActionAsync returns whether the action runs as a background job.
+func (p *PluginManifest) ActionAsync(actionName string) bool {
+	return p.ActionMap[actionName].Async
+}
+
 // Execute executes the plugin.
-func (p *PluginManifest) Execute(actionName string, data map[string]interface{}) ([]byte, error) {
+func (p *PluginManifest) Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
 	if action, ok := p.ActionMap[actionName]; ok {
-		output, err := action.Execute(data)
+		ctxLog := request.Log(ctx).WithFields(logrus.Fields{
+			"plugin": p.PluginName,
+			"action": actionName,
+		})
+		ctxLog.Debugf("running %s command: %s", action.Type, action.Command)
+
+		start := time.Now()
+		output, err := action.Execute(ctx, data)
+		commandDuration.Observe(time.Since(start).Seconds(), p.PluginName, actionName)
 		if err != nil {
+			commandFailures.WithLabelValues(p.PluginName, actionName).Inc()
+			ctxLog.Warnf("plugin command failed: %s", err.Error())
 			return nil, err
 		}
 
@@ -43,5 +89,5 @@ func (p *PluginManifest) Execute(actionName string, data map[string]interface{})
 		}
 		return marshalledBytes, nil
 	}
-	return []byte{}, fmt.Errorf("no such action: %s", actionName)
+	return []byte{}, apierr.NotFound("no such action: %s", actionName)
 }
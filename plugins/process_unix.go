@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// combinedOutput runs the command until it exits or the context is done.
+// The command runs in its own process group, so that the processes it spawned
+// are killed along with it instead of holding its output open.
+func combinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+
+	err := cmd.Wait()
+	return out.Bytes(), err
+}
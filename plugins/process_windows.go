@@ -0,0 +1,32 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+)
+
+// combinedOutput runs the command until it exits or the context is done.
+func combinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+		case <-exited:
+		}
+	}()
+
+	err := cmd.Wait()
+	return out.Bytes(), err
+}
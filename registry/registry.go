@@ -0,0 +1,754 @@
+// Package registry tracks the self-hosted services by name, along with their LAN
+// and WAN addresses, so that clients reach them without DNS: the resolve action
+// returns the address reachable from the network of the caller.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/metrics"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/schema"
+	"github.com/mitchellh/mapstructure"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ModuleName is the name under which the registry module is registered.
+	ModuleName = "registry"
+
+	actionRegister   = "register"
+	actionHeartbeat  = "heartbeat"
+	actionDeregister = "deregister"
+	actionList       = "list"
+	actionResolve    = "resolve"
+
+	// DefaultProbeTimeout bounds the reachability probe of an address.
+	DefaultProbeTimeout = time.Second
+
+	// reapInterval is the frequency at which the services past their TTL are removed.
+	reapInterval = time.Second
+)
+
+// Networks of the addresses of a service.
+const (
+	NetworkLAN = "lan"
+	NetworkWAN = "wan"
+)
+
+// DefaultLocalNetworks are the networks of the callers resolving LAN addresses first.
+var DefaultLocalNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "127.0.0.0/8", "fc00::/7", "fe80::/10", "::1/128"}
+
+var (
+	hostname = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+	servicesRegistered = metrics.NewGaugeVec("orc_registry_services", "Number of services in the registry.")
+)
+
+func init() {
+	metrics.MustRegister(servicesRegistered)
+	servicesRegistered.WithLabelValues().Set(0)
+}
+
+// Settings is the configuration of the registry module.
+type Settings struct {
+	// LANAddress and WANAddress are the addresses of the host, under which the services
+	// of the tasks are registered. The LAN address defaults to the first private
+	// address of the host.
+	LANAddress string `json:"lan_address" mapstructure:"lan_address"`
+	WANAddress string `json:"wan_address" mapstructure:"wan_address"`
+
+	// LocalNetworks are the networks of the callers resolving LAN addresses first,
+	// DefaultLocalNetworks when empty.
+	LocalNetworks []string `json:"local_networks" mapstructure:"local_networks"`
+
+	// ProbeTimeout bounds the reachability probe of an address, e.g. 500ms.
+	ProbeTimeout string `json:"probe_timeout" mapstructure:"probe_timeout"`
+}
+
+// Service is a service tracked by the registry.
+type Service struct {
+	Name string `json:"name"`
+
+	LAN string `json:"lan,omitempty"`
+	WAN string `json:"wan,omitempty"`
+
+	// Ports are the ports of the service by name, e.g. {"http": 8080}.
+	Ports    map[string]int         `json:"ports,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// Task is the task publishing the service, which is deregistered when the task stops.
+	Task string `json:"task,omitempty"`
+
+	// Services with a TTL expire unless they send heartbeats.
+	TTL          string     `json:"ttl,omitempty"`
+	RegisteredAt time.Time  `json:"registered_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// Resolution is the address of a service resolved for a caller.
+type Resolution struct {
+	Service string `json:"service"`
+
+	// Network is the network of the address, lan or wan.
+	Network string `json:"network"`
+	Address string `json:"address"`
+
+	// Port and Endpoint are set for the services with ports.
+	Port     int    `json:"port,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Local is whether the caller was found on a local network.
+	Local bool `json:"local"`
+
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type registerPayload struct {
+	Name     string                 `mapstructure:"name"`
+	LAN      string                 `mapstructure:"lan"`
+	WAN      string                 `mapstructure:"wan"`
+	Ports    map[string]int         `mapstructure:"ports"`
+	Metadata map[string]interface{} `mapstructure:"metadata"`
+	TTL      string                 `mapstructure:"ttl"`
+}
+
+type namePayload struct {
+	Name string `mapstructure:"name"`
+}
+
+type resolvePayload struct {
+	Name string `mapstructure:"name"`
+	Port string `mapstructure:"port"`
+	From string `mapstructure:"from"`
+}
+
+// Module is a registry of services.
+type Module struct {
+	mu       sync.RWMutex
+	services map[string]*Service
+
+	lanAddress    string
+	wanAddress    string
+	localNetworks []*net.IPNet
+	probeTimeout  time.Duration
+
+	stateFile string
+
+	// Events receives the registry/registered, registry/deregistered and
+	// registry/expired events.
+	Events *events.Bus
+
+	sub     *events.Subscription
+	running sync.WaitGroup
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewModule initializes an empty registry.
+func NewModule(settings Settings) (*Module, error) {
+	m := &Module{
+		services:     make(map[string]*Service),
+		lanAddress:   settings.LANAddress,
+		wanAddress:   settings.WANAddress,
+		probeTimeout: DefaultProbeTimeout,
+		done:         make(chan struct{}),
+	}
+
+	if m.lanAddress == "" {
+		m.lanAddress = hostLANAddress()
+	}
+
+	for _, addr := range []string{settings.LANAddress, settings.WANAddress} {
+		if addr != "" && !validAddress(addr) {
+			return nil, fmt.Errorf("invalid %s address: %s", ModuleName, addr)
+		}
+	}
+
+	localNetworks := settings.LocalNetworks
+	if len(localNetworks) == 0 {
+		localNetworks = DefaultLocalNetworks
+	}
+	for _, cidr := range localNetworks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid local network %s: %s", cidr, err.Error())
+		}
+		m.localNetworks = append(m.localNetworks, network)
+	}
+
+	if settings.ProbeTimeout != "" {
+		timeout, err := time.ParseDuration(settings.ProbeTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid probe timeout: %s", err.Error())
+		}
+		m.probeTimeout = timeout
+	}
+
+	return m, nil
+}
+
+// NewPersistentModule initializes a registry backed by a state file.
+// The services are restored from the file if it exists, and saved to it on Close.
+func NewPersistentModule(settings Settings, stateFile string) (*Module, error) {
+	m, err := NewModule(settings)
+	if err != nil {
+		return nil, err
+	}
+	m.stateFile = stateFile
+
+	data, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &m.services); err != nil {
+		return nil, fmt.Errorf("invalid registry state file %s: %s", stateFile, err.Error())
+	}
+
+	if m.services == nil {
+		m.services = make(map[string]*Service)
+	}
+	servicesRegistered.WithLabelValues().Set(float64(len(m.services)))
+
+	return m, nil
+}
+
+// hostLANAddress returns the first private IPv4 address of the host, if any.
+func hostLANAddress() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil || ipNet.IP.IsLoopback() {
+			continue
+		}
+		for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"} {
+			if _, private, _ := net.ParseCIDR(cidr); private.Contains(ipNet.IP) {
+				return ipNet.IP.String()
+			}
+		}
+	}
+
+	return ""
+}
+
+// validAddress returns whether the address is an IP address or a host name.
+func validAddress(addr string) bool {
+	return net.ParseIP(addr) != nil || hostname.MatchString(addr)
+}
+
+// Watch registers the services of the tasks publishing ports when they start, and
+// deregisters them when they stop, until the module is closed. Meanwhile, the
+// services past their TTL are removed without waiting for the next registry action.
+func (m *Module) Watch(bus *events.Bus) {
+	m.sub = bus.Subscribe([]string{events.TopicTaskStarted, events.TopicTaskStopped, events.TopicTaskExited}, 0)
+
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+
+		ticker := time.NewTicker(reapInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.expire(context.Background())
+			case <-m.done:
+				return
+			}
+		}
+	}()
+
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+
+		for evt := range m.sub.Events() {
+			taskName, _ := evt.Data["task"].(string)
+			ctx := request.WithID(context.Background(), evt.RequestID)
+
+			if evt.Topic == events.TopicTaskStarted {
+				ports, _ := evt.Data["ports"].(map[string]int)
+				m.registerTask(ctx, taskName, ports)
+			} else {
+				m.deregisterTask(ctx, taskName)
+			}
+		}
+	}()
+}
+
+// Reconcile brings the services of the tasks in line with the running tasks, given
+// with their published ports. The services of the tasks that stopped while ORC was
+// down are deregistered, and the tasks already running when ORC started, which
+// publish no task/started event, are registered.
+func (m *Module) Reconcile(ctx context.Context, running map[string]map[string]int) {
+	var stale []string
+	registered := make(map[string]bool)
+
+	m.mu.RLock()
+	for _, svc := range m.services {
+		if svc.Task == "" {
+			continue
+		}
+		if _, ok := running[svc.Task]; ok {
+			registered[svc.Task] = true
+		} else {
+			stale = append(stale, svc.Task)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(stale)
+	for _, taskName := range stale {
+		m.deregisterTask(ctx, taskName)
+	}
+
+	names := make([]string, 0, len(running))
+	for taskName := range running {
+		if !registered[taskName] {
+			names = append(names, taskName)
+		}
+	}
+	sort.Strings(names)
+	for _, taskName := range names {
+		m.registerTask(ctx, taskName, running[taskName])
+	}
+}
+
+// registerTask registers the service of a task, by the container ports published
+// on the host ports. Services registered by other means are left untouched.
+func (m *Module) registerTask(ctx context.Context, taskName string, hostPorts map[string]int) {
+	if len(hostPorts) == 0 {
+		return
+	}
+
+	ctxLog := request.Log(ctx).WithFields(logrus.Fields{"module": ModuleName, "task": taskName})
+	if m.lanAddress == "" && m.wanAddress == "" {
+		ctxLog.Warn("the address of the host is unknown, set lan_address in the registry settings")
+		return
+	}
+
+	ports := make(map[string]int, len(hostPorts))
+	for hostPort, containerPort := range hostPorts {
+		port, err := strconv.Atoi(hostPort)
+		if err != nil {
+			ctxLog.Warnf("skipping invalid host port: %s", hostPort)
+			continue
+		}
+		ports[strconv.Itoa(containerPort)] = port
+	}
+
+	m.mu.Lock()
+	if existing, ok := m.services[taskName]; ok && existing.Task == "" {
+		m.mu.Unlock()
+		ctxLog.Infof("service %s is already registered, not registering the task", taskName)
+		return
+	}
+	m.services[taskName] = &Service{
+		Name:         taskName,
+		LAN:          m.lanAddress,
+		WAN:          m.wanAddress,
+		Ports:        ports,
+		Task:         taskName,
+		RegisteredAt: time.Now().UTC(),
+	}
+	servicesRegistered.WithLabelValues().Set(float64(len(m.services)))
+	m.mu.Unlock()
+
+	m.Events.Publish(ctx, events.TopicServiceRegistered, map[string]interface{}{"service": taskName})
+}
+
+// deregisterTask removes the service of a task, if the task registered it.
+func (m *Module) deregisterTask(ctx context.Context, taskName string) {
+	m.mu.Lock()
+	svc, ok := m.services[taskName]
+	if !ok || svc.Task != taskName {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.services, taskName)
+	servicesRegistered.WithLabelValues().Set(float64(len(m.services)))
+	m.mu.Unlock()
+
+	m.Events.Publish(ctx, events.TopicServiceDeregistered, map[string]interface{}{"service": taskName})
+}
+
+// Close stops watching the tasks and expiring the services, then persists the
+// registry to its state file, if any.
+func (m *Module) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	if m.sub != nil {
+		m.sub.Close()
+	}
+	m.running.Wait()
+
+	if m.stateFile == "" {
+		return nil
+	}
+
+	m.mu.RLock()
+	data, err := json.Marshal(m.services)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state file behind.
+	tmpFile := m.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, m.stateFile)
+}
+
+// Stateful returns true, the services live in memory and in the state file.
+func (m *Module) Stateful() bool { return true }
+
+// Name returns the name of the registry module.
+func (m *Module) Name() string { return ModuleName }
+
+// Actions returns the actions supported by the module.
+func (m *Module) Actions() []string {
+	return []string{actionRegister, actionHeartbeat, actionDeregister, actionList, actionResolve}
+}
+
+// ActionSchema returns the arguments of a registry action.
+func (m *Module) ActionSchema(actionName string) schema.Schema {
+	name := schema.Field{Name: "name", Type: schema.TypeString, Required: true, Description: "Name of the service"}
+
+	switch actionName {
+	case actionRegister:
+		return schema.New(
+			name,
+			schema.Field{Name: "lan", Type: schema.TypeString, Description: "Address of the service on the local network"},
+			schema.Field{Name: "wan", Type: schema.TypeString, Description: "Address of the service from the internet"},
+			schema.Field{Name: "ports", Type: schema.TypeObject, Description: "Ports of the service by name, e.g. {\"http\": 8080}"},
+			schema.Field{Name: "metadata", Type: schema.TypeObject, Description: "Metadata of the service"},
+			schema.Field{Name: "ttl", Type: schema.TypeString, Description: "Expiration of the service without heartbeats, e.g. 30s"},
+		)
+	case actionHeartbeat, actionDeregister:
+		return schema.New(name)
+	case actionList:
+		return schema.NewReadOnly()
+	case actionResolve:
+		return schema.NewReadOnly(
+			name,
+			schema.Field{Name: "port", Type: schema.TypeString, Description: "Name of the port to resolve, the first one by default"},
+			schema.Field{Name: "from", Type: schema.TypeString, Description: "Address to resolve the service for, the caller by default, e.g. the client behind a reverse proxy"},
+		)
+	}
+
+	return schema.New()
+}
+
+// ActionRoutes exposes the services as resources: GET and DELETE /registry/services/{name}.
+// The services live under their own path, so that their names can't shadow the actions.
+func (m *Module) ActionRoutes(actionName string) []schema.Route {
+	switch actionName {
+	case actionResolve:
+		return []schema.Route{{Method: http.MethodGet, Path: "services/{name}"}}
+	case actionDeregister:
+		return []schema.Route{{Method: http.MethodDelete, Path: "services/{name}"}}
+	}
+	return nil
+}
+
+// Execute executes a registry action.
+func (m *Module) Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
+	m.expire(ctx)
+
+	switch actionName {
+	case actionRegister:
+		var args registerPayload
+		if err := mapstructure.Decode(data, &args); err != nil {
+			return nil, apierr.InvalidArgument("invalid arguments: %s", err.Error())
+		}
+		return m.register(ctx, args)
+
+	case actionList:
+		return m.list()
+
+	case actionResolve:
+		var args resolvePayload
+		if err := mapstructure.Decode(data, &args); err != nil {
+			return nil, apierr.InvalidArgument("invalid arguments: %s", err.Error())
+		}
+		return m.resolve(ctx, args)
+	}
+
+	var args namePayload
+	if err := mapstructure.Decode(data, &args); err != nil {
+		return nil, apierr.InvalidArgument("invalid arguments: %s", err.Error())
+	}
+
+	switch actionName {
+	case actionHeartbeat:
+		return m.heartbeat(args.Name)
+	case actionDeregister:
+		return m.deregister(ctx, args.Name)
+	}
+
+	return nil, apierr.NotFound("unknown action: %s", actionName)
+}
+
+// expire removes the services whose TTL elapsed without heartbeats.
+func (m *Module) expire(ctx context.Context) {
+	now := time.Now()
+
+	var expired []string
+	m.mu.Lock()
+	for name, svc := range m.services {
+		if svc.ExpiresAt != nil && svc.ExpiresAt.Before(now) {
+			delete(m.services, name)
+			expired = append(expired, name)
+		}
+	}
+	servicesRegistered.WithLabelValues().Set(float64(len(m.services)))
+	m.mu.Unlock()
+
+	sort.Strings(expired)
+	for _, name := range expired {
+		m.Events.Publish(ctx, events.TopicServiceExpired, map[string]interface{}{"service": name})
+	}
+}
+
+func (m *Module) register(ctx context.Context, args registerPayload) ([]byte, error) {
+	if args.Name == "" {
+		return nil, apierr.InvalidArgument("service name not specified")
+	}
+	if args.LAN == "" && args.WAN == "" {
+		return nil, apierr.InvalidArgument("service %s has no address, specify lan or wan", args.Name).WithDetail("service", args.Name)
+	}
+	for network, addr := range map[string]string{NetworkLAN: args.LAN, NetworkWAN: args.WAN} {
+		if addr != "" && !validAddress(addr) {
+			return nil, apierr.InvalidArgument("invalid %s address: %s", network, addr).WithDetail(network, addr)
+		}
+	}
+	for portName, port := range args.Ports {
+		if port < 1 || port > 65535 {
+			return nil, apierr.InvalidArgument("invalid port %s: %d", portName, port).WithDetail("port", portName)
+		}
+	}
+
+	svc := &Service{
+		Name:         args.Name,
+		LAN:          args.LAN,
+		WAN:          args.WAN,
+		Ports:        args.Ports,
+		Metadata:     args.Metadata,
+		TTL:          args.TTL,
+		RegisteredAt: time.Now().UTC(),
+	}
+	if err := svc.renew(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.services[svc.Name] = svc
+	servicesRegistered.WithLabelValues().Set(float64(len(m.services)))
+	snapshot := *svc
+	m.mu.Unlock()
+
+	m.Events.Publish(ctx, events.TopicServiceRegistered, map[string]interface{}{"service": svc.Name})
+	return json.Marshal(snapshot)
+}
+
+// renew pushes back the expiration of the service by its TTL.
+func (svc *Service) renew() error {
+	if svc.TTL == "" {
+		return nil
+	}
+
+	ttl, err := time.ParseDuration(svc.TTL)
+	if err != nil || ttl <= 0 {
+		return apierr.InvalidArgument("invalid ttl: %s", svc.TTL).WithDetail("ttl", svc.TTL)
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	svc.ExpiresAt = &expiresAt
+	return nil
+}
+
+func (m *Module) heartbeat(name string) ([]byte, error) {
+	m.mu.Lock()
+	svc, ok := m.services[name]
+	if !ok {
+		m.mu.Unlock()
+		return nil, apierr.NotFound("unknown service: %s", name).WithDetail("service", name)
+	}
+	err := svc.renew()
+	snapshot := *svc
+	m.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(snapshot)
+}
+
+func (m *Module) deregister(ctx context.Context, name string) ([]byte, error) {
+	m.mu.Lock()
+	_, ok := m.services[name]
+	delete(m.services, name)
+	servicesRegistered.WithLabelValues().Set(float64(len(m.services)))
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, apierr.NotFound("unknown service: %s", name).WithDetail("service", name)
+	}
+
+	m.Events.Publish(ctx, events.TopicServiceDeregistered, map[string]interface{}{"service": name})
+	return json.Marshal(map[string]string{"message": "OK"})
+}
+
+func (m *Module) list() ([]byte, error) {
+	m.mu.RLock()
+	services := make([]Service, 0, len(m.services))
+	for _, svc := range m.services {
+		services = append(services, *svc)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return json.Marshal(map[string]interface{}{"services": services})
+}
+
+// resolve returns the address of the service reachable by the caller: callers on a
+// local network get the LAN address first, the others the WAN address first.
+//
+// The reachability of the addresses of the tasks is probed from the server, in that
+// order. The services registered by the callers are not probed, which would let them
+// scan any address from the network of the server, their first address is returned.
+//
+// The caller is located by the remote address of its connection, which is the one of
+// the proxy when ORC is served behind a reverse proxy. Such callers set "from" instead.
+func (m *Module) resolve(ctx context.Context, args resolvePayload) ([]byte, error) {
+	m.mu.RLock()
+	svc, ok := m.services[args.Name]
+	var snapshot Service
+	if ok {
+		snapshot = *svc
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, apierr.NotFound("unknown service: %s", args.Name).WithDetail("service", args.Name)
+	}
+
+	port, err := snapshot.port(args.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	from := args.From
+	if from == "" {
+		from = request.RemoteAddr(ctx)
+	}
+	local := m.isLocal(from)
+
+	candidates := []struct{ network, address string }{{NetworkLAN, snapshot.LAN}, {NetworkWAN, snapshot.WAN}}
+	if !local {
+		candidates[0], candidates[1] = candidates[1], candidates[0]
+	}
+
+	var unreachable []string
+	for _, candidate := range candidates {
+		if candidate.address == "" {
+			continue
+		}
+
+		res := Resolution{Service: snapshot.Name, Network: candidate.network, Address: candidate.address, Local: local, Metadata: snapshot.Metadata}
+		if port == 0 {
+			// Services without ports can't be probed.
+			return json.Marshal(res)
+		}
+
+		res.Port = port
+		res.Endpoint = net.JoinHostPort(candidate.address, strconv.Itoa(port))
+		if snapshot.Task == "" || m.probe(ctx, res.Endpoint) {
+			return json.Marshal(res)
+		}
+		unreachable = append(unreachable, res.Endpoint)
+	}
+
+	return nil, apierr.Unavailable("service %s is unreachable", snapshot.Name).WithDetail("service", snapshot.Name).WithDetail("unreachable", unreachable)
+}
+
+// port returns the port of the given name, or the first port in name order.
+// Services without ports return 0.
+func (svc *Service) port(portName string) (int, error) {
+	if portName != "" {
+		port, ok := svc.Ports[portName]
+		if !ok {
+			return 0, apierr.NotFound("unknown port %s of service %s", portName, svc.Name).WithDetail("port", portName)
+		}
+		return port, nil
+	}
+
+	names := make([]string, 0, len(svc.Ports))
+	for name := range svc.Ports {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+	sort.Strings(names)
+	return svc.Ports[names[0]], nil
+}
+
+// isLocal returns whether the address, with or without port, belongs to a local
+// network. Callers without an IP address, such as those of a unix socket or of ORC
+// itself, are local.
+func (m *Module) isLocal(addr string) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return true
+	}
+
+	for _, network := range m.localNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// probe returns whether a TCP connection to the endpoint succeeds within the probe timeout.
+func (m *Module) probe(ctx context.Context, endpoint string) bool {
+	dialer := net.Dialer{Timeout: m.probeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,413 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/registry"
+	"github.com/dalloriam/orc/request"
+)
+
+func newModule(t *testing.T) *registry.Module {
+	m, err := registry.NewModule(registry.Settings{LANAddress: "192.168.1.10", WANAddress: "home.example.com", ProbeTimeout: "200ms"})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	return m
+}
+
+func listen(t *testing.T, addr string) net.Listener {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %s", addr, err.Error())
+	}
+	return l
+}
+
+func TestNewModule(t *testing.T) {
+	type testCase struct {
+		name     string
+		settings registry.Settings
+
+		wantErr bool
+	}
+
+	cases := []testCase{
+		{"defaults", registry.Settings{}, false},
+		{"all settings", registry.Settings{LANAddress: "10.0.0.2", WANAddress: "home.example.com", LocalNetworks: []string{"10.0.0.0/24"}, ProbeTimeout: "500ms"}, false},
+		{"invalid address", registry.Settings{WANAddress: "http://home.example.com"}, true},
+		{"invalid network", registry.Settings{LocalNetworks: []string{"10.0.0.0"}}, true},
+		{"invalid probe timeout", registry.Settings{ProbeTimeout: "soon"}, true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			m, err := registry.NewModule(tCase.settings)
+			if (err != nil) != tCase.wantErr {
+				t.Fatalf("expected err: %v, got err=%v", tCase.wantErr, err)
+			}
+			if !tCase.wantErr && m.Name() != "registry" {
+				t.Errorf("invalid name: %s", m.Name())
+			}
+		})
+	}
+}
+
+func TestModule_Execute(t *testing.T) {
+	type testCase struct {
+		name   string
+		action string
+		data   map[string]interface{}
+
+		wantErr apierr.Code
+	}
+
+	m := newModule(t)
+
+	cases := []testCase{
+		{"register", "register", map[string]interface{}{"name": "plex", "lan": "192.168.1.20", "ports": map[string]interface{}{"http": 32400.0}, "metadata": map[string]interface{}{"kind": "media"}}, ""},
+		{"register with ttl", "register", map[string]interface{}{"name": "laptop", "wan": "203.0.113.5", "ttl": "1m"}, ""},
+		{"heartbeat", "heartbeat", map[string]interface{}{"name": "laptop"}, ""},
+		{"list", "list", nil, ""},
+		{"deregister", "deregister", map[string]interface{}{"name": "laptop"}, ""},
+		{"no name", "register", map[string]interface{}{"lan": "192.168.1.20"}, apierr.CodeInvalidArgument},
+		{"no address", "register", map[string]interface{}{"name": "plex"}, apierr.CodeInvalidArgument},
+		{"invalid address", "register", map[string]interface{}{"name": "plex", "lan": "192.168.1.20:32400"}, apierr.CodeInvalidArgument},
+		{"invalid port", "register", map[string]interface{}{"name": "plex", "lan": "192.168.1.20", "ports": map[string]interface{}{"http": 70000.0}}, apierr.CodeInvalidArgument},
+		{"invalid ttl", "register", map[string]interface{}{"name": "plex", "lan": "192.168.1.20", "ttl": "-1s"}, apierr.CodeInvalidArgument},
+		{"unknown heartbeat", "heartbeat", map[string]interface{}{"name": "laptop"}, apierr.CodeNotFound},
+		{"unknown deregister", "deregister", map[string]interface{}{"name": "laptop"}, apierr.CodeNotFound},
+		{"unknown action", "explode", nil, apierr.CodeNotFound},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			_, err := m.Execute(context.Background(), tCase.action, tCase.data)
+			if tCase.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %s", err.Error())
+				}
+				return
+			}
+
+			if apiErr, ok := err.(*apierr.Error); !ok || apiErr.Code != tCase.wantErr {
+				t.Errorf("expected %s error, got %v", tCase.wantErr, err)
+			}
+		})
+	}
+
+	out, err := m.Execute(context.Background(), "list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listed struct {
+		Services []registry.Service `json:"services"`
+	}
+	if err := json.Unmarshal(out, &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Services) != 1 || listed.Services[0].Name != "plex" || listed.Services[0].Ports["http"] != 32400 || listed.Services[0].Metadata["kind"] != "media" {
+		t.Errorf("unexpected services: %+v", listed.Services)
+	}
+}
+
+func TestModule_Resolve(t *testing.T) {
+	lan := listen(t, "127.0.0.1:0")
+	defer lan.Close()
+	port := lan.Addr().(*net.TCPAddr).Port
+	wan := listen(t, "127.0.0.2:"+strconv.Itoa(port))
+
+	// Only the services of the tasks are probed.
+	m, err := registry.NewModule(registry.Settings{LANAddress: "127.0.0.1", WANAddress: "127.0.0.2", ProbeTimeout: "200ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Reconcile(context.Background(), map[string]map[string]int{"plex": {strconv.Itoa(port): 1900, "1": 32400}})
+
+	resolve := func(ctx context.Context, data map[string]interface{}) (registry.Resolution, error) {
+		var res registry.Resolution
+		out, err := m.Execute(ctx, "resolve", data)
+		if err == nil {
+			err = json.Unmarshal(out, &res)
+		}
+		return res, err
+	}
+
+	type testCase struct {
+		name string
+		ctx  context.Context
+		data map[string]interface{}
+
+		expectedNetwork string
+		expectedLocal   bool
+	}
+
+	cases := []testCase{
+		{"local caller", context.Background(), map[string]interface{}{"name": "plex", "from": "192.168.1.5"}, registry.NetworkLAN, true},
+		{"remote caller", context.Background(), map[string]interface{}{"name": "plex", "from": "203.0.113.5"}, registry.NetworkWAN, false},
+		{"remote client", request.WithRemoteAddr(context.Background(), "203.0.113.5:51000"), map[string]interface{}{"name": "plex"}, registry.NetworkWAN, false},
+		{"caller without address", context.Background(), map[string]interface{}{"name": "plex"}, registry.NetworkLAN, true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			res, err := resolve(tCase.ctx, tCase.data)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+
+			if res.Network != tCase.expectedNetwork || res.Local != tCase.expectedLocal || res.Port != port {
+				t.Errorf("unexpected resolution: %+v", res)
+			}
+		})
+	}
+
+	// Unreachable addresses are skipped.
+	wan.Close()
+	res, err := resolve(context.Background(), map[string]interface{}{"name": "plex", "from": "203.0.113.5"})
+	if err != nil || res.Network != registry.NetworkLAN || res.Endpoint != lan.Addr().String() {
+		t.Errorf("expected the LAN address, got %+v (%v)", res, err)
+	}
+
+	failures := []struct {
+		name string
+		data map[string]interface{}
+		code apierr.Code
+	}{
+		{"unknown service", map[string]interface{}{"name": "emby"}, apierr.CodeNotFound},
+		{"unknown port", map[string]interface{}{"name": "plex", "port": "https"}, apierr.CodeNotFound},
+		{"unreachable", map[string]interface{}{"name": "plex", "port": "32400"}, apierr.CodeUnavailable},
+	}
+	for _, failure := range failures {
+		_, err := resolve(context.Background(), failure.data)
+		if apiErr, ok := err.(*apierr.Error); !ok || apiErr.Code != failure.code {
+			t.Errorf("%s: expected %s error, got %v", failure.name, failure.code, err)
+		}
+	}
+
+	// The services registered by the callers are resolved without probing their address.
+	register := map[string]interface{}{"name": "scan", "lan": "192.0.2.1", "ports": map[string]interface{}{"ssh": 22.0}}
+	if _, err := m.Execute(context.Background(), "register", register); err != nil {
+		t.Fatal(err)
+	}
+	res, err = resolve(context.Background(), map[string]interface{}{"name": "scan"})
+	if err != nil || res.Endpoint != "192.0.2.1:22" {
+		t.Errorf("expected the registered address, got %+v (%v)", res, err)
+	}
+}
+
+func TestModule_Expire(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe([]string{events.TopicServiceExpired}, 0)
+	defer sub.Close()
+
+	m := newModule(t)
+	m.Events = bus
+
+	if _, err := m.Execute(context.Background(), "register", map[string]interface{}{"name": "laptop", "lan": "192.168.1.30", "ttl": "20ms"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	if _, err := m.Execute(context.Background(), "heartbeat", map[string]interface{}{"name": "laptop"}); err == nil {
+		t.Errorf("expected the service to have expired")
+	}
+
+	select {
+	case evt := <-sub.Events():
+		if evt.Data["service"] != "laptop" {
+			t.Errorf("unexpected event data: %v", evt.Data)
+		}
+	default:
+		t.Errorf("expected a %s event", events.TopicServiceExpired)
+	}
+}
+
+func TestModule_Reap(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe([]string{events.TopicServiceExpired}, 0)
+	defer sub.Close()
+
+	m := newModule(t)
+	m.Events = bus
+	m.Watch(bus)
+	defer m.Close()
+
+	if _, err := m.Execute(context.Background(), "register", map[string]interface{}{"name": "laptop", "lan": "192.168.1.30", "ttl": "20ms"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The service expires without further registry actions.
+	select {
+	case evt := <-sub.Events():
+		if evt.Data["service"] != "laptop" {
+			t.Errorf("unexpected event data: %v", evt.Data)
+		}
+	case <-time.After(3 * time.Second):
+		t.Errorf("expected a %s event", events.TopicServiceExpired)
+	}
+}
+
+func TestModule_Reconcile(t *testing.T) {
+	m := newModule(t)
+	ctx := context.Background()
+
+	bus := events.NewBus()
+	m.Watch(bus)
+	defer m.Close()
+
+	if _, err := m.Execute(ctx, "register", map[string]interface{}{"name": "db", "lan": "192.168.1.40"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"web", "stopped"} {
+		bus.Publish(ctx, events.TopicTaskStarted, map[string]interface{}{"task": name, "ports": map[string]int{"8080": 80}})
+	}
+
+	list := func() map[string]registry.Service {
+		out, err := m.Execute(ctx, "list", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var listed struct {
+			Services []registry.Service `json:"services"`
+		}
+		if err := json.Unmarshal(out, &listed); err != nil {
+			t.Fatal(err)
+		}
+
+		services := make(map[string]registry.Service)
+		for _, svc := range listed.Services {
+			services[svc.Name] = svc
+		}
+		return services
+	}
+
+	for i := 0; len(list()) != 3; i++ {
+		if i == 100 {
+			t.Fatalf("expected the tasks to be registered, got %v", list())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The stopped task is gone, the running one was hooked into without event.
+	m.Reconcile(ctx, map[string]map[string]int{"web": {"8080": 80}, "hooked": {"9000": 9000}, "db": nil})
+
+	services := list()
+	if _, ok := services["stopped"]; ok {
+		t.Errorf("expected the service of the stopped task to be deregistered")
+	}
+	if hooked, ok := services["hooked"]; !ok || hooked.Task != "hooked" || hooked.Ports["9000"] != 9000 {
+		t.Errorf("expected the service of the running task to be registered, got %+v", hooked)
+	}
+	if _, ok := services["web"]; !ok {
+		t.Errorf("expected the service of the running task to remain registered")
+	}
+	if db := services["db"]; db.Task != "" {
+		t.Errorf("expected services registered by other means to be left untouched, got %+v", db)
+	}
+}
+
+func TestModule_Watch(t *testing.T) {
+	bus := events.NewBus()
+	m := newModule(t)
+	m.Events = bus
+	m.Watch(bus)
+	defer m.Close()
+
+	if _, err := m.Execute(context.Background(), "register", map[string]interface{}{"name": "db", "lan": "192.168.1.40"}); err != nil {
+		t.Fatal(err)
+	}
+
+	registered := bus.Subscribe([]string{events.TopicServiceRegistered, events.TopicServiceDeregistered}, 0)
+	defer registered.Close()
+
+	receive := func() events.Event {
+		select {
+		case evt := <-registered.Events():
+			return evt
+		case <-time.After(time.Second):
+			t.Fatalf("expected a registry event")
+		}
+		return events.Event{}
+	}
+
+	ctx := context.Background()
+	bus.Publish(ctx, events.TopicTaskStarted, map[string]interface{}{"task": "web", "ports": map[string]int{"8080": 80}})
+	if evt := receive(); evt.Topic != events.TopicServiceRegistered || evt.Data["service"] != "web" {
+		t.Fatalf("unexpected event: %+v", evt)
+	}
+
+	out, err := m.Execute(ctx, "list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed struct {
+		Services []registry.Service `json:"services"`
+	}
+	if err := json.Unmarshal(out, &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Services) != 2 {
+		t.Fatalf("expected db and web to be registered, got %+v", listed.Services)
+	}
+	if web := listed.Services[1]; web.LAN != "192.168.1.10" || web.WAN != "home.example.com" || web.Ports["80"] != 8080 || web.Task != "web" {
+		t.Errorf("expected the task to be registered on the addresses of the host, got %+v", web)
+	}
+
+	// Services registered by other means are left untouched by the tasks.
+	bus.Publish(ctx, events.TopicTaskStarted, map[string]interface{}{"task": "db", "ports": map[string]int{"5432": 5432}})
+	bus.Publish(ctx, events.TopicTaskExited, map[string]interface{}{"task": "db"})
+	bus.Publish(ctx, events.TopicTaskStopped, map[string]interface{}{"task": "web"})
+	if evt := receive(); evt.Topic != events.TopicServiceDeregistered || evt.Data["service"] != "web" {
+		t.Fatalf("unexpected event: %+v", evt)
+	}
+
+	if _, err := m.Execute(ctx, "heartbeat", map[string]interface{}{"name": "db"}); err != nil {
+		t.Errorf("expected db to remain registered, got %s", err.Error())
+	}
+}
+
+func TestNewPersistentModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orc-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stateFile := path.Join(dir, "registry.json")
+	m, err := registry.NewPersistentModule(registry.Settings{}, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Execute(context.Background(), "register", map[string]interface{}{"name": "plex", "lan": "192.168.1.20"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := registry.NewPersistentModule(registry.Settings{}, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.Execute(context.Background(), "heartbeat", map[string]interface{}{"name": "plex"}); err != nil {
+		t.Errorf("expected the service to be restored, got %s", err.Error())
+	}
+
+	if err := ioutil.WriteFile(stateFile, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.NewPersistentModule(registry.Settings{}, stateFile); err == nil {
+		t.Errorf("expected invalid state file to be rejected")
+	}
+}
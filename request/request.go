@@ -0,0 +1,212 @@
+package request
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+const (
+	idKey contextKey = iota
+	callerKey
+	asyncKey
+	traceParentKey
+	remoteAddrKey
+	scopesKey
+)
+
+// Headers used to propagate the request context over HTTP.
+const (
+	IDHeader          = "X-Request-ID"
+	CallerHeader      = "X-Orc-Caller"
+	TraceParentHeader = "Traceparent"
+)
+
+// Environment variables used to propagate the request context to commands.
+const (
+	IDEnvVar       = "ORC_REQUEST_ID"
+	CallerEnvVar   = "ORC_CALLER"
+	DeadlineEnvVar = "ORC_DEADLINE"
+
+	// TraceParentEnvVar follows the OpenTelemetry convention for environment propagation.
+	TraceParentEnvVar = "TRACEPARENT"
+)
+
+// maxIDLength is the maximum length of the request IDs accepted from clients.
+const maxIDLength = 128
+
+// Anonymous is the caller identity of unauthenticated requests.
+const Anonymous = "anonymous"
+
+// NewID generates a random request ID.
+func NewID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// IDFromHeader returns the request ID sent by the client, or a new ID if the
+// client sent none. IDs that are too long or contain characters other than
+// printable ASCII are replaced, as they end up in logs and in subprocess environments.
+func IDFromHeader(header http.Header) string {
+	id := header.Get(IDHeader)
+	if id == "" || len(id) > maxIDLength {
+		return NewID()
+	}
+
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return NewID()
+		}
+	}
+
+	return id
+}
+
+// WithID returns a copy of the context carrying the request ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey, id)
+}
+
+// ID returns the ID of the request carried by the context, if any.
+func ID(ctx context.Context) string {
+	id, _ := ctx.Value(idKey).(string)
+	return id
+}
+
+// WithCaller returns a copy of the context carrying the identity of the caller.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey, caller)
+}
+
+// Caller returns the identity of the caller carried by the context.
+// Requests without a known caller are anonymous.
+func Caller(ctx context.Context) string {
+	if caller, ok := ctx.Value(callerKey).(string); ok && caller != "" {
+		return caller
+	}
+	return Anonymous
+}
+
+// WithScopes returns a copy of the context carrying the scopes granted to the caller.
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// Scopes returns the scopes granted to the caller carried by the context.
+// The requests served without authentication, and the actions invoked by ORC
+// itself, have nil scopes.
+func Scopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey).([]string)
+	return scopes
+}
+
+// WithAsync returns a copy of the context requesting the action to run as a background job.
+func WithAsync(ctx context.Context) context.Context {
+	return context.WithValue(ctx, asyncKey, true)
+}
+
+// Async returns whether the caller requested the action to run as a background job.
+func Async(ctx context.Context) bool {
+	async, _ := ctx.Value(asyncKey).(bool)
+	return async
+}
+
+// WithTraceParent returns a copy of the context carrying the W3C trace parent
+// of the current span, propagated to plugins and tasks.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceParentKey, traceParent)
+}
+
+// TraceParent returns the W3C trace parent carried by the context, if any.
+func TraceParent(ctx context.Context) string {
+	traceParent, _ := ctx.Value(traceParentKey).(string)
+	return traceParent
+}
+
+// WithRemoteAddr returns a copy of the context carrying the network address of the
+// client, as "host:port".
+func WithRemoteAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, remoteAddrKey, addr)
+}
+
+// RemoteAddr returns the network address of the client carried by the context.
+// Actions invoked by ORC itself, or over a unix socket, have no address.
+func RemoteAddr(ctx context.Context) string {
+	addr, _ := ctx.Value(remoteAddrKey).(string)
+	return addr
+}
+
+// Detach returns a new context carrying the request ID, caller, scopes, trace
+// parent and client address of ctx, without its deadline and cancellation.
+func Detach(ctx context.Context) context.Context {
+	detached := WithCaller(context.Background(), Caller(ctx))
+	if scopes := Scopes(ctx); scopes != nil {
+		detached = WithScopes(detached, scopes)
+	}
+	if id := ID(ctx); id != "" {
+		detached = WithID(detached, id)
+	}
+	if traceParent := TraceParent(ctx); traceParent != "" {
+		detached = WithTraceParent(detached, traceParent)
+	}
+	if addr := RemoteAddr(ctx); addr != "" {
+		detached = WithRemoteAddr(detached, addr)
+	}
+	return detached
+}
+
+// Log returns a logger annotated with the request ID and caller carried by the context.
+// Contexts without request ID, such as the ones of startup operations, return the standard logger.
+func Log(ctx context.Context) *logrus.Entry {
+	id := ID(ctx)
+	if id == "" {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+
+	return logrus.WithFields(logrus.Fields{
+		"request_id": id,
+		"caller":     Caller(ctx),
+	})
+}
+
+// SetHeaders propagates the request context to an outgoing HTTP request.
+func SetHeaders(ctx context.Context, header http.Header) {
+	if id := ID(ctx); id != "" {
+		header.Set(IDHeader, id)
+	}
+	header.Set(CallerHeader, Caller(ctx))
+	if traceParent := TraceParent(ctx); traceParent != "" {
+		header.Set(TraceParentHeader, traceParent)
+	}
+}
+
+// Environ returns the environment variables propagating the request context to a command.
+func Environ(ctx context.Context) []string {
+	env := []string{CallerEnvVar + "=" + Caller(ctx)}
+
+	if id := ID(ctx); id != "" {
+		env = append(env, IDEnvVar+"="+id)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		env = append(env, DeadlineEnvVar+"="+deadline.UTC().Format(time.RFC3339))
+	}
+
+	if traceParent := TraceParent(ctx); traceParent != "" {
+		env = append(env, TraceParentEnvVar+"="+traceParent)
+	}
+
+	return env
+}
@@ -0,0 +1,135 @@
+package request_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/request"
+)
+
+func TestNewID(t *testing.T) {
+	first, second := request.NewID(), request.NewID()
+
+	if len(first) != 16 {
+		t.Errorf("expected a 16 characters ID, got %s", first)
+	}
+
+	if first == second {
+		t.Errorf("expected unique IDs, got %s twice", first)
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+
+	if request.ID(ctx) != "" {
+		t.Errorf("expected no request ID, got %s", request.ID(ctx))
+	}
+
+	if request.Caller(ctx) != request.Anonymous {
+		t.Errorf("expected anonymous caller, got %s", request.Caller(ctx))
+	}
+
+	ctx = request.WithCaller(request.WithID(ctx, "abcd"), "deploy")
+
+	if request.ID(ctx) != "abcd" {
+		t.Errorf("expected request ID abcd, got %s", request.ID(ctx))
+	}
+
+	if request.Caller(ctx) != "deploy" {
+		t.Errorf("expected caller deploy, got %s", request.Caller(ctx))
+	}
+}
+
+func TestDetach(t *testing.T) {
+	ctx, cancel := context.WithCancel(request.WithCaller(request.WithID(context.Background(), "abcd"), "deploy"))
+	detached := request.Detach(request.WithScopes(request.WithRemoteAddr(request.WithAsync(ctx), "192.168.1.20:51000"), []string{"job/*"}))
+	cancel()
+
+	if detached.Err() != nil {
+		t.Errorf("detached context was canceled along with its parent")
+	}
+
+	if request.ID(detached) != "abcd" || request.Caller(detached) != "deploy" {
+		t.Errorf("detached context lost the request ID or caller")
+	}
+
+	if request.RemoteAddr(detached) != "192.168.1.20:51000" {
+		t.Errorf("detached context lost the client address")
+	}
+
+	if scopes := request.Scopes(detached); len(scopes) != 1 || scopes[0] != "job/*" {
+		t.Errorf("detached context lost the scopes of the caller, got %v", scopes)
+	}
+
+	if request.Async(detached) {
+		t.Errorf("detached context should not request async execution")
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	deadline := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithDeadline(request.WithID(context.Background(), "abcd"), deadline)
+	defer cancel()
+
+	expected := []string{"ORC_CALLER=anonymous", "ORC_REQUEST_ID=abcd", "ORC_DEADLINE=2018-10-01T12:00:00Z"}
+	if actual := request.Environ(ctx); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestIDFromHeader(t *testing.T) {
+	type testCase struct {
+		name   string
+		header string
+
+		wantKept bool
+	}
+
+	cases := []testCase{
+		{"client id", "deploy-1234", true},
+		{"no id", "", false},
+		{"id with spaces", "deploy 1234", false},
+		{"id with newline", "abcd\nlevel=error", false},
+		{"long id", strings.Repeat("a", 129), false},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(request.IDHeader, tCase.header)
+
+			id := request.IDFromHeader(header)
+			if (id == tCase.header) != tCase.wantKept {
+				t.Errorf("expected id kept: %v, got %q", tCase.wantKept, id)
+			}
+			if id == "" {
+				t.Errorf("expected an ID to be generated")
+			}
+		})
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := request.WithTraceParent(request.WithID(context.Background(), "abcd"), traceParent)
+
+	if request.TraceParent(request.Detach(ctx)) != traceParent {
+		t.Errorf("detached context lost the trace parent")
+	}
+
+	header := http.Header{}
+	request.SetHeaders(ctx, header)
+	if header.Get(request.TraceParentHeader) != traceParent {
+		t.Errorf("expected trace parent header, got %q", header.Get(request.TraceParentHeader))
+	}
+
+	expected := []string{"ORC_CALLER=anonymous", "ORC_REQUEST_ID=abcd", "TRACEPARENT=" + traceParent}
+	if actual := request.Environ(ctx); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
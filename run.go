@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/config"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/output"
+	"github.com/dalloriam/orc/request"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	runCommandName = "run"
+	runCommandHelp = "Run an action without the ORC daemon."
+	runCommandArgs = "MODULE ACTION [OPTIONS]"
+
+	runCommandLongHelp = runCommandHelp + `
+
+The modules are loaded in-process from the configuration, as the server would,
+and the action is run right away, including the actions that would otherwise run
+as background jobs. Arguments and output are the same as for "orc cli", and so
+are the exit codes.
+
+Tasks started by the action are followed until they exit, along with the tasks
+they trigger; -detach returns as soon as the action completes, and Ctrl-C stops
+following them. Tasks are always left running, and the images of the tasks are
+not pulled. The tasks already running are left to the daemon managing them.
+
+Stateful modules (keyval, registry, job, manage) keep their state in the process
+running them: "orc run" works on a temporary copy of the state directory, which a
+running daemon neither sees nor preserves. A warning is printed when running their
+actions.`
+
+	// runCaller identifies the actions run by "orc run" in the events and the history.
+	runCaller = "run"
+)
+
+type runCommand struct {
+	settings *settings
+
+	call cliCommand
+
+	detach  bool
+	verbose bool
+}
+
+func (cmd *runCommand) Name() string      { return runCommandName }
+func (cmd *runCommand) Args() string      { return runCommandArgs }
+func (cmd *runCommand) ShortHelp() string { return runCommandHelp }
+func (cmd *runCommand) LongHelp() string  { return runCommandLongHelp }
+func (cmd *runCommand) Hidden() bool      { return false }
+func (cmd *runCommand) Register(fs *flag.FlagSet) {
+	cmd.settings.Register(fs)
+	cmd.call.registerCallFlags(fs)
+	fs.BoolVar(&cmd.detach, "detach", false, "Return once the action completes, without following the tasks it started")
+	fs.BoolVar(&cmd.verbose, "v", false, "Print the logs of the modules below the warning level")
+}
+
+func (cmd *runCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("Invalid syntax")
+	}
+
+	if !output.Valid(cmd.call.format) {
+		return fmt.Errorf("unknown output format: %s", cmd.call.format)
+	}
+
+	cfg, err := cmd.settings.Load()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	level, err := cfg.Level()
+	if err != nil {
+		return err
+	}
+	log.SetLevel(level)
+
+	// The output is the result of the action, the logs of the modules are mostly noise.
+	if !cmd.verbose && log.GetLevel() > log.WarnLevel {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var result interface{}
+	arguments, err := cmd.call.parseArguments(cmd.call.arguments)
+	if err == nil {
+		result, err = cmd.execute(ctx, cfg, args[0], args[1], arguments)
+	}
+
+	if apiErr, ok := err.(*apierr.Error); ok {
+		cmd.call.exitWithError(apiErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	return cmd.call.printResult(result)
+}
+
+// execute loads the modules, runs the action and shuts the modules down.
+func (cmd *runCommand) execute(ctx context.Context, cfg *config.Config, moduleName, actionName string, args []argument) (interface{}, error) {
+	for _, dir := range []string{cfg.TaskDirectory, cfg.PluginDirectory} {
+		if err := createDirIfNotExists(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	// The modules persist their state on shutdown, the state of the daemon is left untouched.
+	stateDir, err := ioutil.TempDir("", "orc-run")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stateDir)
+
+	if err := copyState(cfg.StateDirectory, stateDir); err != nil {
+		return nil, err
+	}
+	runCfg := *cfg
+	runCfg.StateDirectory = stateDir
+
+	o, err := NewStandalone(&runCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer cmd.shutdown(o, cfg)
+
+	o.mu.RLock()
+	mod, ok := o.modules[moduleName]
+	o.mu.RUnlock()
+	if !ok {
+		return nil, apierr.NotFound("unknown module: %s", moduleName)
+	}
+	if !hasAction(mod, actionName) {
+		return nil, apierr.NotFound("unknown action: %s/%s", moduleName, actionName)
+	}
+
+	if isStateful(mod) {
+		fmt.Fprintf(os.Stderr, "warning: %s is a stateful module, its state is not shared with the ORC daemon\n", moduleName)
+	}
+
+	body, err := cmd.call.body(actionSchema(mod, actionName), args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subscribe before running the action, so that the tasks it starts are known.
+	tasks := o.events.Subscribe([]string{events.TopicTaskStarted, events.TopicTaskExited}, 0)
+	defer tasks.Close()
+
+	ctx = request.WithCaller(request.WithID(ctx, request.NewID()), runCaller)
+	out, err := o.invoke(ctx, moduleName, actionName, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cmd.detach {
+		waitForTasks(ctx, tasks)
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(out, &result); err != nil {
+		result = string(out)
+	}
+	return result, nil
+}
+
+// copyState copies the state files of the daemon to the state directory of the run,
+// so that the stateful modules start from the last state the daemon persisted.
+func copyState(from, to string) error {
+	for _, name := range []string{keyvalStateFile, registryStateFile} {
+		data, err := ioutil.ReadFile(path.Join(from, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(path.Join(to, name), data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasAction(mod Module, actionName string) bool {
+	for _, name := range mod.Actions() {
+		if name == actionName {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmd *runCommand) shutdown(o *Orc, cfg *config.Config) {
+	gracePeriod, err := cfg.ShutdownGracePeriod()
+	if err != nil {
+		log.Errorf("invalid shutdown grace period: %s", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	if err := o.Shutdown(ctx); err != nil {
+		log.Errorf("error shutting down: %s", err.Error())
+	}
+}
+
+// waitForTasks waits until the tasks started while running the action exit, along
+// with the tasks they start in turn, or until the context is canceled.
+func waitForTasks(ctx context.Context, tasks *events.Subscription) {
+	running := make(map[string]bool)
+
+	track := func(evt events.Event) {
+		name, _ := evt.Data["task"].(string)
+		if evt.Topic == events.TopicTaskStarted {
+			running[name] = true
+		} else {
+			delete(running, name)
+		}
+	}
+
+	for {
+		// The events published while running the action are already queued.
+		select {
+		case evt, ok := <-tasks.Events():
+			if !ok {
+				return
+			}
+			track(evt)
+			continue
+		default:
+		}
+
+		if len(running) == 0 {
+			return
+		}
+
+		select {
+		case evt, ok := <-tasks.Events():
+			if !ok {
+				return
+			}
+			track(evt)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/config"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/hook"
+	"github.com/dalloriam/orc/keyval"
+	"github.com/dalloriam/orc/webhook"
+)
+
+func TestRunCommand_Execute(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "orc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(homeDir)
+
+	cfg := config.Default(homeDir)
+	cmd := &runCommand{}
+
+	// The runs start from the state of the daemon.
+	if err := os.MkdirAll(cfg.StateDirectory, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	daemonState, err := keyval.NewPersistentModule(path.Join(cfg.StateDirectory, keyvalStateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemonState.Execute(context.Background(), "set", map[string]interface{}{"key": "name", "val": "orc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := daemonState.Close(); err != nil {
+		t.Fatal(err)
+	}
+	persisted, err := ioutil.ReadFile(path.Join(cfg.StateDirectory, keyvalStateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get, err := cmd.call.parseArguments([]string{"key=name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := cmd.execute(context.Background(), cfg, "keyval", "get", get)
+	if err != nil {
+		t.Fatalf("unexpected error getting the key: %s", err.Error())
+	}
+	if value, _ := result.(map[string]interface{}); value["value"] != "orc" {
+		t.Errorf("expected orc, got %v", result)
+	}
+
+	// The state of the daemon is left untouched.
+	set, err := cmd.call.parseArguments([]string{"key=name", "val=changed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmd.execute(context.Background(), cfg, "keyval", "set", set); err != nil {
+		t.Fatalf("unexpected error setting the key: %s", err.Error())
+	}
+
+	if data, err := ioutil.ReadFile(path.Join(cfg.StateDirectory, keyvalStateFile)); err != nil || string(data) != string(persisted) {
+		t.Errorf("expected the state of the daemon to be unchanged, got %s (%v)", data, err)
+	}
+	if _, err := os.Stat(path.Join(cfg.StateDirectory, registryStateFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no registry state in the state directory of the daemon, got %v", err)
+	}
+
+	result, err = cmd.execute(context.Background(), cfg, "keyval", "get", get)
+	if err != nil {
+		t.Fatalf("unexpected error getting the key: %s", err.Error())
+	}
+	if value, _ := result.(map[string]interface{}); value["value"] != "orc" {
+		t.Errorf("expected the change not to be kept across runs, got %v", result)
+	}
+
+	type tCase struct {
+		name string
+
+		module string
+		action string
+		args   []argument
+
+		code apierr.Code
+	}
+
+	cases := []tCase{
+		{"unknown module", "nope", "get", nil, apierr.CodeNotFound},
+		{"unknown action", "keyval", "nope", nil, apierr.CodeNotFound},
+		{"invalid arguments", "keyval", "get", nil, apierr.CodeInvalidArgument},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			_, err := cmd.execute(context.Background(), cfg, tCase.module, tCase.action, tCase.args)
+
+			apiErr, ok := err.(*apierr.Error)
+			if !ok {
+				t.Fatalf("expected an API error, got %v", err)
+			}
+			if apiErr.Code != tCase.code {
+				t.Errorf("expected code %s, got %s", tCase.code, apiErr.Code)
+			}
+		})
+	}
+}
+
+func TestNewStandalone(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "orc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(homeDir)
+
+	var posted int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posted, 1)
+	}))
+	defer receiver.Close()
+
+	cfg := config.Default(homeDir)
+	cfg.Webhooks = []webhook.Rule{{Name: "all", URL: receiver.URL, Topics: []string{"*"}}}
+	for _, dir := range []string{cfg.TaskDirectory, cfg.PluginDirectory, cfg.StateDirectory} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	o, err := NewStandalone(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := o.invoke(context.Background(), "keyval", "set", map[string]interface{}{"key": "name", "val": "orc"}); err != nil {
+		t.Fatal(err)
+	}
+	o.events.Publish(context.Background(), events.TopicTaskFailed, nil)
+	if err := o.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The runs are not posted to the webhooks of the daemon.
+	if n := atomic.LoadInt32(&posted); n != 0 {
+		t.Errorf("expected no webhook deliveries, got %d", n)
+	}
+
+	// Nor do they serve the routes of the daemon.
+	for _, target := range []string{"/", hook.Path + "deploy"} {
+		rec := httptest.NewRecorder()
+		o.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected %s not to be served, got %d", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package schema
+
+// Route exposes an action on a resource-style path, relative to its module.
+// Path segments in braces are mapped to the argument of the same name,
+// e.g. {Method: "GET", Path: "keys/{key}"} on the keyval module serves GET /keyval/keys/{key}.
+type Route struct {
+	Method string
+	Path   string
+
+	// Body is the argument receiving the JSON request body.
+	// When empty, the body is a JSON object of arguments.
+	Body string
+}
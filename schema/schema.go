@@ -0,0 +1,256 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/dalloriam/orc/apierr"
+)
+
+// Type is the type of an argument, named after the JSON types.
+type Type string
+
+// Supported argument types.
+const (
+	TypeAny     Type = "any"
+	TypeString  Type = "string"
+	TypeNumber  Type = "number"
+	TypeInteger Type = "integer"
+	TypeBoolean Type = "boolean"
+	TypeObject  Type = "object"
+	TypeArray   Type = "array"
+)
+
+// Field describes an action argument.
+type Field struct {
+	Name        string        `json:"name"`
+	Type        Type          `json:"type"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Default     interface{}   `json:"default,omitempty"`
+}
+
+// Schema describes the arguments of an action.
+// Arguments that are not declared are passed through without validation.
+type Schema struct {
+	Arguments []Field `json:"arguments"`
+
+	// ReadOnly declares that the action has no side effects, it is then also served on GET.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// New returns a schema declaring the fields.
+func New(fields ...Field) Schema {
+	return Schema{Arguments: append([]Field{}, fields...)}
+}
+
+// NewReadOnly returns the schema of a read-only action declaring the fields.
+func NewReadOnly(fields ...Field) Schema {
+	s := New(fields...)
+	s.ReadOnly = true
+	return s
+}
+
+// Field returns the declaration of an argument.
+func (s Schema) Field(name string) (Field, bool) {
+	for _, f := range s.Arguments {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Check ensures the schema itself is valid.
+func (s Schema) Check() error {
+	seen := make(map[string]bool)
+
+	for _, f := range s.Arguments {
+		if f.Name == "" {
+			return fmt.Errorf("argument without name")
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("argument declared twice: %s", f.Name)
+		}
+		seen[f.Name] = true
+
+		if !f.Type.valid() {
+			return fmt.Errorf("invalid type for argument %s: %s", f.Name, f.Type)
+		}
+
+		if f.Default != nil {
+			if err := f.check(f.Default); err != nil {
+				return fmt.Errorf("invalid default for argument %s: %s", f.Name, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the payload against the schema and returns a copy of it with
+// the defaults applied. All the invalid arguments are reported in the "fields"
+// detail of the returned error.
+func (s Schema) Validate(data map[string]interface{}) (map[string]interface{}, error) {
+	validated := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		validated[k] = v
+	}
+
+	problems := make(map[string]string)
+
+	for _, f := range s.Arguments {
+		val, ok := validated[f.Name]
+		if !ok || val == nil {
+			if f.Default != nil {
+				validated[f.Name] = f.Default
+			} else if f.Required {
+				problems[f.Name] = "required"
+			}
+			continue
+		}
+
+		if err := f.check(val); err != nil {
+			problems[f.Name] = err.Error()
+		}
+	}
+
+	if len(problems) > 0 {
+		names := make([]string, 0, len(problems))
+		for name := range problems {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		message := "invalid arguments"
+		if len(names) == 1 {
+			message = fmt.Sprintf("invalid argument %s: %s", names[0], problems[names[0]])
+		}
+
+		return nil, apierr.InvalidArgument("%s", message).WithDetail("fields", problems)
+	}
+
+	// Keep empty payloads empty, plugins only receive arguments when some were given.
+	if data == nil && len(validated) == 0 {
+		return nil, nil
+	}
+
+	return validated, nil
+}
+
+// Parse converts a command-line value to the type of the field.
+func (f Field) Parse(raw string) (interface{}, error) {
+	var (
+		val interface{}
+		err error
+	)
+
+	switch f.Type {
+	case TypeString:
+		val = raw
+	case TypeNumber:
+		val, err = strconv.ParseFloat(raw, 64)
+	case TypeInteger:
+		val, err = strconv.ParseInt(raw, 10, 64)
+	case TypeBoolean:
+		val, err = strconv.ParseBool(raw)
+	case TypeObject, TypeArray:
+		err = json.Unmarshal([]byte(raw), &val)
+	default:
+		// Values of arguments without a type are parsed as JSON when possible.
+		if json.Unmarshal([]byte(raw), &val) != nil {
+			val = raw
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: expected %s", f.Name, f.Type)
+	}
+
+	return val, nil
+}
+
+func (f Field) check(val interface{}) error {
+	if !f.Type.matches(val) {
+		return fmt.Errorf("expected %s, got %s", f.Type, typeOf(val))
+	}
+
+	if len(f.Enum) == 0 {
+		return nil
+	}
+
+	for _, allowed := range f.Enum {
+		if equal(allowed, val) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("must be one of %v", f.Enum)
+}
+
+func (t Type) valid() bool {
+	switch t {
+	case TypeAny, TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeObject, TypeArray:
+		return true
+	}
+	return false
+}
+
+// matches returns whether the value, as decoded from JSON, is of the type.
+func (t Type) matches(val interface{}) bool {
+	switch t {
+	case TypeAny:
+		return true
+	case TypeInteger:
+		f, ok := toFloat(val)
+		return ok && f == math.Trunc(f)
+	}
+	return typeOf(val) == t
+}
+
+func typeOf(val interface{}) Type {
+	if _, ok := toFloat(val); ok {
+		return TypeNumber
+	}
+
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.String:
+		return TypeString
+	case reflect.Bool:
+		return TypeBoolean
+	case reflect.Map, reflect.Struct:
+		return TypeObject
+	case reflect.Slice, reflect.Array:
+		return TypeArray
+	}
+
+	return TypeAny
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// equal compares values regardless of their numeric type.
+func equal(a, b interface{}) bool {
+	af, aOk := toFloat(a)
+	bf, bOk := toFloat(b)
+	if aOk && bOk {
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
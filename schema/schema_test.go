@@ -0,0 +1,151 @@
+package schema_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/schema"
+)
+
+var testSchema = schema.New(
+	schema.Field{Name: "key", Type: schema.TypeString, Required: true},
+	schema.Field{Name: "count", Type: schema.TypeInteger, Default: 1},
+	schema.Field{Name: "mode", Type: schema.TypeString, Enum: []interface{}{"fast", "slow"}},
+	schema.Field{Name: "val", Type: schema.TypeAny},
+)
+
+func TestSchema_Validate(t *testing.T) {
+	type testCase struct {
+		name string
+		data map[string]interface{}
+
+		expected       map[string]interface{}
+		expectedFields map[string]string
+	}
+
+	cases := []testCase{
+		{
+			"applies defaults",
+			map[string]interface{}{"key": "hello"},
+			map[string]interface{}{"key": "hello", "count": 1},
+			nil,
+		},
+		{
+			"keeps unknown arguments",
+			map[string]interface{}{"key": "hello", "count": 3.0, "extra": true},
+			map[string]interface{}{"key": "hello", "count": 3.0, "extra": true},
+			nil,
+		},
+		{
+			"accepts any type",
+			map[string]interface{}{"key": "hello", "val": []interface{}{1.0}},
+			map[string]interface{}{"key": "hello", "count": 1, "val": []interface{}{1.0}},
+			nil,
+		},
+		{
+			"rejects missing required argument",
+			map[string]interface{}{},
+			nil,
+			map[string]string{"key": "required"},
+		},
+		{
+			"rejects wrong types",
+			map[string]interface{}{"key": 42.0, "count": 1.5},
+			nil,
+			map[string]string{"key": "expected string, got number", "count": "expected integer, got number"},
+		},
+		{
+			"rejects values outside enum",
+			map[string]interface{}{"key": "hello", "mode": "medium"},
+			nil,
+			map[string]string{"mode": "must be one of [fast slow]"},
+		},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			actual, err := testSchema.Validate(tCase.data)
+
+			if tCase.expectedFields == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err.Error())
+				}
+				if !reflect.DeepEqual(actual, tCase.expected) {
+					t.Errorf("expected %v, got %v", tCase.expected, actual)
+				}
+				return
+			}
+
+			apiErr, ok := err.(*apierr.Error)
+			if !ok || apiErr.Code != apierr.CodeInvalidArgument {
+				t.Fatalf("expected invalid argument error, got err=%v", err)
+			}
+
+			if !reflect.DeepEqual(apiErr.Details["fields"], tCase.expectedFields) {
+				t.Errorf("expected fields %v, got %v", tCase.expectedFields, apiErr.Details["fields"])
+			}
+		})
+	}
+}
+
+func TestSchema_Check(t *testing.T) {
+	type testCase struct {
+		name   string
+		schema schema.Schema
+
+		wantErr bool
+	}
+
+	cases := []testCase{
+		{"valid schema", testSchema, false},
+		{"unknown type", schema.New(schema.Field{Name: "key", Type: "text"}), true},
+		{"missing name", schema.New(schema.Field{Type: schema.TypeString}), true},
+		{"duplicate argument", schema.New(schema.Field{Name: "a", Type: schema.TypeAny}, schema.Field{Name: "a", Type: schema.TypeAny}), true},
+		{"bad default", schema.New(schema.Field{Name: "a", Type: schema.TypeBoolean, Default: "yes"}), true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if err := tCase.schema.Check(); (err != nil) != tCase.wantErr {
+				t.Errorf("expected err: %v, got err=%v", tCase.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestField_Parse(t *testing.T) {
+	type testCase struct {
+		fieldType schema.Type
+		raw       string
+
+		expected interface{}
+		wantErr  bool
+	}
+
+	cases := []testCase{
+		{schema.TypeString, "42", "42", false},
+		{schema.TypeNumber, "4.5", 4.5, false},
+		{schema.TypeInteger, "42", int64(42), false},
+		{schema.TypeInteger, "4.5", nil, true},
+		{schema.TypeBoolean, "true", true, false},
+		{schema.TypeBoolean, "maybe", nil, true},
+		{schema.TypeArray, `["a"]`, []interface{}{"a"}, false},
+		{schema.TypeAny, "12", 12.0, false},
+		{schema.TypeAny, "hello", "hello", false},
+	}
+
+	for _, tCase := range cases {
+		t.Run(string(tCase.fieldType)+"/"+tCase.raw, func(t *testing.T) {
+			actual, err := schema.Field{Name: "arg", Type: tCase.fieldType}.Parse(tCase.raw)
+
+			if (err != nil) != tCase.wantErr {
+				t.Fatalf("expected err: %v, got err=%v", tCase.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(actual, tCase.expected) {
+				t.Errorf("expected %#v, got %#v", tCase.expected, actual)
+			}
+		})
+	}
+}
@@ -4,33 +4,22 @@ import (
 	"context"
 	"flag"
 	"os"
-	"os/user"
-	"path"
+	"os/signal"
+	"syscall"
 
+	"github.com/dalloriam/orc/auth"
+	"github.com/dalloriam/orc/config"
 	"github.com/dalloriam/orc/interfaces"
+	"github.com/dalloriam/orc/tracing"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	serverCommandName = "server"
-	serverCommandArgs = "[--docker-defs /path/to/docker/defs/directory] [--plugin-dir /path/to/plugin/dir]"
+	serverCommandArgs = "[--config /path/to/config.json] [--docker_defs_path /path/to/docker/defs/directory] [--plugins_dir /path/to/plugin/dir] [--listen tcp://host:port|tls://host:port|unix:///path/to/socket]..."
 	serverCommandHelp = "Starts the ORC server."
-
-	defaultDockerPathSuffix = ".config/dalloriam/orc/docker"
-	defaultPluginDirSuffix  = ".config/dalloriam/orc/plugins"
-
-	serverHost = "0.0.0.0"
-	serverPort = 33000
 )
 
-func getHomeDir() (string, error) {
-	usr, err := user.Current()
-	if err != nil {
-		return "", err
-	}
-	return usr.HomeDir, nil
-}
-
 func createDirIfNotExists(dirPath string) error {
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
 		if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
@@ -41,8 +30,10 @@ func createDirIfNotExists(dirPath string) error {
 }
 
 type serverCommand struct {
-	dockerDefsDir string
-	pluginsDir    string
+	settings  *settings
+	listeners []interfaces.Listener
+
+	exporter *tracing.Exporter
 }
 
 func (cmd *serverCommand) Name() string      { return serverCommandName }
@@ -52,43 +43,155 @@ func (cmd *serverCommand) LongHelp() string  { return serverCommandHelp }
 func (cmd *serverCommand) Hidden() bool      { return false }
 
 func (cmd *serverCommand) Register(fs *flag.FlagSet) {
-	fs.StringVar(&cmd.dockerDefsDir, "docker_defs_path", "", "Path to docker definitions directory. (defaults to ~/.config/dalloriam/orc/docker)")
-	fs.StringVar(&cmd.pluginsDir, "plugins_dir", "", "Path to the plugins directory. (defaults to ~/.config/dalloriam/orc/plugins)")
+	cmd.settings.Register(fs)
 }
 
 func (cmd *serverCommand) Run(ctx context.Context, args []string) error {
-	if cmd.dockerDefsDir == "" {
-		homeDir, err := getHomeDir()
-		if err != nil {
+	cfg, err := cmd.settings.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	level, err := cfg.Level()
+	if err != nil {
+		return err
+	}
+	log.SetLevel(level)
+
+	listeners, err := cfg.ServerListeners()
+	if err != nil {
+		return err
+	}
+	cmd.listeners = listeners
+
+	for _, dir := range []string{cfg.TaskDirectory, cfg.PluginDirectory, cfg.StateDirectory} {
+		if err := createDirIfNotExists(dir); err != nil {
 			return err
 		}
+	}
 
-		cmd.dockerDefsDir = path.Join(homeDir, defaultDockerPathSuffix)
+	tokens, err := cfg.Tokens()
+	if err != nil {
+		return err
+	}
+	if err := cfg.CheckAuthentication(listeners, tokens); err != nil {
+		return err
+	}
+	authenticator := auth.NewAuthenticator(tokens)
+	if !authenticator.Enabled() {
+		log.Warn("no API tokens configured, API authentication is DISABLED")
 	}
 
-	if cmd.pluginsDir == "" {
-		homeDir, err := getHomeDir()
-		if err != nil {
+	if cfg.Tracing.OTLPEndpoint != "" {
+		log.Infof("exporting traces to %s", cfg.Tracing.OTLPEndpoint)
+		cmd.exporter = tracing.NewExporter(cfg.Tracing.OTLPEndpoint, cfg.Tracing.ServiceName)
+		tracing.SetExporter(cmd.exporter)
+	}
+
+	o, err := New(cfg, interfaces.NewServer(authenticator))
+
+	if err != nil {
+		return err
+	}
+	log.AddHook(o.Logs())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- o.Serve(listeners)
+	}()
+
+	for {
+		select {
+		case err := <-serveErr:
+			// The server stopped without being asked to.
+			if shutdownErr := cmd.shutdown(o, cfg); err == nil {
+				err = shutdownErr
+			}
 			return err
+
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				if err := cmd.reload(o, authenticator); err != nil {
+					log.Errorf("error reloading configuration, keeping previous state: %s", err.Error())
+				}
+				continue
+			}
+
+			log.Infof("received %s, shutting down...", sig)
+			if err := cmd.shutdown(o, cfg); err != nil {
+				return err
+			}
+			log.Info("shutdown complete")
+			return nil
+		}
+	}
+}
+
+func (cmd *serverCommand) shutdown(o *Orc, cfg *config.Config) error {
+	gracePeriod, err := cfg.ShutdownGracePeriod()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	err = o.Shutdown(ctx)
+
+	// Spans are flushed last, so that the ones of drained requests are exported.
+	if cmd.exporter != nil {
+		tracing.SetExporter(nil)
+		if exportErr := cmd.exporter.Shutdown(ctx); exportErr != nil {
+			log.Warnf("error exporting spans: %s", exportErr.Error())
 		}
-		cmd.pluginsDir = path.Join(homeDir, defaultPluginDirSuffix)
 	}
 
-	if err := createDirIfNotExists(cmd.dockerDefsDir); err != nil {
+	return err
+}
+
+func (cmd *serverCommand) reload(o *Orc, authenticator *auth.Authenticator) error {
+	log.Info("received SIGHUP, reloading...")
+
+	cfg, err := cmd.settings.Reload()
+	if err != nil {
 		return err
 	}
 
-	if err := createDirIfNotExists(cmd.pluginsDir); err != nil {
+	if err := cfg.Validate(); err != nil {
 		return err
 	}
 
-	o, err := New(cmd.dockerDefsDir, cmd.pluginsDir, interfaces.HandleWithHTTP)
+	level, err := cfg.Level()
+	if err != nil {
+		return err
+	}
+	log.SetLevel(level)
 
+	tokens, err := cfg.Tokens()
 	if err != nil {
 		return err
 	}
+	// Listener changes require a restart, the running listeners are the ones exposed.
+	if err := cfg.CheckAuthentication(cmd.listeners, tokens); err != nil {
+		return err
+	}
+	authenticator.SetTokens(tokens)
+	if !authenticator.Enabled() {
+		log.Warn("no API tokens configured, API authentication is DISABLED")
+	}
 
-	log.Fatal(o.Serve(serverHost, serverPort))
+	if err := o.Reload(cfg); err != nil {
+		return err
+	}
 
+	log.Info("reload complete, listener, directory, grpc and tracing changes require a restart")
 	return nil
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/dalloriam/orc/config"
+	"github.com/dalloriam/orc/interfaces"
+)
+
+// settings resolves the effective server configuration.
+// Precedence is: flags > environment variables > configuration file > defaults.
+type settings struct {
+	configFile string
+
+	taskDir   string
+	pluginDir string
+	stateDir  string
+	authFile  string
+	logLevel  string
+
+	listeners   stringSlice
+	tlsCert     string
+	tlsKey      string
+	tlsClientCA string
+	socketMode  string
+
+	cfg *config.Config
+}
+
+// Register registers the configuration override flags.
+func (s *settings) Register(fs *flag.FlagSet) {
+	fs.StringVar(&s.configFile, "config", "", "Path to the configuration file. (defaults to ~/.config/dalloriam/orc/config.json)")
+
+	fs.StringVar(&s.taskDir, "docker_defs_path", "", "Path to docker definitions directory. (defaults to ~/.config/dalloriam/orc/docker)")
+	fs.StringVar(&s.pluginDir, "plugins_dir", "", "Path to the plugins directory. (defaults to ~/.config/dalloriam/orc/plugins)")
+	fs.StringVar(&s.stateDir, "state_dir", "", "Path to the directory where state is persisted. (defaults to ~/.config/dalloriam/orc/state)")
+	fs.StringVar(&s.authFile, "auth_file", "", "Path to the API token file. (defaults to ~/.config/dalloriam/orc/auth.json)")
+	fs.StringVar(&s.logLevel, "log_level", "", "Log level. (defaults to info)")
+
+	fs.Var(&s.listeners, "listen", "Listener to serve on (tcp://host:port, tls://host:port or unix:///path), can be repeated.")
+	fs.StringVar(&s.tlsCert, "tls_cert", "", "Path to the certificate used by tls:// listeners.")
+	fs.StringVar(&s.tlsKey, "tls_key", "", "Path to the private key used by tls:// listeners.")
+	fs.StringVar(&s.tlsClientCA, "tls_client_ca", "", "Path to a CA bundle. When set, tls:// listeners require client certificates signed by it.")
+	fs.StringVar(&s.socketMode, "socket_mode", "", "Permissions of unix:// listener sockets. (defaults to 0600)")
+}
+
+// Load resolves the configuration. The result is cached for subsequent calls.
+func (s *settings) Load() (*config.Config, error) {
+	if s.cfg != nil {
+		return s.cfg, nil
+	}
+	return s.Reload()
+}
+
+// Reload resolves the configuration again, picking up changes to the configuration file.
+func (s *settings) Reload() (*config.Config, error) {
+	cfgPath := s.configFile
+	mustExist := cfgPath != "" || os.Getenv(config.EnvConfigFile) != ""
+	if cfgPath == "" {
+		defaultPath, err := config.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		cfgPath = defaultPath
+	}
+
+	cfg, err := config.Load(cfgPath, mustExist)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ApplyEnv()
+	s.applyFlags(cfg)
+
+	s.cfg = cfg
+	return cfg, nil
+}
+
+func (s *settings) applyFlags(cfg *config.Config) {
+	overrides := []struct {
+		value string
+		field *string
+	}{
+		{s.taskDir, &cfg.TaskDirectory},
+		{s.pluginDir, &cfg.PluginDirectory},
+		{s.stateDir, &cfg.StateDirectory},
+		{s.authFile, &cfg.Auth.TokenFile},
+		{s.logLevel, &cfg.LogLevel},
+	}
+	for _, override := range overrides {
+		if override.value != "" {
+			*override.field = override.value
+		}
+	}
+
+	if len(s.listeners) > 0 {
+		cfg.SetListeners(s.listeners)
+	}
+
+	for i := range cfg.Listeners {
+		l := &cfg.Listeners[i]
+		if s.socketMode != "" {
+			l.SocketMode = s.socketMode
+		}
+
+		if s.tlsCert == "" && s.tlsKey == "" && s.tlsClientCA == "" {
+			continue
+		}
+
+		if parsed, err := interfaces.ParseListener(l.Listen); err != nil || parsed.Scheme != interfaces.SchemeTLS {
+			continue
+		}
+
+		if l.TLS == nil {
+			l.TLS = &interfaces.TLSConfig{}
+		}
+		if s.tlsCert != "" {
+			l.TLS.CertFile = s.tlsCert
+		}
+		if s.tlsKey != "" {
+			l.TLS.KeyFile = s.tlsKey
+		}
+		if s.tlsClientCA != "" {
+			l.TLS.ClientCAFile = s.tlsClientCA
+		}
+	}
+}
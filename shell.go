@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/config"
+	"github.com/dalloriam/orc/output"
+	"github.com/dalloriam/orc/shell"
+)
+
+const (
+	shellCommandName = "shell"
+	shellCommandHelp = "Open an interactive shell on the ORC server."
+	shellCommandArgs = "[OPTIONS]"
+
+	shellCommandLongHelp = shellCommandHelp + `
+
+Actions are invoked as MODULE ACTION [NAME=VALUE]..., and their results stored in
+variables with VAR = MODULE ACTION ...; type "help" in the shell for the syntax.
+Modules, actions and argument names are completed with Tab, and the history is
+kept in ~/` + defaultShellHistorySuffix + `.`
+
+	defaultShellHistorySuffix = ".config/dalloriam/orc/shell_history"
+
+	shellPrompt             = "orc> "
+	shellContinuationPrompt = "...  "
+)
+
+type shellCommand struct {
+	host    string
+	context string
+	format  string
+}
+
+func (cmd *shellCommand) Name() string      { return shellCommandName }
+func (cmd *shellCommand) Args() string      { return shellCommandArgs }
+func (cmd *shellCommand) ShortHelp() string { return shellCommandHelp }
+func (cmd *shellCommand) LongHelp() string  { return shellCommandLongHelp }
+func (cmd *shellCommand) Hidden() bool      { return false }
+func (cmd *shellCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.host, "H", "", fmt.Sprintf("Server to connect to, e.g. unix:///path/to/orc.sock (overrides $%s)", hostEnvVar))
+	fs.StringVar(&cmd.host, "host", "", fmt.Sprintf("Server to connect to, e.g. unix:///path/to/orc.sock (overrides $%s)", hostEnvVar))
+	fs.StringVar(&cmd.context, "context", "", "Context of the server to connect to, see orc context")
+	fs.StringVar(&cmd.format, "o", output.FormatPretty, "Output format of the results: json, pretty, table or yaml")
+	fs.StringVar(&cmd.format, "output", output.FormatPretty, "Output format of the results: json, pretty, table or yaml")
+}
+
+// historyPath returns the path of the history file, or an empty path if the home
+// directory is unknown.
+func (cmd *shellCommand) historyPath() string {
+	homeDir, err := config.HomeDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(homeDir, defaultShellHistorySuffix)
+}
+
+func (cmd *shellCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Invalid syntax")
+	}
+
+	c, err := (&cliCommand{host: cmd.host}).newClient(cmd.context)
+	if err != nil {
+		return err
+	}
+
+	session := shell.NewSession(c, os.Stdout)
+	if err := session.SetFormat(cmd.format); err != nil {
+		return err
+	}
+
+	// Completion is only missing when the server can't list its actions, the shell remains usable.
+	if err := session.LoadSchemas(); err != nil {
+		printShellError(err)
+	}
+
+	editor := shell.NewEditor(os.Stdin, os.Stdout, session.Complete)
+	historyPath := cmd.historyPath()
+	if historyPath != "" {
+		if err := editor.LoadHistory(historyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "error loading history: %s\n", err.Error())
+		}
+		defer func() {
+			os.MkdirAll(path.Dir(historyPath), 0700)
+			if err := editor.SaveHistory(historyPath); err != nil {
+				fmt.Fprintf(os.Stderr, "error saving history: %s\n", err.Error())
+			}
+		}()
+	}
+
+	for ctx.Err() == nil {
+		input, err := cmd.readInput(editor)
+		if err == shell.ErrInterrupted {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		editor.AddHistory(strings.Replace(input, "\n", " ", -1))
+
+		err = session.Execute(input)
+		if err == shell.ErrExit {
+			return nil
+		}
+		if err != nil {
+			printShellError(err)
+		}
+	}
+
+	return nil
+}
+
+// readInput reads a command, continuing on the next lines until its quotes
+// and JSON values are closed.
+func (cmd *shellCommand) readInput(editor *shell.Editor) (string, error) {
+	input, err := editor.ReadLine(shellPrompt)
+	if err != nil {
+		return "", err
+	}
+
+	for !shell.Complete(input) {
+		line, err := editor.ReadLine(shellContinuationPrompt)
+		if err != nil {
+			return "", err
+		}
+		input += "\n" + line
+	}
+
+	return input, nil
+}
+
+func printShellError(err error) {
+	if apiErr, ok := err.(*apierr.Error); ok {
+		printAPIError(os.Stderr, apiErr)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+}
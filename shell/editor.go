@@ -0,0 +1,354 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// maxHistory is the number of lines kept in the history.
+const maxHistory = 1000
+
+// ErrInterrupted is returned by ReadLine when the line is interrupted with Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// Keys of the line editor.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// Editor reads lines from a terminal, with emacs-style editing keys, history and
+// tab-completion. Input that is not a terminal is read line by line.
+type Editor struct {
+	in       *os.File
+	out      io.Writer
+	reader   *bufio.Reader
+	complete func(line string) []string
+	history  []string
+}
+
+// NewEditor returns an editor reading from in and echoing to out. Tab completes the
+// last word of the text before the cursor with the candidates returned by complete.
+func NewEditor(in *os.File, out io.Writer, complete func(line string) []string) *Editor {
+	return &Editor{
+		in:       in,
+		out:      out,
+		reader:   bufio.NewReader(in),
+		complete: complete,
+	}
+}
+
+// LoadHistory reads the history from a file, one line per entry.
+// A missing file yields an empty history.
+func (e *Editor) LoadHistory(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		e.AddHistory(line)
+	}
+	return nil
+}
+
+// SaveHistory writes the history to a file.
+func (e *Editor) SaveHistory(path string) error {
+	var data strings.Builder
+	for _, line := range e.history {
+		data.WriteString(line)
+		data.WriteByte('\n')
+	}
+	return ioutil.WriteFile(path, []byte(data.String()), 0600)
+}
+
+// AddHistory appends a line to the history, unless it is blank or repeats the last entry.
+func (e *Editor) AddHistory(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// ReadLine prompts for a line and returns it, without its line ending.
+// It returns io.EOF at the end of the input or on Ctrl-D, and ErrInterrupted on Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	fd := int(e.in.Fd())
+	if !isTerminal(fd) {
+		return e.readPlain()
+	}
+
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return e.readPlain()
+	}
+	defer restore()
+
+	return e.edit(prompt)
+}
+
+func (e *Editor) readPlain() (string, error) {
+	line, err := e.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// lineState is the line being edited.
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+func (l *lineState) insert(runes ...rune) {
+	buf := make([]rune, 0, len(l.buf)+len(runes))
+	buf = append(buf, l.buf[:l.pos]...)
+	buf = append(buf, runes...)
+	l.buf = append(buf, l.buf[l.pos:]...)
+	l.pos += len(runes)
+}
+
+// delete removes the runes between from and to and moves the cursor to from.
+func (l *lineState) delete(from, to int) {
+	if from < 0 || to > len(l.buf) || from >= to {
+		return
+	}
+	l.buf = append(l.buf[:from], l.buf[to:]...)
+	l.pos = from
+}
+
+func (l *lineState) set(line string) {
+	l.buf = []rune(line)
+	l.pos = len(l.buf)
+}
+
+func (e *Editor) refresh(l *lineState) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *Editor) edit(prompt string) (string, error) {
+	l := &lineState{prompt: prompt}
+	e.refresh(l)
+
+	// The line being typed is kept at the end of the history while browsing it.
+	history := append(append([]string{}, e.history...), "")
+	histPos := len(history) - 1
+
+	browse := func(to int) {
+		if to < 0 || to >= len(history) {
+			return
+		}
+		history[histPos] = string(l.buf)
+		histPos = to
+		l.set(history[histPos])
+	}
+
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			fmt.Fprint(e.out, "\r\n")
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyLineFeed:
+			fmt.Fprint(e.out, "\r\n")
+			return string(l.buf), nil
+
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+
+		case keyCtrlD:
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+
+		case keyBackspace, keyCtrlH:
+			l.delete(l.pos-1, l.pos)
+
+		case keyCtrlA:
+			l.pos = 0
+
+		case keyCtrlE:
+			l.pos = len(l.buf)
+
+		case keyCtrlB:
+			if l.pos > 0 {
+				l.pos--
+			}
+
+		case keyCtrlF:
+			if l.pos < len(l.buf) {
+				l.pos++
+			}
+
+		case keyCtrlK:
+			l.buf = l.buf[:l.pos]
+
+		case keyCtrlU:
+			l.delete(0, l.pos)
+
+		case keyCtrlW:
+			start := l.pos
+			for start > 0 && l.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && l.buf[start-1] != ' ' {
+				start--
+			}
+			l.delete(start, l.pos)
+
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+
+		case keyCtrlP:
+			browse(histPos - 1)
+
+		case keyCtrlN:
+			browse(histPos + 1)
+
+		case keyTab:
+			e.completeLine(l)
+
+		case keyEscape:
+			switch e.readEscape() {
+			case 'A':
+				browse(histPos - 1)
+			case 'B':
+				browse(histPos + 1)
+			case 'C':
+				if l.pos < len(l.buf) {
+					l.pos++
+				}
+			case 'D':
+				if l.pos > 0 {
+					l.pos--
+				}
+			case 'H':
+				l.pos = 0
+			case 'F':
+				l.pos = len(l.buf)
+			case '~':
+				l.delete(l.pos, l.pos+1)
+			}
+
+		default:
+			if r >= ' ' {
+				l.insert(r)
+			}
+		}
+
+		e.refresh(l)
+	}
+}
+
+// readEscape reads the escape sequence of a special key, and returns A, B, C and D
+// for the arrows, H and F for home and end, and ~ for delete.
+func (e *Editor) readEscape() rune {
+	r, _, err := e.reader.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+
+	r, _, err = e.reader.ReadRune()
+	if err != nil {
+		return 0
+	}
+	if r < '0' || r > '9' {
+		return r
+	}
+
+	// Sequences such as ESC [ 3 ~ identify keys by number.
+	num := r
+	for {
+		r, _, err = e.reader.ReadRune()
+		if err != nil || r == '~' {
+			break
+		}
+	}
+
+	switch num {
+	case '1', '7':
+		return 'H'
+	case '4', '8':
+		return 'F'
+	case '3':
+		return '~'
+	}
+	return 0
+}
+
+// completeLine completes the word before the cursor with the longest prefix shared
+// by its candidates, and lists them when that doesn't complete anything.
+func (e *Editor) completeLine(l *lineState) {
+	if e.complete == nil {
+		return
+	}
+
+	before := string(l.buf[:l.pos])
+	candidates := e.complete(before)
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+
+	word := before[strings.LastIndexAny(before, " \t")+1:]
+	prefix := commonPrefix(candidates)
+	if len(candidates) == 1 && !strings.HasSuffix(prefix, "=") {
+		prefix += " "
+	}
+
+	if len(prefix) > len(word) && strings.HasPrefix(prefix, word) {
+		l.insert([]rune(prefix[len(word):])...)
+		return
+	}
+
+	fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
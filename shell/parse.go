@@ -0,0 +1,119 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Split splits a command line into words. Words are separated by spaces, and may
+// be quoted with single or double quotes or escaped with backslashes, as in a shell.
+// JSON objects and arrays are kept verbatim, including their quotes and spaces,
+// e.g. val={"name": "web"} is a single word.
+func Split(line string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+	)
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case c == '\\':
+			if i+1 < len(runes) {
+				i++
+				word.WriteRune(runes[i])
+			}
+			inWord = true
+
+		case c == '"' || c == '\'':
+			end := indexRune(runes, i+1, c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+			inWord = true
+
+		case (c == '{' || c == '[') && (!inWord || strings.HasSuffix(word.String(), "=")):
+			end := jsonEnd(runes, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated JSON value")
+			}
+			word.WriteString(string(runes[i : end+1]))
+			i = end
+			inWord = true
+
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// Complete returns whether the input can be executed: its quotes are closed, and
+// so are the braces and brackets of its JSON values. Incomplete input is continued
+// on the next line, so that JSON values can span several lines.
+func Complete(input string) bool {
+	_, err := Split(input)
+	return err == nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// jsonEnd returns the index of the brace or bracket closing the JSON value
+// starting at start, or -1 if the value is not closed.
+func jsonEnd(runes []rune, start int) int {
+	depth := 0
+	inString := false
+
+	for i := start; i < len(runes); i++ {
+		c := runes[i]
+
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
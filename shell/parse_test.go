@@ -0,0 +1,53 @@
+package shell_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dalloriam/orc/shell"
+)
+
+func TestSplit(t *testing.T) {
+	type testCase struct {
+		name string
+		line string
+
+		expected []string
+		wantErr  bool
+	}
+
+	cases := []testCase{
+		{"empty", "  ", nil, false},
+		{"words", "keyval get  key=name", []string{"keyval", "get", "key=name"}, false},
+		{"double quotes", `keyval set value="hello world"`, []string{"keyval", "set", "value=hello world"}, false},
+		{"single quotes", `echo 'say "hi"'`, []string{"echo", `say "hi"`}, false},
+		{"escape", `a\ b c\=d`, []string{"a b", "c=d"}, false},
+		{"json argument", `mod act {"name": "web", "ports": [80, 443]}`, []string{"mod", "act", `{"name": "web", "ports": [80, 443]}`}, false},
+		{"json value", `mod act tags=["a b", "c]"] x=1`, []string{"mod", "act", `tags=["a b", "c]"]`, "x=1"}, false},
+		{"multi-line json", "mod act {\n  \"a\": 1\n}", []string{"mod", "act", "{\n  \"a\": 1\n}"}, false},
+		{"brace within word", "a{b", []string{"a{b"}, false},
+		{"unterminated quote", `echo "hi`, nil, true},
+		{"unterminated json", `mod act {"a": [1, 2}`, nil, true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			actual, err := shell.Split(tCase.line)
+			if (err != nil) != tCase.wantErr {
+				t.Fatalf("expected error=%v, got %v", tCase.wantErr, err)
+			}
+			if !reflect.DeepEqual(actual, tCase.expected) {
+				t.Errorf("expected %q, got %q", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	if shell.Complete(`mod act {"a":`) {
+		t.Errorf("expected open JSON value to be incomplete")
+	}
+	if !shell.Complete(`mod act {"a": "}"}`) {
+		t.Errorf("expected closed JSON value to be complete")
+	}
+}
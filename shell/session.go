@@ -0,0 +1,403 @@
+package shell
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/hook"
+	"github.com/dalloriam/orc/output"
+	"github.com/dalloriam/orc/schema"
+)
+
+// lastResult is the variable holding the result of the last action.
+const lastResult = "_"
+
+// ErrExit is returned by Execute when the user leaves the shell.
+var ErrExit = errors.New("exit")
+
+// Help describes the syntax of the shell.
+const Help = `Usage:
+  MODULE ACTION [NAME=VALUE]... [{JSON ARGUMENTS}]   invoke an action
+  VAR = MODULE ACTION ...                            invoke an action and store its result
+
+Values are converted to the types declared by the action, and may be JSON objects
+or arrays spanning several lines. Results are stored in $_, and in $VAR when
+assigned. Variables are used as values with $VAR or $VAR.path[0].field, or
+within strings with ${VAR.path}.
+
+Commands:
+  actions [MODULE]                list the actions and their arguments
+  modules                         list the modules
+  vars                            list the variables
+  output json|pretty|table|yaml   set the output format
+  help                            show this help
+  exit                            leave the shell`
+
+var (
+	identifier    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	reference     = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)((?:\.[^.\[]+|\[\d+\])*)$`)
+	interpolation = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)((?:\.[^.\[}]+|\[\d+\])*)\}`)
+)
+
+var builtins = []string{"actions", "exit", "help", "modules", "output", "quit", "vars"}
+
+// Caller invokes the actions of the server.
+type Caller interface {
+	Call(module, action string, body interface{}) ([]byte, error)
+}
+
+// Session executes the command lines of the shell against the server, keeping
+// the variables and the output format between them.
+type Session struct {
+	caller Caller
+	out    io.Writer
+
+	format  string
+	vars    map[string]interface{}
+	schemas map[string]map[string]schema.Schema
+}
+
+// NewSession returns a session printing the results to out.
+func NewSession(caller Caller, out io.Writer) *Session {
+	return &Session{
+		caller:  caller,
+		out:     out,
+		format:  output.FormatPretty,
+		vars:    make(map[string]interface{}),
+		schemas: make(map[string]map[string]schema.Schema),
+	}
+}
+
+// LoadSchemas fetches the actions of the server and their arguments, which are
+// used to convert the arguments and to complete the command lines.
+func (s *Session) LoadSchemas() error {
+	out, err := s.caller.Call("manage", "actions_available", map[string]interface{}{"detailed": true})
+	if err != nil {
+		return err
+	}
+
+	schemas := make(map[string]map[string]schema.Schema)
+	if err := json.Unmarshal(out, &schemas); err != nil {
+		return err
+	}
+
+	s.schemas = schemas
+	return nil
+}
+
+// SetFormat sets the output format of the results.
+func (s *Session) SetFormat(format string) error {
+	if !output.Valid(format) {
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+	s.format = format
+	return nil
+}
+
+// Execute runs a command line.
+func (s *Session) Execute(line string) error {
+	words, err := Split(line)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	target := ""
+	if len(words) >= 2 && words[1] == "=" {
+		target = words[0]
+		if !identifier.MatchString(target) || target == lastResult {
+			return fmt.Errorf("invalid variable name: %s", target)
+		}
+		words = words[2:]
+	} else if handled, err := s.builtin(words); handled {
+		return err
+	}
+
+	if len(words) < 2 {
+		return fmt.Errorf("expected MODULE ACTION, see help")
+	}
+
+	module, action := words[0], words[1]
+	args, err := s.arguments(module, action, words[2:])
+	if err != nil {
+		return err
+	}
+
+	out, err := s.caller.Call(module, action, args)
+	if err != nil {
+		return err
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(out, &result); err != nil {
+		result = string(out)
+	}
+
+	s.vars[lastResult] = result
+	if target != "" {
+		s.vars[target] = result
+	}
+
+	return s.print(result)
+}
+
+func (s *Session) builtin(words []string) (bool, error) {
+	switch words[0] {
+	case "exit", "quit":
+		return true, ErrExit
+
+	case "help":
+		fmt.Fprintln(s.out, Help)
+		return true, nil
+
+	case "output":
+		if len(words) != 2 {
+			fmt.Fprintln(s.out, s.format)
+			return true, nil
+		}
+		return true, s.SetFormat(words[1])
+
+	case "vars":
+		names := make([]string, 0, len(s.vars))
+		for name := range s.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		vars := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			vars["$"+name] = s.vars[name]
+		}
+		return true, s.print(vars)
+
+	case "modules":
+		if err := s.LoadSchemas(); err != nil {
+			return true, err
+		}
+		fmt.Fprintln(s.out, strings.Join(s.modules(), "\n"))
+		return true, nil
+
+	case "actions":
+		if err := s.LoadSchemas(); err != nil {
+			return true, err
+		}
+		return true, s.printActions(words[1:])
+	}
+
+	return false, nil
+}
+
+func (s *Session) printActions(modules []string) error {
+	if len(modules) == 0 {
+		modules = s.modules()
+	}
+
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	for _, module := range modules {
+		actions, ok := s.schemas[module]
+		if !ok {
+			return apierr.NotFound("unknown module: %s", module)
+		}
+
+		for _, action := range sortedKeys(actions) {
+			var args []string
+			for _, field := range actions[action].Arguments {
+				arg := fmt.Sprintf("%s=%s", field.Name, field.Type)
+				if !field.Required {
+					arg = "[" + arg + "]"
+				}
+				args = append(args, arg)
+			}
+			fmt.Fprintf(w, "%s %s\t%s\n", module, action, strings.Join(args, " "))
+		}
+	}
+	return w.Flush()
+}
+
+func (s *Session) modules() []string {
+	return sortedKeys(s.schemas)
+}
+
+// arguments converts the words following the action into its arguments.
+func (s *Session) arguments(module, action string, words []string) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	actionSchema := s.schemas[module][action]
+
+	for _, word := range words {
+		if strings.HasPrefix(word, "{") {
+			var body map[string]interface{}
+			if err := json.Unmarshal([]byte(word), &body); err != nil {
+				return nil, apierr.InvalidArgument("invalid JSON arguments: %s", err.Error())
+			}
+			for name, val := range body {
+				args[name] = val
+			}
+			continue
+		}
+
+		parts := strings.SplitN(word, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, apierr.InvalidArgument("invalid argument syntax: %s, expected NAME=VALUE", word)
+		}
+
+		val, err := s.value(actionSchema, parts[0], parts[1])
+		if err != nil {
+			return nil, err
+		}
+		args[parts[0]] = val
+	}
+
+	return args, nil
+}
+
+// value converts the raw value of an argument.
+func (s *Session) value(actionSchema schema.Schema, name, raw string) (interface{}, error) {
+	if m := reference.FindStringSubmatch(raw); m != nil {
+		return s.lookup(m[1], m[2])
+	}
+
+	if strings.HasPrefix(raw, "{") || strings.HasPrefix(raw, "[") {
+		var val interface{}
+		if err := json.Unmarshal([]byte(raw), &val); err != nil {
+			return nil, apierr.InvalidArgument("invalid JSON value: %s", err.Error()).WithDetail("argument", name)
+		}
+		return val, nil
+	}
+
+	var lookupErr error
+	raw = interpolation.ReplaceAllStringFunc(raw, func(match string) string {
+		m := interpolation.FindStringSubmatch(match)
+		val, err := s.lookup(m[1], m[2])
+		if err != nil {
+			lookupErr = err
+			return ""
+		}
+		if str, ok := val.(string); ok {
+			return str
+		}
+		data, _ := json.Marshal(val)
+		return string(data)
+	})
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+
+	field, ok := actionSchema.Field(name)
+	if !ok {
+		return raw, nil
+	}
+
+	val, err := field.Parse(raw)
+	if err != nil {
+		return nil, apierr.InvalidArgument("%s", err.Error()).WithDetail("argument", name)
+	}
+	return val, nil
+}
+
+// lookup returns the value at a path of a variable, e.g. ".tasks[0].name".
+func (s *Session) lookup(name, path string) (interface{}, error) {
+	doc, ok := s.vars[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable: $%s", name)
+	}
+
+	val, ok := hook.Extract(doc, "$"+path)
+	if !ok {
+		return nil, fmt.Errorf("no value at $%s%s", name, path)
+	}
+	return val, nil
+}
+
+// Complete returns the completions of the last word of the line: the commands
+// and modules, then the actions of the module, then the names of its arguments
+// and the variables.
+func (s *Session) Complete(line string) []string {
+	words := strings.Fields(line)
+	current := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	assignment := len(words) >= 2 && words[1] == "="
+	if assignment {
+		words = words[2:]
+	}
+
+	var candidates []string
+	switch {
+	case strings.Contains(current, "$"):
+		prefix := current[:strings.LastIndex(current, "$")+1]
+		for _, name := range sortedKeys(s.vars) {
+			candidates = append(candidates, prefix+name)
+		}
+
+	case len(words) == 0:
+		candidates = s.modules()
+		if !assignment {
+			candidates = append(candidates, builtins...)
+		}
+
+	case len(words) == 1 && words[0] == "output" && !assignment:
+		candidates = output.Formats
+
+	case len(words) == 1 && words[0] == "actions" && !assignment:
+		candidates = s.modules()
+
+	case len(words) == 1:
+		candidates = sortedKeys(s.schemas[words[0]])
+
+	default:
+		given := make(map[string]bool)
+		for _, word := range words[2:] {
+			given[strings.SplitN(word, "=", 2)[0]] = true
+		}
+		for _, field := range s.schemas[words[0]][words[1]].Arguments {
+			if !given[field.Name] {
+				candidates = append(candidates, field.Name+"=")
+			}
+		}
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func (s *Session) print(result interface{}) error {
+	return output.Write(s.out, s.format, result)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]interface{}:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]schema.Schema:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]map[string]schema.Schema:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+package shell_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/output"
+	"github.com/dalloriam/orc/schema"
+	"github.com/dalloriam/orc/shell"
+)
+
+type call struct {
+	module string
+	action string
+	body   interface{}
+}
+
+// fakeCaller records the calls and replies with the result of the action.
+type fakeCaller struct {
+	calls   []call
+	results map[string]interface{}
+}
+
+func (c *fakeCaller) Call(module, action string, body interface{}) ([]byte, error) {
+	if module == "manage" && action == "actions_available" {
+		return json.Marshal(map[string]map[string]schema.Schema{
+			"keyval": {
+				"get": schema.NewReadOnly(schema.Field{Name: "key", Type: schema.TypeString, Required: true}),
+				"set": schema.New(
+					schema.Field{Name: "key", Type: schema.TypeString, Required: true},
+					schema.Field{Name: "value", Type: schema.TypeAny, Required: true},
+				),
+			},
+			"task": {
+				"list":  schema.NewReadOnly(),
+				"scale": schema.New(schema.Field{Name: "replicas", Type: schema.TypeInteger}),
+			},
+		})
+	}
+
+	c.calls = append(c.calls, call{module, action, body})
+
+	result, ok := c.results[module+"/"+action]
+	if !ok {
+		return nil, apierr.NotFound("unknown action: %s/%s", module, action)
+	}
+	return json.Marshal(result)
+}
+
+func newSession(t *testing.T) (*shell.Session, *fakeCaller, *bytes.Buffer) {
+	caller := &fakeCaller{results: map[string]interface{}{
+		"task/list":  map[string]interface{}{"tasks": []interface{}{map[string]interface{}{"name": "web", "replicas": 2}, map[string]interface{}{"name": "db"}}},
+		"task/scale": map[string]interface{}{"ok": true},
+		"keyval/get": map[string]interface{}{"value": "hello"},
+	}}
+
+	out := &bytes.Buffer{}
+	session := shell.NewSession(caller, out)
+	if err := session.LoadSchemas(); err != nil {
+		t.Fatal(err)
+	}
+	return session, caller, out
+}
+
+func TestSession_Execute(t *testing.T) {
+	session, caller, out := newSession(t)
+
+	if err := session.Execute("tasks = task list"); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !strings.Contains(out.String(), `"name": "web"`) {
+		t.Errorf("expected pretty output, got %q", out.String())
+	}
+
+	// Arguments are typed by the schema, and variables keep the type of their value.
+	if err := session.Execute(`task scale replicas=3 name=$tasks.tasks[0].name note="for ${tasks.tasks[1].name}" {"force": true}`); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	expected := map[string]interface{}{"replicas": int64(3), "name": "web", "note": "for db", "force": true}
+	if body := caller.calls[1].body; !reflect.DeepEqual(body, expected) {
+		t.Errorf("expected body %v, got %v", expected, body)
+	}
+
+	// The last result is always available.
+	if err := session.Execute("keyval set key=last value=$_"); err == nil {
+		t.Errorf("expected unknown action to fail")
+	}
+	if body := caller.calls[2].body.(map[string]interface{}); !reflect.DeepEqual(body["value"], map[string]interface{}{"ok": true}) {
+		t.Errorf("expected last result to be passed, got %v", body["value"])
+	}
+
+	errCases := []string{
+		"task",
+		"task scale replicas",
+		"task scale replicas=$missing",
+		"task scale replicas=$tasks.missing",
+		"_ = task list",
+		"output xml",
+		"actions unknown",
+	}
+	for _, line := range errCases {
+		if err := session.Execute(line); err == nil {
+			t.Errorf("expected %q to fail", line)
+		}
+	}
+
+	if err := session.Execute("exit"); err != shell.ErrExit {
+		t.Errorf("expected exit, got %v", err)
+	}
+}
+
+func TestSession_Table(t *testing.T) {
+	type testCase struct {
+		name string
+		line string
+
+		expected []string
+	}
+
+	cases := []testCase{
+		{"list of objects", "task list", []string{"NAME  REPLICAS", "web   2", "db"}},
+		{"object", "keyval get key=greeting", []string{"KEY    VALUE", "value  hello"}},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			session, _, out := newSession(t)
+			if err := session.SetFormat(output.FormatTable); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := session.Execute(tCase.line); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+
+			lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+			for i := range lines {
+				lines[i] = strings.TrimRight(lines[i], " ")
+			}
+			if !reflect.DeepEqual(lines, tCase.expected) {
+				t.Errorf("expected %q, got %q", tCase.expected, lines)
+			}
+		})
+	}
+}
+
+func TestSession_Complete(t *testing.T) {
+	type testCase struct {
+		name string
+		line string
+
+		expected []string
+	}
+
+	session, _, _ := newSession(t)
+	if err := session.Execute("tasks = task list"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []testCase{
+		{"modules and commands", "", []string{"actions", "exit", "help", "keyval", "modules", "output", "quit", "task", "vars"}},
+		{"module prefix", "ke", []string{"keyval"}},
+		{"assignment", "res = t", []string{"task"}},
+		{"actions", "task ", []string{"list", "scale"}},
+		{"action prefix", "keyval s", []string{"set"}},
+		{"arguments", "keyval set ", []string{"key=", "value="}},
+		{"given arguments", "keyval set key=a ", []string{"value="}},
+		{"variables", "keyval set value=$t", []string{"value=$tasks"}},
+		{"output formats", "output ", []string{"json", "pretty", "table", "yaml"}},
+		{"unknown module", "nope ", nil},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if actual := session.Complete(tCase.line); !reflect.DeepEqual(actual, tCase.expected) {
+				t.Errorf("expected %q, got %q", tCase.expected, actual)
+			}
+		})
+	}
+}
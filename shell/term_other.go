@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package shell
+
+import "errors"
+
+// Line editing is only supported on linux and darwin, lines are read as is elsewhere.
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func() error, error) {
+	return nil, errors.New("raw mode is not supported on this platform")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dalloriam/orc/output"
+	"github.com/dalloriam/orc/schema"
+	"github.com/dalloriam/orc/shellinit"
+	"github.com/dalloriam/orc/task"
+)
+
+const (
+	shellinitCommandName = "shellinit"
+	shellinitCommandHelp = "Generate the shell functions of the ORC tasks and actions."
+	shellinitCommandArgs = "bash|zsh|fish [OPTIONS]"
+
+	shellinitCommandLongHelp = shellinitCommandHelp + `
+
+Every task of the task directory gets a function of the same name, which starts it
+with ORC or, when the daemon is unreachable, runs it detached with the docker CLI.
+The arguments of the function replace the command of the task, e.g. latex make clean.
+Every action registered by the daemon gets an orc_MODULE_ACTION function taking
+NAME=VALUE arguments, e.g. orc_keyval_get key=name. The completions of these
+functions and of orc itself are generated too.
+
+Load the functions from the shell configuration with:
+  bash: eval "$(orc shellinit bash)"
+  zsh:  eval "$(orc shellinit zsh)"
+  fish: orc shellinit fish | source`
+)
+
+type shellinitCommand struct {
+	settings *settings
+
+	host    string
+	context string
+}
+
+func (cmd *shellinitCommand) Name() string      { return shellinitCommandName }
+func (cmd *shellinitCommand) Args() string      { return shellinitCommandArgs }
+func (cmd *shellinitCommand) ShortHelp() string { return shellinitCommandHelp }
+func (cmd *shellinitCommand) LongHelp() string  { return shellinitCommandLongHelp }
+func (cmd *shellinitCommand) Hidden() bool      { return false }
+func (cmd *shellinitCommand) Register(fs *flag.FlagSet) {
+	cmd.settings.Register(fs)
+	fs.StringVar(&cmd.host, "H", "", fmt.Sprintf("Server to list the actions of, e.g. unix:///path/to/orc.sock (overrides $%s)", hostEnvVar))
+	fs.StringVar(&cmd.host, "host", "", fmt.Sprintf("Server to list the actions of, e.g. unix:///path/to/orc.sock (overrides $%s)", hostEnvVar))
+	fs.StringVar(&cmd.context, "context", "", "Context of the server to list the actions of, see orc context")
+}
+
+// actions lists the actions registered by the daemon.
+func (cmd *shellinitCommand) actions() (map[string]map[string]schema.Schema, error) {
+	c, err := (&cliCommand{host: cmd.host}).newClient(cmd.context)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := c.Call("manage", "actions_available", map[string]interface{}{"detailed": true})
+	if err != nil {
+		return nil, err
+	}
+
+	var actions map[string]map[string]schema.Schema
+	if err := json.Unmarshal(out, &actions); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+func (cmd *shellinitCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Invalid syntax")
+	}
+
+	script := shellinit.Script{
+		Commands: []string{cliCommandName, configCommandName, contextCommandName, runCommandName, serverCommandName, shellCommandName, shellinitCommandName},
+		Formats:  output.Formats,
+	}
+
+	// The functions are generated from what is available, warnings go to stderr so
+	// that the script can still be evaluated.
+	cfg, err := cmd.settings.Load()
+	if err == nil {
+		script.Tasks, err = task.ReadDefinitions(cfg.TaskDirectory)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "orc: no task functions generated: %s\n", err.Error())
+	}
+	if filePath, err := cliConfigPath(); err == nil {
+		if cliCfg, err := readCLIConfig(filePath); err == nil {
+			script.Contexts = cliCfg.contextNames()
+		}
+	}
+	if script.Actions, err = cmd.actions(); err != nil {
+		fmt.Fprintf(os.Stderr, "orc: no action functions generated, the daemon is unreachable: %s\n", err.Error())
+	}
+
+	return shellinit.Generate(os.Stdout, args[0], script)
+}
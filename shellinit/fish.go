@@ -0,0 +1,105 @@
+package shellinit
+
+// fishTemplate generates the functions and completions of fish.
+const fishTemplate = `# ORC shell integration for fish, generated by "orc shellinit fish".
+# Load it from ~/.config/fish/config.fish with: orc shellinit fish | source
+
+# __orc_task NAME COUNT DOCKER_ARGS... [ARGS]... starts a task with ORC, the arguments
+# replacing its command, and runs it detached with the docker CLI when the daemon is
+# unreachable. COUNT is the number of arguments of docker run up to the image.
+function __orc_task
+	set -l name $argv[1]
+	set -l last (math $argv[2] + 2)
+	set -l run $argv[3..$last]
+	set -l user $argv[(math $last + 1)..-1]
+	set -l args
+	for arg in $user
+		# A leading @ would read the argument from a file.
+		switch $arg
+			case '@*'
+				set arg "\\$arg"
+		end
+		set -a args -a "arguments[]=$arg"
+	end
+	command orc cli -a "name=$name" $args task start
+	set -l rc $status
+	if test $rc -eq {{.UnreachableCode}}
+		echo "orc: daemon unreachable, running $name locally" >&2
+		command docker run $run $user
+		return $status
+	end
+	return $rc
+end
+
+# __orc_action MODULE ACTION [NAME=VALUE]... invokes an action of ORC.
+function __orc_action
+	set -l args
+	for arg in $argv[3..-1]
+		set -a args -a $arg
+	end
+	command orc cli $args $argv[1] $argv[2]
+end
+{{- range .Skipped}}
+
+# Task {{quote .}} is skipped, its name is not a valid function name.
+{{- end}}
+{{- range .Tasks}}
+
+function {{.Func}} --description {{quote (printf "Start the %s task with ORC" .Name)}}
+	set -l args $argv
+{{- if .Command}}
+	set -q args[1]; or set args {{join .Command}}
+{{- end}}
+	__orc_task {{quote .Name}} {{len .DockerArgs}} {{join .DockerArgs}} $args
+end
+{{- end}}
+{{- range .Actions}}
+
+function {{.Func}} --description {{quote (printf "Invoke %s %s with ORC" .Module .Action)}}
+	__orc_action {{quote .Module}} {{quote .Action}} $argv
+end
+{{- if .Arguments}}
+complete -c {{.Func}} -f -a {{quote (spaced .Arguments)}}
+{{- end}}
+{{- end}}
+
+# __orc_cli_args prints the arguments of "orc cli" and "orc run" that are neither flags nor their values.
+function __orc_cli_args
+	set -l skip 0
+	for word in (commandline -opc)[3..-1]
+		if test $skip -eq 1
+			set skip 0
+			continue
+		end
+		switch $word
+			case -a -argument -H -host -context -t -topic -d -data -o -output -q -query
+				set skip 1
+			case '-*'
+			case '*'
+				echo $word
+		end
+	end
+end
+
+# __orc_cli_position COUNT [MODULE] returns whether COUNT arguments of the command are
+# typed, the first one being MODULE.
+function __orc_cli_position
+	set -l args (__orc_cli_args)
+	test (count $args) -eq $argv[1]
+	and begin
+		test (count $argv) -lt 2
+		or test "$args[1]" = "$argv[2]"
+	end
+end
+
+complete -c orc -f -n __fish_use_subcommand -a {{quote (spaced .Commands)}}
+complete -c orc -f -n '__fish_seen_subcommand_from shellinit' -a {{quote (spaced .Shells)}}
+complete -c orc -x -n '__fish_seen_subcommand_from cli shell shellinit' -o context -a {{quote (spaced .Contexts)}}
+complete -c orc -f -n '__fish_seen_subcommand_from context; and not __fish_seen_subcommand_from list current use set delete' -a 'list current use set delete'
+complete -c orc -f -n '__fish_seen_subcommand_from context; and __fish_seen_subcommand_from use set delete' -a {{quote (spaced .Contexts)}}
+complete -c orc -x -n '__fish_seen_subcommand_from cli run' -o o -o output -a {{quote (spaced .Formats)}}
+complete -c orc -f -n '__fish_seen_subcommand_from cli run; and __orc_cli_position 0' -a {{quote (printf "events %s" (spaced .ModuleNames))}}
+{{- range .Modules}}
+complete -c orc -f -n {{quote (printf "__fish_seen_subcommand_from cli run; and __orc_cli_position 1 %s" .Name)}} -a {{quote (spaced .Actions)}}
+{{- end}}
+`
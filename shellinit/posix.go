@@ -0,0 +1,144 @@
+package shellinit
+
+// posixTemplate generates the functions of bash and zsh, whose completions use the
+// bash completion system of zsh.
+const posixTemplate = `# ORC shell integration for {{.Shell}}, generated by "orc shellinit {{.Shell}}".
+# Load it from ~/.{{.Shell}}rc with: eval "$(orc shellinit {{.Shell}})"
+
+# __orc_task NAME COUNT DOCKER_ARGS... [ARGS]... starts a task with ORC, the arguments
+# replacing its command, and runs it detached with the docker CLI when the daemon is
+# unreachable. COUNT is the number of arguments of docker run up to the image.
+__orc_task() {
+	local name="$1" count="$2" arg rc
+	local -a run args
+	shift 2
+	run=("${@:1:$count}")
+	shift "$count"
+	for arg in "$@"; do
+		# A leading @ would read the argument from a file.
+		case "$arg" in
+			@*) args+=(-a "arguments[]=\\$arg") ;;
+			*) args+=(-a "arguments[]=$arg") ;;
+		esac
+	done
+	command orc cli -a "name=$name" "${args[@]}" task start
+	rc=$?
+	if [ "$rc" -eq {{.UnreachableCode}} ]; then
+		echo "orc: daemon unreachable, running $name locally" >&2
+		command docker run "${run[@]}" "$@"
+		return
+	fi
+	return "$rc"
+}
+
+# __orc_action MODULE ACTION [NAME=VALUE]... invokes an action of ORC.
+__orc_action() {
+	local module="$1" action="$2" arg
+	local -a args
+	shift 2
+	for arg in "$@"; do
+		args+=(-a "$arg")
+	done
+	command orc cli "${args[@]}" "$module" "$action"
+}
+{{- range .Skipped}}
+
+# Task {{quote .}} is skipped, its name is not a valid function name.
+{{- end}}
+{{- range .Tasks}}
+
+{{.Func}}() {
+{{- if .Command}}
+	[ "$#" -gt 0 ] || set -- {{join .Command}}
+{{- end}}
+	__orc_task {{quote .Name}} {{len .DockerArgs}} {{join .DockerArgs}} "$@"
+}
+{{- end}}
+{{- range .Actions}}
+
+{{.Func}}() {
+	__orc_action {{quote .Module}} {{quote .Action}} "$@"
+}
+{{- end}}
+
+# Completions of the functions and of orc.
+{{- if eq .Shell "zsh"}}
+if (( ! $+functions[compdef] )); then
+	autoload -U +X compinit && compinit
+fi
+autoload -U +X bashcompinit && bashcompinit
+{{- end}}
+{{- range .Actions}}{{if .Arguments}}
+complete -o nospace -W {{quote (spaced .Arguments)}} {{.Func}}
+{{- end}}{{end}}
+
+# __orc_actions MODULE lists the actions of the module, and --modules the modules.
+__orc_actions() {
+	case "$1" in
+	--modules) echo {{quote (spaced .ModuleNames)}} ;;{{- range .Modules}}
+	{{quote .Name}}) echo {{quote (spaced .Actions)}} ;;
+{{- end}}
+	esac
+}
+
+_orc() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" word skip=0 i
+	local -a positional
+
+	# The words that are neither flags nor their values: the command, then its arguments.
+	for (( i = 1; i < COMP_CWORD; i++ )); do
+		word="${COMP_WORDS[i]}"
+		if [ "$skip" -eq 1 ]; then
+			skip=0
+			continue
+		fi
+		case "$word" in
+			-a|-argument|-H|-host|-context|-t|-topic|-d|-data|-o|-output|-q|-query) skip=1 ;;
+			-*) ;;
+			*) positional+=("$word") ;;
+		esac
+	done
+
+	if [ "$prev" = "-context" ]; then
+		COMPREPLY=($(compgen -W {{quote (spaced .Contexts)}} -- "$cur"))
+		return
+	fi
+
+	if [ "${#positional[@]}" -eq 0 ]; then
+		COMPREPLY=($(compgen -W {{quote (spaced .Commands)}} -- "$cur"))
+		return
+	fi
+
+	case "${positional[0]}" in
+	cli|run)
+		case "$prev" in
+			-o|-output) COMPREPLY=($(compgen -W {{quote (spaced .Formats)}} -- "$cur")) ;;
+			-a|-argument|-H|-host|-t|-topic|-d|-data|-q|-query) ;;
+			*)
+				case "${#positional[@]}" in
+					1) COMPREPLY=($(compgen -W "events $(__orc_actions --modules)" -- "$cur")) ;;
+					2) COMPREPLY=($(compgen -W "$(__orc_actions "${positional[1]}")" -- "$cur")) ;;
+				esac
+				;;
+		esac
+		;;
+	context)
+		case "${#positional[@]}" in
+			1) COMPREPLY=($(compgen -W 'list current use set delete' -- "$cur")) ;;
+			2)
+				case "${positional[1]}" in
+					use|set|delete) COMPREPLY=($(compgen -W {{quote (spaced .Contexts)}} -- "$cur")) ;;
+				esac
+				;;
+		esac
+		;;
+	shellinit)
+		if [ "${#positional[@]}" -eq 1 ]; then
+			COMPREPLY=($(compgen -W {{quote (spaced .Shells)}} -- "$cur"))
+		fi
+		;;
+	esac
+}
+
+complete -F _orc orc
+`
@@ -0,0 +1,196 @@
+// Package shellinit generates the shell functions calling the tasks and actions of
+// ORC, which fall back to running the tasks locally when the daemon is unreachable.
+package shellinit
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/dalloriam/orc/schema"
+	"github.com/dalloriam/orc/task"
+)
+
+// Supported shells.
+const (
+	Bash = "bash"
+	Zsh  = "zsh"
+	Fish = "fish"
+)
+
+// Shells lists the supported shells.
+var Shells = []string{Bash, Fish, Zsh}
+
+// unreachableExitCode is the exit code of "orc cli" when the daemon can't be reached,
+// the daemon returning other codes when it can't start the task.
+const unreachableExitCode = 11
+
+var (
+	// Tasks keep their name as function name, as long as it is a valid one.
+	taskFuncName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	invalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	bareWord     = regexp.MustCompile(`^[A-Za-z0-9_./:=,@%+-]+$`)
+)
+
+// Script describes the functions to generate.
+type Script struct {
+	// Tasks are the task definitions, run with the docker CLI when the daemon is unreachable.
+	Tasks []*task.Task
+
+	// Actions are the actions registered by the daemon, by module.
+	Actions map[string]map[string]schema.Schema
+
+	// Commands, Formats and Contexts are completed for the orc command itself.
+	Commands []string
+	Formats  []string
+	Contexts []string
+}
+
+type taskFunc struct {
+	Func string
+	Name string
+
+	// DockerArgs are the arguments of "docker run" up to the image, the command being
+	// replaced by the arguments of the function when it is given some.
+	DockerArgs []string
+	Command    []string
+}
+
+type actionFunc struct {
+	Func      string
+	Module    string
+	Action    string
+	Arguments []string
+}
+
+type module struct {
+	Name    string
+	Actions []string
+}
+
+type templateData struct {
+	Shell           string
+	UnreachableCode int
+
+	Tasks       []taskFunc
+	Actions     []actionFunc
+	Modules     []module
+	ModuleNames []string
+	Skipped     []string
+
+	Commands []string
+	Formats  []string
+	Contexts []string
+	Shells   []string
+}
+
+// Generate writes the script of the shell.
+func Generate(w io.Writer, shell string, script Script) error {
+	var (
+		tmpl  string
+		quote func(string) string
+	)
+	switch shell {
+	case Bash, Zsh:
+		tmpl, quote = posixTemplate, posixQuote
+	case Fish:
+		tmpl, quote = fishTemplate, fishQuote
+	default:
+		return fmt.Errorf("unsupported shell: %s, expected one of %s", shell, strings.Join(Shells, ", "))
+	}
+
+	t, err := template.New(shell).Funcs(template.FuncMap{
+		"quote": quote,
+		"spaced": func(words []string) string {
+			return strings.Join(words, " ")
+		},
+		"join": func(words []string) string {
+			quoted := make([]string, len(words))
+			for i, word := range words {
+				quoted[i] = quote(word)
+			}
+			return strings.Join(quoted, " ")
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return err
+	}
+
+	return t.Execute(w, newTemplateData(shell, script))
+}
+
+func newTemplateData(shell string, script Script) templateData {
+	data := templateData{
+		Shell:           shell,
+		UnreachableCode: unreachableExitCode,
+		Commands:        script.Commands,
+		Formats:         script.Formats,
+		Contexts:        script.Contexts,
+		Shells:          Shells,
+	}
+
+	tasks := append([]*task.Task{}, script.Tasks...)
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	for _, t := range tasks {
+		if !taskFuncName.MatchString(t.Name) {
+			data.Skipped = append(data.Skipped, t.Name)
+			continue
+		}
+		args := t.DockerRunArgs()
+		data.Tasks = append(data.Tasks, taskFunc{
+			Func:       t.Name,
+			Name:       t.Name,
+			DockerArgs: args[:len(args)-len(t.Command)],
+			Command:    t.Command,
+		})
+	}
+
+	modules := make([]string, 0, len(script.Actions))
+	for name := range script.Actions {
+		modules = append(modules, name)
+	}
+	sort.Strings(modules)
+
+	for _, name := range modules {
+		mod := module{Name: name}
+		for action := range script.Actions[name] {
+			mod.Actions = append(mod.Actions, action)
+		}
+		sort.Strings(mod.Actions)
+
+		for _, action := range mod.Actions {
+			fn := actionFunc{
+				Func:   "orc_" + invalidChars.ReplaceAllString(name, "_") + "_" + invalidChars.ReplaceAllString(action, "_"),
+				Module: name,
+				Action: action,
+			}
+			for _, field := range script.Actions[name][action].Arguments {
+				fn.Arguments = append(fn.Arguments, field.Name+"=")
+			}
+			data.Actions = append(data.Actions, fn)
+		}
+		data.Modules = append(data.Modules, mod)
+	}
+	data.ModuleNames = modules
+
+	return data
+}
+
+// posixQuote quotes a word for bash and zsh.
+func posixQuote(word string) string {
+	if bareWord.MatchString(word) {
+		return word
+	}
+	return "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote quotes a word for fish, where backslashes are escaped within quotes.
+func fishQuote(word string) string {
+	if bareWord.MatchString(word) {
+		return word
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(word) + "'"
+}
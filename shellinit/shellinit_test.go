@@ -0,0 +1,190 @@
+package shellinit_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dalloriam/orc/schema"
+	"github.com/dalloriam/orc/shellinit"
+	"github.com/dalloriam/orc/task"
+)
+
+// Stubs of the orc and docker commands, logging their arguments.
+const (
+	orcStub    = "#!/bin/sh\necho \"orc $*\" >> \"$STUB_LOG\"\nexit \"${ORC_EXIT:-0}\"\n"
+	dockerStub = "#!/bin/sh\necho \"docker $*\" >> \"$STUB_LOG\"\n"
+)
+
+func testScript() shellinit.Script {
+	return shellinit.Script{
+		Tasks: []*task.Task{
+			{Name: "latex", Image: "latex:latest", Command: []string{"make", "pdf"}, Environment: map[string]string{"TITLE": "it's done"}},
+			{Name: "bad name", Image: "busybox"},
+		},
+		Actions: map[string]map[string]schema.Schema{
+			"keyval": {
+				"get": schema.NewReadOnly(schema.Field{Name: "key", Type: schema.TypeString, Required: true}),
+				"set": schema.New(schema.Field{Name: "key", Type: schema.TypeString}, schema.Field{Name: "value", Type: schema.TypeAny}),
+			},
+			"task": {
+				"list": schema.NewReadOnly(),
+			},
+		},
+		Commands: []string{"cli", "shellinit"},
+		Formats:  []string{"json", "yaml"},
+		Contexts: []string{"home-server", "laptop"},
+	}
+}
+
+func generate(t *testing.T, shell string) string {
+	var buf bytes.Buffer
+	if err := shellinit.Generate(&buf, shell, testScript()); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	return buf.String()
+}
+
+func TestGenerate(t *testing.T) {
+	type testCase struct {
+		shell string
+
+		expected []string
+	}
+
+	cases := []testCase{
+		{shellinit.Bash, []string{
+			"latex() {\n\t[ \"$#\" -gt 0 ] || set -- make pdf\n\t__orc_task latex 8 --name latex -t -d --rm -e 'TITLE=it'\\''s done' latex:latest \"$@\"\n}",
+			"orc_keyval_set() {\n\t__orc_action keyval set \"$@\"\n}",
+			"complete -o nospace -W 'key= value=' orc_keyval_set",
+			"# Task 'bad name' is skipped",
+			"\tkeyval) echo 'get set' ;;",
+		}},
+		{shellinit.Zsh, []string{
+			"latex() {",
+			"autoload -U +X bashcompinit && bashcompinit",
+			"complete -F _orc orc",
+		}},
+		{shellinit.Fish, []string{
+			"function latex --description 'Start the latex task with ORC'\n\tset -l args $argv\n\tset -q args[1]; or set args make pdf\n\t__orc_task latex 8 --name latex -t -d --rm -e 'TITLE=it\\'s done' latex:latest $args\nend",
+			"function orc_task_list --description 'Invoke task list with ORC'",
+			"complete -c orc_keyval_get -f -a key=",
+			"complete -c orc -f -n '__fish_seen_subcommand_from cli run; and __orc_cli_position 1 keyval' -a 'get set'",
+			"complete -c orc -x -n '__fish_seen_subcommand_from cli shell shellinit' -o context -a 'home-server laptop'",
+		}},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.shell, func(t *testing.T) {
+			script := generate(t, tCase.shell)
+			for _, expected := range tCase.expected {
+				if !strings.Contains(script, expected) {
+					t.Errorf("expected script to contain %q, got:\n%s", expected, script)
+				}
+			}
+
+			// Check the syntax with the shell, when it is installed.
+			if bin, err := exec.LookPath(tCase.shell); err == nil {
+				cmd := exec.Command(bin, "-n")
+				cmd.Stdin = strings.NewReader(script)
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Errorf("invalid %s script: %s: %s", tCase.shell, err.Error(), out)
+				}
+			}
+		})
+	}
+
+	if err := shellinit.Generate(ioutil.Discard, "powershell", testScript()); err == nil {
+		t.Errorf("expected unsupported shell to be rejected")
+	}
+}
+
+func TestGenerate_BashFunctions(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "orc-shellinit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, stub := range map[string]string{"orc": orcStub, "docker": dockerStub} {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(stub), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scriptFile := path.Join(dir, "orc.bash")
+	if err := ioutil.WriteFile(scriptFile, []byte(generate(t, shellinit.Bash)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(orcExit, commands string) string {
+		logFile := path.Join(dir, "log")
+		os.Remove(logFile)
+
+		cmd := exec.Command(bash, "-c", "source "+scriptFile+"\n"+commands)
+		cmd.Env = append(os.Environ(), "PATH="+dir+":"+os.Getenv("PATH"), "STUB_LOG="+logFile, "ORC_EXIT="+orcExit)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("error running %q: %s: %s", commands, err.Error(), out)
+		}
+
+		logged, _ := ioutil.ReadFile(logFile)
+		return string(logged) + string(out)
+	}
+
+	if out := run("0", `orc_keyval_set key=a "value=b c"`); out != "orc cli -a key=a -a value=b c keyval set\n" {
+		t.Errorf("unexpected action call: %q", out)
+	}
+
+	// The arguments of the task replace its command.
+	if out := run("0", "latex"); out != "orc cli -a name=latex -a arguments[]=make -a arguments[]=pdf task start\n" {
+		t.Errorf("expected task to be started by the daemon, got %q", out)
+	}
+	if out := run("0", `latex clean "@a b"`); out != "orc cli -a name=latex -a arguments[]=clean -a arguments[]=\\@a b task start\n" {
+		t.Errorf("expected the arguments to be passed to the daemon, got %q", out)
+	}
+
+	// The task runs locally when the daemon is unreachable, but not on other errors
+	// such as the daemon failing to reach docker.
+	expected := "orc cli -a name=latex -a arguments[]=clean task start\ndocker run --name latex -t -d --rm -e TITLE=it's done latex:latest clean\norc: daemon unreachable, running latex locally\n"
+	if out := run("11", "latex clean"); out != expected {
+		t.Errorf("expected task to fall back to docker, got %q", out)
+	}
+	expected = "orc cli -a name=latex -a arguments[]=make -a arguments[]=pdf task start\ndocker run --name latex -t -d --rm -e TITLE=it's done latex:latest make pdf\norc: daemon unreachable, running latex locally\n"
+	if out := run("11", "latex"); out != expected {
+		t.Errorf("expected task to fall back to docker with its command, got %q", out)
+	}
+	if out := run("6", "latex || echo failed $?"); out != "orc cli -a name=latex -a arguments[]=make -a arguments[]=pdf task start\nfailed 6\n" {
+		t.Errorf("expected other errors to be returned, got %q", out)
+	}
+
+	complete := func(words ...string) string {
+		return strings.TrimSpace(run("0", "COMP_WORDS=("+strings.Join(words, " ")+"); COMP_CWORD="+
+			string(rune('0'+len(words)-1))+`; _orc; echo "${COMPREPLY[@]}"`))
+	}
+
+	completions := map[string][]string{
+		"cli shellinit": {"orc", "''"},
+		"keyval":        {"orc", "cli", "-a", "x=y", "ke"},
+		"get set":       {"orc", "cli", "keyval", "''"},
+		"json":          {"orc", "cli", "-o", "j"},
+		"bash fish zsh": {"orc", "shellinit", "''"},
+
+		"home-server laptop": {"orc", "cli", "-context", "''"},
+		"laptop":             {"orc", "context", "use", "l"},
+		"use":                {"orc", "context", "u"},
+	}
+	for expected, words := range completions {
+		if actual := complete(words...); actual != expected {
+			t.Errorf("expected completions of %v to be %q, got %q", words, expected, actual)
+		}
+	}
+}
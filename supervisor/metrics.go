@@ -0,0 +1,12 @@
+package supervisor
+
+import "github.com/dalloriam/orc/metrics"
+
+var (
+	modulePanics    = metrics.NewCounterVec("orc_module_panics_total", "Number of panics recovered from the modules.", "module")
+	modulesDisabled = metrics.NewGaugeVec("orc_module_disabled", "Whether the module is disabled after repeated panics.", "module")
+)
+
+func init() {
+	metrics.MustRegister(modulePanics, modulesDisabled)
+}
@@ -0,0 +1,175 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/request"
+	"github.com/sirupsen/logrus"
+)
+
+// Policy decides when a panicking module is disabled.
+type Policy struct {
+	// MaxPanics is the number of panics within the window after which a module
+	// is disabled. Zero never disables modules.
+	MaxPanics int
+
+	Window time.Duration
+}
+
+type moduleState struct {
+	panics   []time.Time
+	disabled bool
+}
+
+// Supervisor recovers the panics of the modules, in their actions as well as in
+// their background goroutines, and disables the modules that keep panicking until
+// they are reset. A nil supervisor still recovers and logs panics, but does not
+// keep track of them.
+type Supervisor struct {
+	mu      sync.Mutex
+	policy  Policy
+	modules map[string]*moduleState
+}
+
+// New returns a supervisor applying the policy.
+func New(policy Policy) *Supervisor {
+	return &Supervisor{
+		policy:  policy,
+		modules: make(map[string]*moduleState),
+	}
+}
+
+// SetPolicy changes the policy. Modules already disabled stay disabled.
+func (s *Supervisor) SetPolicy(policy Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// Call runs an action of the module, converting its panic into an internal error.
+// Actions of disabled modules are rejected without running.
+func (s *Supervisor) Call(ctx context.Context, moduleName string, fn func() ([]byte, error)) (out []byte, err error) {
+	if err := s.Check(moduleName); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			s.report(ctx, moduleName, "action", v, debug.Stack())
+			out, err = nil, apierr.Internal("module %s panicked", moduleName)
+		}
+	}()
+
+	return fn()
+}
+
+// Recover recovers the panic of a background goroutine of the module.
+// It must be deferred directly, e.g. defer s.Recover(ctx, "task", "lifecycle").
+func (s *Supervisor) Recover(ctx context.Context, moduleName, source string) {
+	if v := recover(); v != nil {
+		s.report(ctx, moduleName, source, v, debug.Stack())
+	}
+}
+
+// Disabled returns whether the module is disabled.
+func (s *Supervisor) Disabled(moduleName string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.modules[moduleName]
+	return ok && state.disabled
+}
+
+// Check returns an unavailable error if the module is disabled.
+func (s *Supervisor) Check(moduleName string) error {
+	if s.Disabled(moduleName) {
+		return apierr.Unavailable("module %s is disabled after repeated panics, reload to enable it", moduleName).WithDetail("module", moduleName)
+	}
+	return nil
+}
+
+// DisabledModules returns the sorted names of the disabled modules.
+func (s *Supervisor) DisabledModules() []string {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for name, state := range s.modules {
+		if state.disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reset forgets the panics of all modules and enables them again.
+func (s *Supervisor) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, state := range s.modules {
+		if state.disabled {
+			logrus.WithField("module", name).Info("module enabled")
+			modulesDisabled.WithLabelValues(name).Set(0)
+		}
+	}
+	s.modules = make(map[string]*moduleState)
+}
+
+func (s *Supervisor) report(ctx context.Context, moduleName, source string, v interface{}, stack []byte) {
+	modulePanics.WithLabelValues(moduleName).Inc()
+
+	request.Log(ctx).WithFields(logrus.Fields{
+		"module": moduleName,
+		"source": source,
+		"panic":  fmt.Sprint(v),
+		"stack":  string(stack),
+	}).Error("recovered from panic")
+
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.modules[moduleName]
+	if !ok {
+		state = &moduleState{}
+		s.modules[moduleName] = state
+	}
+
+	now := time.Now()
+	recent := state.panics[:0]
+	for _, at := range state.panics {
+		if now.Sub(at) < s.policy.Window {
+			recent = append(recent, at)
+		}
+	}
+	state.panics = append(recent, now)
+
+	if !state.disabled && s.policy.MaxPanics > 0 && len(state.panics) >= s.policy.MaxPanics {
+		state.disabled = true
+		modulesDisabled.WithLabelValues(moduleName).Set(1)
+		logrus.WithFields(logrus.Fields{
+			"module": moduleName,
+			"panics": len(state.panics),
+			"window": s.policy.Window.String(),
+		}).Error("module disabled after repeated panics")
+	}
+}
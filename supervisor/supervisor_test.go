@@ -0,0 +1,135 @@
+package supervisor_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/supervisor"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logrus.SetLevel(logrus.PanicLevel)
+}
+
+func panicky() ([]byte, error) {
+	var m map[string]string
+	m["nil"] = "map"
+	return nil, nil
+}
+
+func TestSupervisor_Call(t *testing.T) {
+	type testCase struct {
+		name string
+		sup  *supervisor.Supervisor
+		fn   func() ([]byte, error)
+
+		wantOut  string
+		wantCode apierr.Code
+	}
+
+	cases := []testCase{
+		{"success", supervisor.New(supervisor.Policy{}), func() ([]byte, error) { return []byte("out"), nil }, "out", ""},
+		{"error", supervisor.New(supervisor.Policy{}), func() ([]byte, error) { return nil, apierr.NotFound("nope") }, "", apierr.CodeNotFound},
+		{"panic", supervisor.New(supervisor.Policy{}), panicky, "", apierr.CodeInternal},
+		{"panic without supervisor", nil, panicky, "", apierr.CodeInternal},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			out, err := tCase.sup.Call(context.Background(), "mod", tCase.fn)
+			if string(out) != tCase.wantOut {
+				t.Errorf("expected output %q, got %q", tCase.wantOut, string(out))
+			}
+
+			if tCase.wantCode == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil || apierr.From(err).Code != tCase.wantCode {
+				t.Errorf("expected %s error, got %v", tCase.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestSupervisor_Disable(t *testing.T) {
+	sup := supervisor.New(supervisor.Policy{MaxPanics: 3, Window: time.Minute})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		sup.Call(ctx, "mod", panicky)
+	}
+	if sup.Disabled("mod") {
+		t.Fatalf("expected module to be enabled below the threshold")
+	}
+
+	// Panics of background goroutines count as well.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer sup.Recover(ctx, "mod", "background")
+		panicky()
+	}()
+	wg.Wait()
+
+	if !sup.Disabled("mod") {
+		t.Fatalf("expected module to be disabled")
+	}
+
+	called := false
+	_, err := sup.Call(ctx, "mod", func() ([]byte, error) {
+		called = true
+		return nil, nil
+	})
+	if called || err == nil || apierr.From(err).Code != apierr.CodeUnavailable {
+		t.Errorf("expected disabled module to be unavailable, got %v", err)
+	}
+
+	if disabled := sup.DisabledModules(); len(disabled) != 1 || disabled[0] != "mod" {
+		t.Errorf("unexpected disabled modules: %v", disabled)
+	}
+
+	if sup.Disabled("other") {
+		t.Errorf("expected other modules not to be affected")
+	}
+
+	sup.Reset()
+
+	if sup.Disabled("mod") {
+		t.Errorf("expected module to be enabled after reset")
+	}
+}
+
+func TestSupervisor_Window(t *testing.T) {
+	sup := supervisor.New(supervisor.Policy{MaxPanics: 2, Window: 10 * time.Millisecond})
+
+	sup.Call(context.Background(), "mod", panicky)
+	time.Sleep(20 * time.Millisecond)
+	sup.Call(context.Background(), "mod", panicky)
+
+	if sup.Disabled("mod") {
+		t.Errorf("expected panics outside of the window not to count")
+	}
+}
+
+func TestSupervisor_NeverDisable(t *testing.T) {
+	sup := supervisor.New(supervisor.Policy{Window: time.Minute})
+
+	for i := 0; i < 10; i++ {
+		if _, err := sup.Call(context.Background(), "mod", panicky); err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+
+	if sup.Disabled("mod") {
+		t.Errorf("expected modules not to be disabled without a panic limit")
+	}
+}
@@ -1,13 +1,18 @@
 package task
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"path"
-	"strings"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/dalloriam/orc/apierr"
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/schema"
+	"github.com/dalloriam/orc/supervisor"
+	"github.com/docker/docker/client"
 	"github.com/mitchellh/mapstructure"
 	"github.com/sirupsen/logrus"
 )
@@ -15,26 +20,48 @@ import (
 const (
 	moduleName = "task"
 
+	// ModuleName is the name under which the task module is registered.
+	ModuleName = moduleName
+
 	// MaintenanceLoopFrequencyMs is the frequency at which we check for changes in task status.
 	MaintenanceLoopFrequencyMs = 500
 )
 
 // Controller defines available docker interactions
 type Controller struct {
+	mu            sync.RWMutex
 	defsDirectory string
 	tasks         map[string]taskDef
 
 	RunningTasks map[string]chan bool
 
-	shouldInitializeTasks bool
+	// StopTasksOnClose defines whether running tasks are stopped when the controller is closed.
+	// When unset, tasks are left running and are picked up again on the next startup.
+	StopTasksOnClose bool
+
+	// Supervisor recovers the panics of the task lifecycles.
+	Supervisor *supervisor.Supervisor
+
+	// Events receives the task/started, task/stopped, task/exited and task/failed events.
+	Events *events.Bus
+
+	shouldInitializeTasks  bool
+	shouldHookRunningTasks bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	lifecycle sync.WaitGroup
 }
 
 // NewController loads the task definitions and returns a new controller.
-func NewController(definitionsDirectory string, initializeTasks bool) (*Controller, error) {
+// When hookRunningTasks is set, the tasks already running are managed by the controller
+// as if it had started them, otherwise they are left alone.
+func NewController(definitionsDirectory string, initializeTasks, hookRunningTasks bool) (*Controller, error) {
 	cont := &Controller{
-		defsDirectory:         definitionsDirectory,
-		RunningTasks:          make(map[string]chan bool),
-		shouldInitializeTasks: initializeTasks,
+		defsDirectory:          definitionsDirectory,
+		RunningTasks:           make(map[string]chan bool),
+		shouldInitializeTasks:  initializeTasks,
+		shouldHookRunningTasks: hookRunningTasks,
 	}
 	if err := cont.loadTasks(); err != nil {
 		return nil, err
@@ -51,48 +78,83 @@ func (c *Controller) Name() string {
 
 // Actions returns the actions defined by the module
 func (c *Controller) Actions() []string {
-	return []string{"start", "stop", "running"}
+	return []string{"start", "stop", "running", "list"}
+}
+
+// TaskStatus describes a defined task.
+type TaskStatus struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
 }
 
 // AddTask adds the task to the controller.
 func (c *Controller) AddTask(name string, t taskDef) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.tasks == nil {
 		c.tasks = make(map[string]taskDef)
 	}
 	c.tasks[name] = t
 }
 
-func (c *Controller) loadTasks() error {
-	ctxLog := logrus.WithFields(logrus.Fields{
-		"module": moduleName,
-	})
+func (c *Controller) getTask(name string) (taskDef, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	task, ok := c.tasks[name]
+	return task, ok
+}
 
-	files, err := ioutil.ReadDir(c.defsDirectory)
+// exitCoder is implemented by the tasks reporting the exit code of their last run.
+type exitCoder interface {
+	ExitCode() (int, bool)
+}
+
+// portPublisher is implemented by the tasks publishing ports on the host.
+type portPublisher interface {
+	PublishedPorts() map[string]int
+}
+
+func (c *Controller) readDefinitions() ([]*Task, error) {
+	return ReadDefinitions(c.defsDirectory)
+}
+
+func (c *Controller) loadTasks() error {
+	tasks, err := c.readDefinitions()
 	if err != nil {
-		return fmt.Errorf("invalid task directory: %s", c.defsDirectory)
+		return err
 	}
 
-	for _, f := range files {
-		if !strings.HasSuffix(f.Name(), ".json") {
-			continue
-		}
+	return c.registerTasks(tasks)
+}
 
-		taskPath := path.Join(c.defsDirectory, f.Name())
+// Reload replaces the task definitions by the ones currently in the definitions directory.
+// Tasks that are already running keep being managed.
+func (c *Controller) Reload() error {
+	tasks, err := c.readDefinitions()
+	if err != nil {
+		return err
+	}
 
-		data, err := ioutil.ReadFile(taskPath)
-		if err != nil {
-			return err
-		}
+	c.mu.Lock()
+	c.tasks = make(map[string]taskDef)
+	c.mu.Unlock()
 
-		var task Task
-		if err := json.Unmarshal(data, &task); err != nil {
-			return err
-		}
+	return c.registerTasks(tasks)
+}
+
+func (c *Controller) registerTasks(tasks []*Task) error {
+	ctx := context.Background()
+	ctxLog := logrus.WithFields(logrus.Fields{
+		"module": moduleName,
+	})
 
-		c.AddTask(task.Name, &task)
+	for _, task := range tasks {
+		c.AddTask(task.Name, task)
 
 		if c.shouldInitializeTasks {
-			if err := task.Initialize(); err != nil {
+			if err := task.Initialize(ctx); err != nil {
 				return err
 			}
 		} else {
@@ -100,51 +162,144 @@ func (c *Controller) loadTasks() error {
 		}
 		ctxLog.Infof("task loaded successfully: %s", task.Name)
 
-		isRunning, err := task.IsRunning()
+		if !c.shouldHookRunningTasks {
+			continue
+		}
+
+		isRunning, err := task.IsRunning(ctx)
 		if err != nil {
 			return err
 		}
 
 		if isRunning {
 			logrus.Infof("hooking into already running task: %s", task.Name)
-			go c.manageLifecycle(task.Name, &task)
+			c.manageLifecycle(ctx, task.Name, task)
 		}
 	}
 
 	return nil
 }
 
+// ActionSchema returns the arguments of a task action.
+func (c *Controller) ActionSchema(actionName string) schema.Schema {
+	switch actionName {
+	case "start":
+		return schema.New(
+			schema.Field{Name: "name", Type: schema.TypeString, Required: true, Description: "Name of the task"},
+			schema.Field{Name: "arguments", Type: schema.TypeArray, Description: "Arguments replacing the command of the task"},
+		)
+	case "stop":
+		return schema.New(schema.Field{Name: "name", Type: schema.TypeString, Required: true, Description: "Name of the task"})
+	case "running", "list":
+		return schema.NewReadOnly()
+	}
+	return schema.New()
+}
+
 // Execute executes an action.
-func (c *Controller) Execute(actionName string, data map[string]interface{}) ([]byte, error) {
+func (c *Controller) Execute(ctx context.Context, actionName string, data map[string]interface{}) ([]byte, error) {
 	switch actionName {
 	case "start":
 		var args StartPayload
 		if err := mapstructure.Decode(data, &args); err != nil {
-			return nil, err
+			return nil, apierr.InvalidArgument("invalid arguments: %s", err.Error())
 		}
-		if err := c.Start(args.TaskName); err != nil {
-			return nil, err
+		if err := c.Start(ctx, args.TaskName, args.Arguments...); err != nil {
+			return nil, classifyError(err)
 		}
 	case "stop":
 		var args StartPayload
 		if err := mapstructure.Decode(data, &args); err != nil {
-			return nil, err
+			return nil, apierr.InvalidArgument("invalid arguments: %s", err.Error())
 		}
-		if err := c.Stop(args.TaskName); err != nil {
-			return nil, err
+		if err := c.Stop(ctx, args.TaskName); err != nil {
+			return nil, classifyError(err)
 		}
 	case "running":
 		return json.Marshal(map[string]interface{}{
 			"message": "OK",
 			"tasks":   c.getRunningTasks(),
 		})
+	case "list":
+		return json.Marshal(map[string]interface{}{
+			"message": "OK",
+			"tasks":   c.listTasks(),
+		})
 	default:
-		return nil, fmt.Errorf("unknown action: %s", actionName)
+		return nil, apierr.NotFound("unknown action: %s", actionName)
 	}
 	return json.Marshal(map[string]interface{}{"message": "OK"})
 }
 
+// classifyError converts docker client errors to API errors.
+func classifyError(err error) error {
+	if _, ok := err.(*apierr.Error); ok {
+		return err
+	}
+
+	if client.IsErrConnectionFailed(err) {
+		return apierr.Unavailable("%s", err.Error())
+	}
+
+	if client.IsErrNotFound(err) {
+		return apierr.NotFound("%s", err.Error())
+	}
+
+	return err
+}
+
+// Close releases the lifecycle goroutines of the running tasks.
+// Running tasks are only stopped if StopTasksOnClose is set.
+func (c *Controller) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.doneChan())
+	})
+	c.lifecycle.Wait()
+	return nil
+}
+
+// doneChan returns the channel closed when the controller is closed.
+func (c *Controller) doneChan() chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	return c.done
+}
+
+func (c *Controller) closing() bool {
+	select {
+	case <-c.doneChan():
+		return true
+	default:
+		return false
+	}
+}
+
+// listTasks returns the defined tasks, sorted by name.
+func (c *Controller) listTasks() []TaskStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tasks := []TaskStatus{}
+	for name := range c.tasks {
+		_, running := c.RunningTasks[name]
+		tasks = append(tasks, TaskStatus{Name: name, Running: running})
+	}
+
+	sort.Slice(tasks, func(i, k int) bool {
+		return tasks[i].Name < tasks[k].Name
+	})
+
+	return tasks
+}
+
 func (c *Controller) getRunningTasks() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	tasks := []string{}
 	for k := range c.RunningTasks {
 		tasks = append(tasks, k)
@@ -152,30 +307,109 @@ func (c *Controller) getRunningTasks() []string {
 	return tasks
 }
 
-// Manages the lifecycle (status & cleanup) of a running task
-func (c *Controller) manageLifecycle(name string, task taskDef) {
+// RunningTaskPorts returns the host ports published by the running tasks, by task name.
+func (c *Controller) RunningTaskPorts() map[string]map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ports := make(map[string]map[string]int, len(c.RunningTasks))
+	for name := range c.RunningTasks {
+		ports[name] = nil
+		if publisher, ok := c.tasks[name].(portPublisher); ok {
+			ports[name] = publisher.PublishedPorts()
+		}
+	}
+	return ports
+}
+
+// Manages the lifecycle (status & cleanup) of a running task.
+// The request ID carried by ctx is kept in the lifecycle logs and passed on to the
+// subsequent tasks, so that a whole chain can be traced back to the request that started it.
+func (c *Controller) manageLifecycle(reqCtx context.Context, name string, task taskDef) {
+	c.mu.Lock()
 	if _, ok := c.RunningTasks[name]; ok {
 		// If this is true, task is already managed by another goroutine.
+		c.mu.Unlock()
 		return
 	}
 
 	outChan := make(chan bool)
 	c.RunningTasks[name] = outChan
+	c.mu.Unlock()
+
+	tasksRunning.WithLabelValues().Inc()
+	// Tasks already running on startup are measured from the moment they are picked up.
+	started := time.Now()
+
+	done := c.doneChan()
 
+	c.lifecycle.Add(1)
 	go func() {
-		ctxLog := logrus.WithFields(logrus.Fields{
+		defer c.lifecycle.Done()
+
+		// The lifecycle outlives the request that started the task.
+		ctx := request.Detach(reqCtx)
+		defer c.Supervisor.Recover(ctx, moduleName, "lifecycle of task "+name)
+
+		ctxLog := request.Log(ctx).WithFields(logrus.Fields{
 			"module": moduleName,
 			"task":   name,
 		})
 
+		// Set when the controller is closed while leaving the task running.
+		released := false
+
+		// The exit is published before the subsequent tasks start, so that
+		// subscribers see the events in order.
+		exitPublished := false
+		publishExit := func() {
+			if exitPublished {
+				return
+			}
+			exitPublished = true
+
+			duration := time.Since(started)
+			taskDuration.Observe(duration.Seconds(), name)
+
+			data := map[string]interface{}{
+				"task":        name,
+				"duration_ms": float64(duration) / float64(time.Millisecond),
+			}
+			exitCode, exited := 0, false
+			if coder, ok := task.(exitCoder); ok {
+				exitCode, exited = coder.ExitCode()
+			}
+			if exited {
+				data["exit_code"] = exitCode
+			}
+			c.Events.Publish(ctx, events.TopicTaskExited, data)
+
+			if exited && exitCode != 0 {
+				c.Events.Publish(ctx, events.TopicTaskFailed, map[string]interface{}{
+					"task":      name,
+					"reason":    failureExit,
+					"exit_code": exitCode,
+				})
+			}
+		}
+
 		// No matter how we exit, cleanup must be performed.
 		defer func() {
-			close(outChan)
+			c.mu.Lock()
 			delete(c.RunningTasks, name)
+			c.mu.Unlock()
 
-			if err := task.Cleanup(); err != nil {
-				ctxLog.Errorf("error cleaning up [%s]: %s", name, err.Error())
+			tasksRunning.WithLabelValues().Dec()
+
+			if !released {
+				publishExit()
+
+				if err := task.Cleanup(ctx); err != nil {
+					ctxLog.Errorf("error cleaning up [%s]: %s", name, err.Error())
+				}
 			}
+
+			close(outChan)
 		}()
 
 		// Assume the current service is running
@@ -183,70 +417,113 @@ func (c *Controller) manageLifecycle(name string, task taskDef) {
 		var err error
 
 		for isRunning {
-			isRunning, err = task.IsRunning()
+			isRunning, err = task.IsRunning(ctx)
 			if err != nil {
 				ctxLog.Errorf("error fetching status: %s", err.Error())
 				return
 			}
 
-			time.Sleep(time.Duration(MaintenanceLoopFrequencyMs * time.Millisecond))
+			select {
+			case <-done:
+				if !c.StopTasksOnClose {
+					ctxLog.Info("controller closing, leaving task running")
+					released = true
+					return
+				}
+
+				ctxLog.Info("controller closing, stopping task")
+				if err := task.Stop(ctx); err != nil {
+					ctxLog.Errorf("error stopping task: %s", err.Error())
+				}
+				return
+			case <-time.After(time.Duration(MaintenanceLoopFrequencyMs * time.Millisecond)):
+			}
 		}
 
 		ctxLog.Info("task complete")
 
-		nextTasks, err := task.NextTasks()
+		if c.closing() {
+			ctxLog.Warn("controller closing, not starting subsequent tasks")
+			return
+		}
+
+		nextTasks, err := task.NextTasks(ctx)
 		if err != nil {
 			ctxLog.Errorf("error fetching next tasks: %s", err.Error())
 		}
 
+		publishExit()
+
 		for _, taskName := range nextTasks {
-			if err := c.Start(taskName); err != nil {
+			if err := c.Start(ctx, taskName); err != nil {
 				ctxLog.Errorf("error starting connex task [%s]: %s", taskName, err.Error())
 			}
 		}
 	}()
 }
 
-// Start runs the container as task.
-func (c *Controller) Start(taskName string) error {
-	if task, ok := c.tasks[taskName]; ok {
+// Start runs the container as task, the arguments replacing its command if set.
+// The context only bounds the startup of the task, not its lifecycle.
+func (c *Controller) Start(ctx context.Context, taskName string, args ...string) error {
+	if task, ok := c.getTask(taskName); ok {
 		// Start the task from the definition
-		isRunning, err := task.IsRunning()
+		isRunning, err := task.IsRunning(ctx)
 		if err != nil {
 			return err
 		}
 
 		if !isRunning {
-			if err := task.Start(); err != nil {
+			if err := task.Start(ctx, args...); err != nil {
+				taskFailures.WithLabelValues(taskName, failureStart).Inc()
+				c.Events.Publish(ctx, events.TopicTaskFailed, map[string]interface{}{
+					"task":   taskName,
+					"reason": failureStart,
+					"error":  err.Error(),
+				})
 				return err
 			}
+			taskStarts.WithLabelValues(taskName).Inc()
+
+			// The published ports let the registry track the services of the tasks.
+			data := map[string]interface{}{"task": taskName}
+			if publisher, ok := task.(portPublisher); ok {
+				if ports := publisher.PublishedPorts(); len(ports) > 0 {
+					data["ports"] = ports
+				}
+			}
+			c.Events.Publish(ctx, events.TopicTaskStarted, data)
 		} else {
-			logrus.Infof("task [%s] is already running", taskName)
+			request.Log(ctx).Infof("task [%s] is already running", taskName)
 		}
 
 		// Run the task
-		c.manageLifecycle(taskName, task)
+		c.manageLifecycle(ctx, taskName, task)
 		return nil
 	}
 
-	return fmt.Errorf("unknown task: %s", taskName)
+	return apierr.NotFound("unknown task: %s", taskName).WithDetail("task", taskName)
 }
 
 // Stop stops a task.
-func (c *Controller) Stop(taskName string) error {
-	if task, ok := c.tasks[taskName]; ok {
-		isRunning, err := task.IsRunning()
+func (c *Controller) Stop(ctx context.Context, taskName string) error {
+	if task, ok := c.getTask(taskName); ok {
+		isRunning, err := task.IsRunning(ctx)
 		if err != nil {
 			return err
 		}
 
 		if !isRunning {
-			logrus.Infof("task [%s] is not running", taskName)
+			request.Log(ctx).Infof("task [%s] is not running", taskName)
 			return nil
 		}
 
-		return task.Stop()
+		if err := task.Stop(ctx); err != nil {
+			return err
+		}
+		taskStops.WithLabelValues(taskName).Inc()
+		c.Events.Publish(ctx, events.TopicTaskStopped, map[string]interface{}{"task": taskName})
+		return nil
 	}
 
-	return fmt.Errorf("unknown task: %s", taskName)
+	return apierr.NotFound("unknown task: %s", taskName).WithDetail("task", taskName)
 }
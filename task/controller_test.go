@@ -1,9 +1,14 @@
 package task_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/dalloriam/orc/events"
 	"github.com/dalloriam/orc/task"
 	"github.com/sirupsen/logrus"
 )
@@ -27,11 +32,13 @@ type mocktask struct {
 
 	CurrentlyRunning bool
 	ChainedTasks     []string
+	Ports            map[string]int
 
 	CallChain []string
+	Arguments []string
 }
 
-func (m *mocktask) IsRunning() (bool, error) {
+func (m *mocktask) IsRunning(ctx context.Context) (bool, error) {
 	if m.ShouldIsRunningFail {
 		return false, errors.New("something terrible happened")
 	}
@@ -41,16 +48,17 @@ func (m *mocktask) IsRunning() (bool, error) {
 	return m.CurrentlyRunning, nil
 }
 
-func (m *mocktask) Start() error {
+func (m *mocktask) Start(ctx context.Context, args ...string) error {
 	if m.ShouldStartFail {
 		return errors.New("Something terrible happened")
 	}
 	m.CallChain = append(m.CallChain, "start")
+	m.Arguments = args
 	m.CurrentlyRunning = true
 	return nil
 }
 
-func (m *mocktask) Stop() error {
+func (m *mocktask) Stop(ctx context.Context) error {
 	if m.ShouldStopFail {
 		return errors.New("something terrible happened")
 	}
@@ -59,7 +67,7 @@ func (m *mocktask) Stop() error {
 	return nil
 }
 
-func (m *mocktask) Cleanup() error {
+func (m *mocktask) Cleanup(ctx context.Context) error {
 	if m.ShouldCleanupFail {
 		return errors.New("something terrible happened")
 	}
@@ -68,7 +76,11 @@ func (m *mocktask) Cleanup() error {
 	return nil
 }
 
-func (m *mocktask) NextTasks() ([]string, error) {
+func (m *mocktask) PublishedPorts() map[string]int {
+	return m.Ports
+}
+
+func (m *mocktask) NextTasks(ctx context.Context) ([]string, error) {
 	if m.ShouldNextTasksFail {
 		return nil, errors.New("something terrible happened")
 	}
@@ -91,7 +103,7 @@ func TestNewController(t *testing.T) {
 	}
 
 	for _, tCase := range cases {
-		controller, err := task.NewController(tCase.testDataDir, false)
+		controller, err := task.NewController(tCase.testDataDir, false, true)
 
 		if tCase.wantErr {
 			if err == nil {
@@ -192,7 +204,7 @@ func TestController_Start(t *testing.T) {
 				c.AddTask(k, v)
 			}
 
-			err := c.Start(tCase.taskToStart)
+			err := c.Start(context.Background(), tCase.taskToStart)
 
 			for i := 0; i < len(tCase.expectedCallChain); i++ {
 				actualCallChain := tCase.tasks[tCase.taskToStart].CallChain
@@ -218,7 +230,7 @@ func TestController_Start(t *testing.T) {
 				return
 			}
 
-			tCase.tasks[tCase.taskToStart].Stop()
+			tCase.tasks[tCase.taskToStart].Stop(context.Background())
 
 			// Wait until task done.
 			for _ = range c.RunningTasks[tCase.taskToStart] {
@@ -299,7 +311,7 @@ func TestController_Stop(t *testing.T) {
 				c.AddTask(k, v)
 			}
 
-			err := c.Stop(tCase.taskToStop)
+			err := c.Stop(context.Background(), tCase.taskToStop)
 
 			for i := 0; i < len(tCase.expectedCallChain); i++ {
 				actualCallChain := tCase.tasks[tCase.taskToStop].CallChain
@@ -327,3 +339,199 @@ func TestController_Stop(t *testing.T) {
 		})
 	}
 }
+
+func TestController_Close(t *testing.T) {
+	type testCase struct {
+		name             string
+		stopTasksOnClose bool
+
+		expectedCalls   []string
+		unexpectedCalls []string
+	}
+
+	cases := []testCase{
+		{"leaves tasks running", false, []string{"start"}, []string{"stop", "cleanup", "next_tasks"}},
+		{"stops tasks", true, []string{"start", "stop", "cleanup"}, []string{"next_tasks"}},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			c := &task.Controller{
+				RunningTasks:     make(map[string]chan bool),
+				StopTasksOnClose: tCase.stopTasksOnClose,
+			}
+
+			mock := &mocktask{}
+			c.AddTask("hello", mock)
+
+			if err := c.Start(context.Background(), "hello"); err != nil {
+				t.Errorf("expected no error, got %s", err.Error())
+				return
+			}
+
+			if err := c.Close(); err != nil {
+				t.Errorf("expected no error, got %s", err.Error())
+				return
+			}
+
+			called := make(map[string]bool)
+			for _, call := range mock.CallChain {
+				called[call] = true
+			}
+
+			for _, call := range tCase.expectedCalls {
+				if !called[call] {
+					t.Errorf("expected %s to be called, got %v", call, mock.CallChain)
+				}
+			}
+
+			for _, call := range tCase.unexpectedCalls {
+				if called[call] {
+					t.Errorf("expected %s not to be called, got %v", call, mock.CallChain)
+				}
+			}
+
+			if len(c.RunningTasks) != 0 {
+				t.Errorf("expected no managed tasks after close, got %d", len(c.RunningTasks))
+			}
+		})
+	}
+}
+
+func TestController_List(t *testing.T) {
+	c := &task.Controller{
+		RunningTasks: map[string]chan bool{"web": make(chan bool)},
+	}
+	c.AddTask("web", &mocktask{CurrentlyRunning: true})
+	c.AddTask("backup", &mocktask{})
+
+	out, err := c.Execute(context.Background(), "list", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	var actual struct {
+		Tasks []task.TaskStatus `json:"tasks"`
+	}
+	if err := json.Unmarshal(out, &actual); err != nil {
+		t.Fatalf("execute returned invalid JSON: %s", out)
+	}
+
+	expected := []task.TaskStatus{{Name: "backup"}, {Name: "web", Running: true}}
+	if !reflect.DeepEqual(actual.Tasks, expected) {
+		t.Errorf("expected tasks %v, got %v", expected, actual.Tasks)
+	}
+}
+
+func TestController_StartArguments(t *testing.T) {
+	c := &task.Controller{
+		RunningTasks: make(map[string]chan bool),
+	}
+	mock := &mocktask{}
+	c.AddTask("latex", mock)
+
+	data := map[string]interface{}{"name": "latex", "arguments": []interface{}{"make", "clean"}}
+	if _, err := c.Execute(context.Background(), "start", data); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if expected := []string{"make", "clean"}; !reflect.DeepEqual(mock.Arguments, expected) {
+		t.Errorf("expected the task to start with %v, got %v", expected, mock.Arguments)
+	}
+}
+
+func TestController_StartFailureEvent(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe([]string{events.TopicTaskFailed}, 0)
+	defer sub.Close()
+
+	c := &task.Controller{
+		RunningTasks: make(map[string]chan bool),
+		Events:       bus,
+	}
+	c.AddTask("hello", &mocktask{ShouldStartFail: true})
+
+	if err := c.Start(context.Background(), "hello"); err == nil {
+		t.Fatalf("expected error, got none")
+	}
+
+	select {
+	case evt := <-sub.Events():
+		if evt.Data["task"] != "hello" || evt.Data["reason"] != "start" {
+			t.Errorf("unexpected event data: %v", evt.Data)
+		}
+	default:
+		t.Errorf("expected a %s event", events.TopicTaskFailed)
+	}
+}
+
+func TestController_StartedEvent(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe([]string{events.TopicTaskStarted}, 0)
+	defer sub.Close()
+
+	c := &task.Controller{
+		RunningTasks: make(map[string]chan bool),
+		Events:       bus,
+	}
+	web := &mocktask{Ports: map[string]int{"8080": 80}}
+	c.AddTask("web", web)
+
+	if err := c.Start(context.Background(), "web"); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	select {
+	case evt := <-sub.Events():
+		if evt.Data["task"] != "web" || !reflect.DeepEqual(evt.Data["ports"], map[string]int{"8080": 80}) {
+			t.Errorf("unexpected event data: %v", evt.Data)
+		}
+	default:
+		t.Errorf("expected a %s event", events.TopicTaskStarted)
+	}
+
+	web.Stop(context.Background())
+	for range c.RunningTasks["web"] {
+	}
+}
+
+func TestController_ChainedEvents(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe([]string{events.TopicTaskStarted, events.TopicTaskExited}, 0)
+	defer sub.Close()
+
+	c := &task.Controller{
+		RunningTasks: make(map[string]chan bool),
+		Events:       bus,
+	}
+	build := &mocktask{ChainedTasks: []string{"deploy"}}
+	deploy := &mocktask{}
+	c.AddTask("build", build)
+	c.AddTask("deploy", deploy)
+
+	if err := c.Start(context.Background(), "build"); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	<-sub.Events()
+
+	build.Stop(context.Background())
+
+	var received []string
+	for len(received) < 2 {
+		select {
+		case evt := <-sub.Events():
+			received = append(received, evt.Topic+" "+evt.Data["task"].(string))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the events, got %v", received)
+		}
+	}
+
+	expected := []string{events.TopicTaskExited + " build", events.TopicTaskStarted + " deploy"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %v, got %v", expected, received)
+	}
+
+	deploy.Stop(context.Background())
+	for range c.RunningTasks["deploy"] {
+	}
+}
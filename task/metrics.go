@@ -0,0 +1,25 @@
+package task
+
+import "github.com/dalloriam/orc/metrics"
+
+// Reasons of task failures.
+const (
+	failureStart = "start"
+	failureExit  = "exit"
+)
+
+var (
+	taskStarts   = metrics.NewCounterVec("orc_task_starts_total", "Number of tasks started.", "task")
+	taskStops    = metrics.NewCounterVec("orc_task_stops_total", "Number of tasks stopped on request.", "task")
+	taskFailures = metrics.NewCounterVec("orc_task_failures_total", "Number of tasks that failed to start or exited with a non-zero code.", "task", "reason")
+	tasksRunning = metrics.NewGaugeVec("orc_tasks_running", "Number of tasks currently running.")
+	taskDuration = metrics.NewHistogramVec(
+		"orc_task_run_duration_seconds", "Time during which tasks ran, from their start to their exit.",
+		[]float64{1, 10, 60, 300, 900, 1800, 3600, 7200, 14400, 43200, 86400}, "task",
+	)
+)
+
+func init() {
+	metrics.MustRegister(taskStarts, taskStops, taskFailures, tasksRunning, taskDuration)
+	tasksRunning.WithLabelValues().Set(0)
+}
@@ -3,5 +3,14 @@ package task
 // StartPayload represents a command payload sent to the task module.
 type StartPayload struct {
 	TaskName  string   `json:"name" mapstructure:"name"`
-	Arguments []string `json:"arguments"`
+	Arguments []string `json:"arguments" mapstructure:"arguments"`
+}
+
+// Settings represents the configuration of the task module.
+type Settings struct {
+	// SkipInitialization disables pulling the task images when the module is loaded.
+	SkipInitialization bool `json:"skip_initialization" mapstructure:"skip_initialization"`
+
+	// StopOnExit stops the running tasks when ORC shuts down instead of leaving them running.
+	StopOnExit bool `json:"stop_on_exit" mapstructure:"stop_on_exit"`
 }
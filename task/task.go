@@ -2,11 +2,17 @@ package task
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"path"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/dalloriam/orc/request"
 	"github.com/sirupsen/logrus"
 
 	"github.com/docker/docker/api/types/container"
@@ -20,12 +26,12 @@ import (
 )
 
 type taskDef interface {
-	IsRunning() (bool, error)
-	Start() error
-	Stop() error
-	Cleanup() error
+	IsRunning(ctx context.Context) (bool, error)
+	Start(ctx context.Context, args ...string) error
+	Stop(ctx context.Context) error
+	Cleanup(ctx context.Context) error
 
-	NextTasks() ([]string, error)
+	NextTasks(ctx context.Context) ([]string, error)
 }
 
 type dockerClient interface {
@@ -55,6 +61,60 @@ type Task struct {
 	OnFailure []string `json:"on_failure,omitempty"`
 
 	Client dockerClient
+
+	mu       sync.Mutex
+	exitCode int
+	exited   bool
+}
+
+// PublishedPorts returns the container ports of the task, by host port.
+func (s *Task) PublishedPorts() map[string]int {
+	return s.Ports
+}
+
+// ExitCode returns the exit code of the last run of the task, once it exited.
+func (s *Task) ExitCode() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitCode, s.exited
+}
+
+func (s *Task) setExitCode(code int, exited bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exitCode, s.exited = code, exited
+}
+
+// ReadDefinitions reads the task definitions of a directory, one JSON file per task.
+func ReadDefinitions(directory string) ([]*Task, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("invalid task directory: %s", directory)
+	}
+
+	var tasks []*Task
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+
+		taskPath := path.Join(directory, f.Name())
+
+		data, err := ioutil.ReadFile(taskPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var task Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, &task)
+	}
+
+	return tasks, nil
 }
 
 func (s *Task) initClient() (dockerClient, error) {
@@ -70,7 +130,7 @@ func (s *Task) initClient() (dockerClient, error) {
 	return client, nil
 }
 
-func (s *Task) containerID() (string, error) {
+func (s *Task) containerID(ctx context.Context) (string, error) {
 	cli, err := s.initClient()
 	if err != nil {
 		return "", err
@@ -79,7 +139,7 @@ func (s *Task) containerID() (string, error) {
 	filter := filters.NewArgs()
 	filter.Add("name", s.Name)
 
-	containers, err := cli.ContainerList(context.Background(), types.ContainerListOptions{Filters: filter, All: true})
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{Filters: filter, All: true})
 	if err != nil {
 		return "", err
 	}
@@ -92,7 +152,7 @@ func (s *Task) containerID() (string, error) {
 }
 
 // IsRunning returns whether the service is currently running.
-func (s *Task) IsRunning() (bool, error) {
+func (s *Task) IsRunning(ctx context.Context) (bool, error) {
 	cli, err := s.initClient()
 	if err != nil {
 		return false, err
@@ -102,7 +162,7 @@ func (s *Task) IsRunning() (bool, error) {
 	filter.Add("name", s.Name)
 
 	containers, err := cli.ContainerList(
-		context.Background(), types.ContainerListOptions{Filters: filter},
+		ctx, types.ContainerListOptions{Filters: filter},
 	)
 	if err != nil {
 		return false, err
@@ -112,31 +172,29 @@ func (s *Task) IsRunning() (bool, error) {
 }
 
 // Initialize pulls the docker image associated with the service, if required.
-func (s *Task) Initialize() error {
-	logrus.Debugf("ensuring image [%s] is available...", s.Image)
+func (s *Task) Initialize(ctx context.Context) error {
+	request.Log(ctx).Debugf("ensuring image [%s] is available...", s.Image)
 	cli, err := s.initClient()
 	if err != nil {
 		return err
 	}
 
-	_, err = cli.ImagePull(context.Background(), s.Image, types.ImagePullOptions{})
+	_, err = cli.ImagePull(ctx, s.Image, types.ImagePullOptions{})
 
 	if err == nil {
-		logrus.Debugf("image [%s] is available", s.Image)
+		request.Log(ctx).Debugf("image [%s] is available", s.Image)
 	}
 	return err
 }
 
-func (s *Task) actuallyStart() error {
-	logrus.Infof("starting service: %s", s.Name)
+func (s *Task) actuallyStart(ctx context.Context, args []string) error {
+	request.Log(ctx).Infof("starting service: %s", s.Name)
 
 	cli, err := s.initClient()
 	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
-
 	exposedPorts := nat.PortSet{}
 	portMapping := nat.PortMap{}
 
@@ -163,9 +221,14 @@ func (s *Task) actuallyStart() error {
 		volumeBinds = append(volumeBinds, fmt.Sprintf("%s:%s", srcVol, dstVol))
 	}
 
+	command := s.Command
+	if len(args) > 0 {
+		command = args
+	}
+
 	resp, err := cli.ContainerCreate(ctx, &container.Config{
 		Image:        s.Image,
-		Cmd:          s.Command,
+		Cmd:          command,
 		Tty:          true,
 		ExposedPorts: exposedPorts,
 		Env:          envVars,
@@ -182,18 +245,59 @@ func (s *Task) actuallyStart() error {
 		return err
 	}
 
-	logrus.Infof("service [%s] started", s.Name)
+	request.Log(ctx).Infof("service [%s] started", s.Name)
 
 	return nil
 }
 
+// DockerRunArgs returns the arguments of "docker run" creating the same container as
+// the task, for running it without ORC. The container is detached as when ORC starts
+// it, and removed once it exits unless the task is a daemon.
+func (s *Task) DockerRunArgs() []string {
+	args := []string{"--name", s.Name, "-t", "-d"}
+	if !s.Daemon {
+		args = append(args, "--rm")
+	}
+
+	for _, name := range sortedKeys(s.Environment) {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", name, s.Environment[name]))
+	}
+
+	hostPorts := make([]string, 0, len(s.Ports))
+	for hostPort := range s.Ports {
+		hostPorts = append(hostPorts, hostPort)
+	}
+	sort.Strings(hostPorts)
+	for _, hostPort := range hostPorts {
+		args = append(args, "-p", fmt.Sprintf("0.0.0.0:%s:%d/tcp", hostPort, s.Ports[hostPort]))
+	}
+
+	for _, src := range sortedKeys(s.Volumes) {
+		args = append(args, "-v", fmt.Sprintf("%s:%s", src, s.Volumes[src]))
+	}
+
+	args = append(args, s.Image)
+	return append(args, s.Command...)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // Start starts the service (if it is not already running).
-func (s *Task) Start() error {
-	if err := s.actuallyStart(); err != nil {
+// The arguments, if any, replace the command of the task.
+func (s *Task) Start(ctx context.Context, args ...string) error {
+	s.setExitCode(0, false)
+	if err := s.actuallyStart(ctx, args); err != nil {
 		return err
 	}
 
-	isRunning, err := s.IsRunning()
+	isRunning, err := s.IsRunning(ctx)
 	if err != nil {
 		return err
 	}
@@ -205,32 +309,32 @@ func (s *Task) Start() error {
 }
 
 // Stop stops the service.
-func (s *Task) Stop() error {
-	logrus.Infof("stopping service: %s", s.Name)
+func (s *Task) Stop(ctx context.Context) error {
+	request.Log(ctx).Infof("stopping service: %s", s.Name)
 
 	cli, err := s.initClient()
 	if err != nil {
 		return err
 	}
 
-	containerID, err := s.containerID()
+	containerID, err := s.containerID(ctx)
 	if err != nil {
 		return err
 	}
 
 	duration := 10 * time.Second
-	return cli.ContainerStop(context.Background(), containerID, &duration)
+	return cli.ContainerStop(ctx, containerID, &duration)
 }
 
 // Cleanup picks up the pieces & deletes the container.
-func (s *Task) Cleanup() error {
+func (s *Task) Cleanup(ctx context.Context) error {
 
 	cli, err := s.initClient()
 	if err != nil {
 		return err
 	}
 
-	containerID, err := s.containerID()
+	containerID, err := s.containerID(ctx)
 	if err != nil {
 		if strings.HasPrefix(err.Error(), "unexpected state") {
 			// Container was already cleaned up.
@@ -239,19 +343,19 @@ func (s *Task) Cleanup() error {
 		return err
 	}
 
-	logrus.Infof("cleaning up container: %s", s.Name)
-	return cli.ContainerRemove(context.Background(), containerID, types.ContainerRemoveOptions{Force: true})
+	request.Log(ctx).Infof("cleaning up container: %s", s.Name)
+	return cli.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true})
 }
 
 // NextTasks fetches the exit status of the container, and
 // returns s.OnSuccess if 0, else s.OnFailure.
-func (s *Task) NextTasks() ([]string, error) {
-	ctxLog := logrus.WithFields(logrus.Fields{
+func (s *Task) NextTasks(ctx context.Context) ([]string, error) {
+	ctxLog := request.Log(ctx).WithFields(logrus.Fields{
 		"module": moduleName,
 		"task":   s.Name,
 	})
 
-	containerID, err := s.containerID()
+	containerID, err := s.containerID(ctx)
 	if err != nil {
 		if strings.HasPrefix(err.Error(), "unexpected state") {
 			// Container was already cleaned up, we can't risk starting anymore tasks.
@@ -266,7 +370,7 @@ func (s *Task) NextTasks() ([]string, error) {
 		return nil, err
 	}
 
-	containerInfo, err := cli.ContainerInspect(context.Background(), containerID)
+	containerInfo, err := cli.ContainerInspect(ctx, containerID)
 	if err != nil {
 		return nil, err
 	}
@@ -276,9 +380,12 @@ func (s *Task) NextTasks() ([]string, error) {
 	}
 
 	ctxLog.Infof("task exited with exit code %d", containerInfo.State.ExitCode)
+	s.setExitCode(containerInfo.State.ExitCode, true)
 	if containerInfo.State.ExitCode == 0 {
 		return s.OnSuccess, nil
 	}
 
+	taskFailures.WithLabelValues(s.Name, failureExit).Inc()
+
 	return s.OnFailure, nil
 }
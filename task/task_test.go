@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"reflect"
 	"testing"
 	"time"
 
@@ -112,7 +113,7 @@ func TestTask_IsRunning(t *testing.T) {
 
 			task := task.Task{Client: mockClient}
 
-			isRunning, err := task.IsRunning()
+			isRunning, err := task.IsRunning(context.Background())
 
 			if (err != nil) != tCase.wantErr {
 				t.Errorf("expected error: %v, got err=%v", tCase.wantErr, err)
@@ -142,7 +143,7 @@ func TestTask_Initialize(t *testing.T) {
 		t.Run(tCase.name, func(t *testing.T) {
 			task := task.Task{Client: &dockerClientMock{ShouldImagePullFail: tCase.imagePullFails}}
 
-			err := task.Initialize()
+			err := task.Initialize(context.Background())
 
 			if (err != nil) != tCase.wantErr {
 				t.Errorf("expected error: %v, got err=%v", tCase.wantErr, err)
@@ -236,7 +237,7 @@ func TestTask_Start(t *testing.T) {
 
 			tCase.task.Client = mockClient
 
-			err := tCase.task.Start()
+			err := tCase.task.Start(context.Background())
 
 			if (err != nil) != tCase.wantErr {
 				t.Errorf("expected error: %v, got err=%v", tCase.wantErr, err)
@@ -311,7 +312,7 @@ func TestTask_Stop(t *testing.T) {
 			task := &task.Task{Client: mockClient}
 
 			// TODO: Validate that the container killed by stop() is the correct one.
-			err := task.Stop()
+			err := task.Stop(context.Background())
 
 			if (err != nil) != tCase.wantErr {
 				t.Errorf("expected error: %v, got err=%v", tCase.wantErr, err)
@@ -350,7 +351,7 @@ func TestTask_Cleanup(t *testing.T) {
 
 			task := &task.Task{Client: mockClient}
 
-			err := task.Cleanup()
+			err := task.Cleanup(context.Background())
 
 			if (err != nil) != tCase.wantErr {
 				t.Errorf("expected error: %v, got err=%v", tCase.wantErr, err)
@@ -406,7 +407,7 @@ func TestTask_NextTasks(t *testing.T) {
 			}
 
 			task := &task.Task{OnSuccess: tCase.onSuccess, OnFailure: tCase.onFailure, Client: mockClient}
-			nexts, err := task.NextTasks()
+			nexts, err := task.NextTasks(context.Background())
 
 			if (err != nil) != tCase.wantErr {
 				t.Errorf("expected error: %v, got err=%v", tCase.wantErr, err)
@@ -425,3 +426,44 @@ func TestTask_NextTasks(t *testing.T) {
 		})
 	}
 }
+
+func TestTask_DockerRunArgs(t *testing.T) {
+	type testCase struct {
+		name string
+		task *task.Task
+
+		expected []string
+	}
+
+	cases := []testCase{
+		{
+			name: "Task",
+			task: &task.Task{
+				Name:        "latex",
+				Image:       "latex:latest",
+				Command:     []string{"make", "pdf"},
+				Environment: map[string]string{"B": "2", "A": "1"},
+				Volumes:     map[string]string{"/home/docs": "/docs"},
+			},
+			expected: []string{"--name", "latex", "-t", "-d", "--rm", "-e", "A=1", "-e", "B=2", "-v", "/home/docs:/docs", "latex:latest", "make", "pdf"},
+		},
+		{
+			name: "Daemon",
+			task: &task.Task{
+				Name:   "plex",
+				Image:  "plex:latest",
+				Daemon: true,
+				Ports:  map[string]int{"8080": 80, "32400": 32400},
+			},
+			expected: []string{"--name", "plex", "-t", "-d", "-p", "0.0.0.0:32400:32400/tcp", "-p", "0.0.0.0:8080:80/tcp", "plex:latest"},
+		},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if actual := tCase.task.DockerRunArgs(); !reflect.DeepEqual(actual, tCase.expected) {
+				t.Errorf("expected %q, got %q", tCase.expected, actual)
+			}
+		})
+	}
+}
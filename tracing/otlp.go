@@ -0,0 +1,273 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	tracesPath = "/v1/traces"
+	scopeName  = "github.com/dalloriam/orc"
+
+	// Spans are exported in batches, when the batch is full or when the flush interval elapses.
+	maxBatchSize  = 256
+	flushInterval = 5 * time.Second
+
+	// maxPendingSpans bounds the memory used when the collector is unreachable.
+	maxPendingSpans = 4096
+
+	exportTimeout = 10 * time.Second
+)
+
+// Exporter sends finished spans to an OpenTelemetry collector using OTLP over HTTP, in JSON.
+type Exporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+	dropped int
+
+	flush     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	loop      sync.WaitGroup
+}
+
+// NewExporter returns an exporter sending spans to the OTLP endpoint, e.g. http://localhost:4318.
+func NewExporter(endpoint, serviceName string) *Exporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, tracesPath) {
+		url += tracesPath
+	}
+
+	e := &Exporter{
+		url:         url,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+		flush:       make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+
+	e.loop.Add(1)
+	go e.run()
+
+	return e
+}
+
+func (e *Exporter) add(s *Span) {
+	e.mu.Lock()
+	if len(e.pending) >= maxPendingSpans {
+		e.dropped++
+	} else {
+		e.pending = append(e.pending, s)
+	}
+	full := len(e.pending) >= maxBatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *Exporter) run() {
+	defer e.loop.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		case <-e.flush:
+		}
+
+		if err := e.Flush(context.Background()); err != nil {
+			logrus.Warnf("error exporting spans: %s", err.Error())
+		}
+	}
+}
+
+// Flush exports the pending spans.
+func (e *Exporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	spans := e.pending
+	dropped := e.dropped
+	e.pending = nil
+	e.dropped = 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		logrus.Warnf("dropped %d spans, the trace collector is not keeping up", dropped)
+	}
+
+	for len(spans) > 0 {
+		batch := spans
+		if len(batch) > maxBatchSize {
+			batch = batch[:maxBatchSize]
+		}
+		spans = spans[len(batch):]
+
+		if err := e.export(ctx, batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Shutdown stops the exporter and exports the pending spans.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.closeOnce.Do(func() {
+		close(e.done)
+	})
+	e.loop.Wait()
+
+	return e.Flush(ctx)
+}
+
+func (e *Exporter) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("trace collector returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              Kind           `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+// OTLP status codes.
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// payload builds the ExportTraceServiceRequest of the spans.
+func (e *Exporter) payload(spans []*Span) map[string]interface{} {
+	encoded := make([]otlpSpan, 0, len(spans))
+
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        attributes(s.attributes),
+			Status:            otlpStatus{Code: statusUnset},
+		}
+		if s.parentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.failed {
+			span.Status = otlpStatus{Code: statusError, Message: s.errMessage}
+		}
+		s.mu.Unlock()
+
+		encoded = append(encoded, span)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": attributes(map[string]interface{}{"service.name": e.serviceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": scopeName},
+						"spans": encoded,
+					},
+				},
+			},
+		},
+	}
+}
+
+// attributes encodes the attributes as OTLP key/values, sorted by key.
+func attributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		var value map[string]interface{}
+		switch v := attrs[k].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: value})
+	}
+
+	return kvs
+}
@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dalloriam/orc/request"
+)
+
+// Kind is the role of a span in a trace, as defined by OTLP.
+type Kind int
+
+// Supported span kinds.
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+var (
+	exporterMu sync.RWMutex
+	exporter   *Exporter
+)
+
+// SetExporter sets the exporter receiving the finished spans and returns the previous one.
+// Tracing is disabled while no exporter is set.
+func SetExporter(e *Exporter) *Exporter {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+
+	previous := exporter
+	exporter = e
+	return previous
+}
+
+func currentExporter() *Exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+	return exporter
+}
+
+// Span is a traced operation. A nil span is valid and records nothing,
+// which is what Start returns when tracing is disabled.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+
+	name  string
+	kind  Kind
+	start time.Time
+	end   time.Time
+
+	mu         sync.Mutex
+	attributes map[string]interface{}
+	errMessage string
+	failed     bool
+
+	exporter *Exporter
+}
+
+// Start starts a span, child of the trace parent carried by the context if any.
+// The returned context carries the new span as trace parent.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	e := currentExporter()
+	if e == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+		exporter:   e,
+	}
+
+	if traceID, parentID, ok := ParseTraceParent(request.TraceParent(ctx)); ok {
+		s.traceID = traceID
+		s.parentID = parentID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+
+	return request.WithTraceParent(ctx, s.TraceParent()), s
+}
+
+// TraceParent returns the W3C trace parent identifying the span.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// SetAttribute annotates the span. Values must be strings, booleans or numbers.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.failed = true
+	s.errMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and hands it to the exporter.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	s.exporter.add(s)
+}
+
+// ParseTraceParent parses a W3C trace parent, e.g. 00-<trace id>-<parent id>-01.
+func ParseTraceParent(traceParent string) (traceID [16]byte, parentID [8]byte, ok bool) {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false
+	}
+
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == [16]byte{} {
+		return traceID, parentID, false
+	}
+
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil || parentID == [8]byte{} {
+		return traceID, parentID, false
+	}
+
+	return traceID, parentID, true
+}
@@ -0,0 +1,123 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dalloriam/orc/request"
+	"github.com/dalloriam/orc/tracing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	type testCase struct {
+		name        string
+		traceParent string
+
+		wantOk bool
+	}
+
+	cases := []testCase{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"empty", "", false},
+		{"bad version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"short span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01", false},
+		{"not hex", "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", false},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if _, _, ok := tracing.ParseTraceParent(tCase.traceParent); ok != tCase.wantOk {
+				t.Errorf("expected ok: %v, got %v", tCase.wantOk, ok)
+			}
+		})
+	}
+}
+
+func TestStart_Disabled(t *testing.T) {
+	tracing.SetExporter(nil)
+
+	ctx, span := tracing.Start(context.Background(), "test", tracing.KindInternal)
+	if span != nil {
+		t.Errorf("expected no span when tracing is disabled")
+	}
+
+	// Nil spans must be usable.
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("failure"))
+	span.End()
+
+	if request.TraceParent(ctx) != "" {
+		t.Errorf("expected no trace parent, got %s", request.TraceParent(ctx))
+	}
+}
+
+func TestExporter(t *testing.T) {
+	var mu sync.Mutex
+	var received []map[string]interface{}
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected collector path: %s", r.URL.Path)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload: %s", err.Error())
+		}
+
+		mu.Lock()
+		received = append(received, payload)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	exporter := tracing.NewExporter(collector.URL, "orc-test")
+	tracing.SetExporter(exporter)
+	defer tracing.SetExporter(nil)
+
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := request.WithTraceParent(context.Background(), parent)
+
+	ctx, span := tracing.Start(ctx, "keyval/get", tracing.KindInternal)
+	span.SetAttribute("orc.module", "keyval")
+	span.SetError(errors.New("no such key"))
+	span.End()
+
+	if traceParent := request.TraceParent(ctx); !strings.HasPrefix(traceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || traceParent == parent {
+		t.Errorf("expected a child trace parent, got %s", traceParent)
+	}
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) != 1 {
+		t.Fatalf("expected 1 export, got %d", len(received))
+	}
+
+	encoded, _ := json.Marshal(received[0])
+	for _, expected := range []string{
+		`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`"parentSpanId":"00f067aa0ba902b7"`,
+		`"name":"keyval/get"`,
+		`"status":{"code":2,"message":"no such key"}`,
+		`{"key":"orc.module","value":{"stringValue":"keyval"}}`,
+		`{"key":"service.name","value":{"stringValue":"orc-test"}}`,
+	} {
+		if !strings.Contains(string(encoded), expected) {
+			t.Errorf("expected export to contain %s, got %s", expected, string(encoded))
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package webhook
+
+import "github.com/dalloriam/orc/metrics"
+
+// Results of webhook deliveries.
+const (
+	resultDelivered = "delivered"
+	resultFailed    = "failed"
+)
+
+var (
+	webhookDeliveries = metrics.NewCounterVec("orc_webhook_deliveries_total", "Number of webhook deliveries, by result.", "webhook", "result")
+	webhookRetries    = metrics.NewCounterVec("orc_webhook_retries_total", "Number of webhook delivery attempts that were retried.", "webhook")
+)
+
+func init() {
+	metrics.MustRegister(webhookDeliveries, webhookRetries)
+}
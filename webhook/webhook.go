@@ -0,0 +1,436 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/request"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers of the webhook requests.
+const (
+	EventHeader = "X-Orc-Event"
+
+	// DeliveryHeader holds the ID of the delivery, unique per webhook and event and
+	// kept across retries, so that receivers can discard duplicates.
+	DeliveryHeader  = "X-Orc-Delivery"
+	SignatureHeader = "X-Orc-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// Default delivery settings.
+const (
+	DefaultMaxAttempts = 5
+	DefaultTimeout     = "10s"
+
+	// DefaultRetryBackoff is the delay before the first retry, which doubles on every attempt.
+	DefaultRetryBackoff = time.Second
+
+	queueSize = 256
+	workers   = 4
+)
+
+// Rule posts the events matching its topics to a URL, e.g. the task/failed events
+// of the backup task:
+//
+//	{"name": "backup-failed", "url": "https://hooks.example.com/orc",
+//	 "topics": ["task/failed"], "filter": {"task": "backup"},
+//	 "body": "{\"text\": \"backup failed with exit code {{.Data.exit_code}}\"}"}
+type Rule struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+
+	// Topics are the patterns of the events to post, see events.Match.
+	Topics []string `json:"topics"`
+
+	// Filter only posts the events whose data hold these values.
+	Filter map[string]interface{} `json:"filter,omitempty"`
+
+	// Body is a text/template rendering the payload from the event, e.g. {{.Topic}},
+	// {{.Data.task}} or {{json .Data}}. The event is posted as JSON when empty.
+	Body        string            `json:"body,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+
+	// Secret signs the payload with HMAC-SHA256, sent as "sha256=<hex>" in the
+	// X-Orc-Signature header.
+	Secret string `json:"secret,omitempty"`
+
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+	Timeout     string `json:"timeout,omitempty"`
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Validate ensures the rule is usable.
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("webhook name is required")
+	}
+
+	if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url for webhook %s: %s", r.Name, r.URL)
+	}
+
+	if len(r.Topics) == 0 {
+		return fmt.Errorf("no topics for webhook %s", r.Name)
+	}
+	for _, topic := range r.Topics {
+		if !events.ValidPattern(topic) {
+			return fmt.Errorf("invalid topic for webhook %s: %s", r.Name, topic)
+		}
+	}
+
+	if _, err := r.template(); err != nil {
+		return fmt.Errorf("invalid body for webhook %s: %s", r.Name, err.Error())
+	}
+
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("invalid max attempts for webhook %s: %d", r.Name, r.MaxAttempts)
+	}
+
+	if _, err := r.timeout(); err != nil {
+		return fmt.Errorf("invalid timeout for webhook %s: %s", r.Name, err.Error())
+	}
+
+	return nil
+}
+
+func (r *Rule) template() (*template.Template, error) {
+	if r.Body == "" {
+		return nil, nil
+	}
+	return template.New(r.Name).Funcs(templateFuncs).Option("missingkey=zero").Parse(r.Body)
+}
+
+func (r *Rule) timeout() (time.Duration, error) {
+	if r.Timeout == "" {
+		return time.ParseDuration(DefaultTimeout)
+	}
+	return time.ParseDuration(r.Timeout)
+}
+
+func (r *Rule) maxAttempts() int {
+	if r.MaxAttempts == 0 {
+		return DefaultMaxAttempts
+	}
+	return r.MaxAttempts
+}
+
+// Matches returns whether the event must be posted by the rule.
+func (r *Rule) Matches(evt events.Event) bool {
+	if !events.Match(r.Topics, evt.Topic) {
+		return false
+	}
+
+	for key, want := range r.Filter {
+		got, ok := evt.Data[key]
+		if !ok || !sameValue(got, want) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameValue compares values regardless of their numeric types, e.g. the exit code
+// of an event and the float decoded from the configuration.
+func sameValue(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// Sign returns the signature of the payload, as sent in the X-Orc-Signature header.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeadLetter is a delivery that failed all its attempts.
+type DeadLetter struct {
+	Time     time.Time    `json:"time"`
+	Delivery string       `json:"delivery"`
+	Webhook  string       `json:"webhook"`
+	URL      string       `json:"url"`
+	Event    events.Event `json:"event"`
+	Attempts int          `json:"attempts"`
+	Error    string       `json:"error"`
+}
+
+type delivery struct {
+	id   string
+	rule *Rule
+	tmpl *template.Template
+	evt  events.Event
+}
+
+// Dispatcher posts the events of a bus to the webhooks of its rules. Deliveries are
+// retried with an exponential backoff, and the deliveries that keep failing are
+// appended to a dead-letter file, one JSON object per line.
+type Dispatcher struct {
+	// RetryBackoff is the delay before the first retry.
+	RetryBackoff time.Duration
+
+	mu             sync.RWMutex
+	rules          []*Rule
+	templates      map[*Rule]*template.Template
+	deadLetterFile string
+	deadLetterMu   sync.Mutex
+
+	http  *http.Client
+	sub   *events.Subscription
+	queue chan delivery
+
+	done    chan struct{}
+	running sync.WaitGroup
+	close   sync.Once
+}
+
+// NewDispatcher starts posting the events of the bus to the webhooks.
+// Failed deliveries are appended to the dead-letter file.
+func NewDispatcher(bus *events.Bus, deadLetterFile string) *Dispatcher {
+	d := &Dispatcher{
+		RetryBackoff:   DefaultRetryBackoff,
+		templates:      make(map[*Rule]*template.Template),
+		deadLetterFile: deadLetterFile,
+		http:           &http.Client{},
+		sub:            bus.Subscribe(nil, 0),
+		queue:          make(chan delivery, queueSize),
+		done:           make(chan struct{}),
+	}
+
+	d.running.Add(1)
+	go d.dispatch()
+
+	for i := 0; i < workers; i++ {
+		d.running.Add(1)
+		go d.work()
+	}
+
+	return d
+}
+
+// SetRules replaces the rules. Deliveries already queued are not affected.
+func (d *Dispatcher) SetRules(rules []Rule) error {
+	compiled := make([]*Rule, 0, len(rules))
+	templates := make(map[*Rule]*template.Template)
+
+	for i := range rules {
+		rule := rules[i]
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+
+		tmpl, _ := rule.template()
+		compiled = append(compiled, &rule)
+		templates[&rule] = tmpl
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules = compiled
+	d.templates = templates
+
+	return nil
+}
+
+// Close stops the dispatcher. Queued and retried deliveries are dead-lettered.
+func (d *Dispatcher) Close() error {
+	d.close.Do(func() {
+		close(d.done)
+		d.sub.Close()
+	})
+	d.running.Wait()
+	return nil
+}
+
+func (d *Dispatcher) dispatch() {
+	defer d.running.Done()
+	defer close(d.queue)
+
+	for evt := range d.sub.Events() {
+		d.mu.RLock()
+		for _, rule := range d.rules {
+			if !rule.Matches(evt) {
+				continue
+			}
+
+			dlv := delivery{id: request.NewID(), rule: rule, tmpl: d.templates[rule], evt: evt}
+			select {
+			case d.queue <- dlv:
+			default:
+				d.deadLetter(dlv, 0, fmt.Errorf("delivery queue is full"))
+			}
+		}
+		d.mu.RUnlock()
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.running.Done()
+
+	for dlv := range d.queue {
+		select {
+		case <-d.done:
+			d.deadLetter(dlv, 0, fmt.Errorf("dispatcher closed before delivery"))
+		default:
+			d.deliver(dlv)
+		}
+	}
+}
+
+// deliver posts the event, retrying until the attempts of the rule are exhausted.
+func (d *Dispatcher) deliver(dlv delivery) {
+	ctxLog := logrus.WithFields(logrus.Fields{
+		"webhook":    dlv.rule.Name,
+		"delivery":   dlv.id,
+		"topic":      dlv.evt.Topic,
+		"event_id":   dlv.evt.ID,
+		"request_id": dlv.evt.RequestID,
+	})
+
+	payload, err := render(dlv.tmpl, dlv.evt)
+	if err != nil {
+		webhookDeliveries.WithLabelValues(dlv.rule.Name, resultFailed).Inc()
+		d.deadLetter(dlv, 0, err)
+		return
+	}
+
+	backoff := d.RetryBackoff
+	attempts := dlv.rule.maxAttempts()
+
+	for attempt := 1; ; attempt++ {
+		err := d.post(dlv, payload)
+		if err == nil {
+			webhookDeliveries.WithLabelValues(dlv.rule.Name, resultDelivered).Inc()
+			ctxLog.Debugf("webhook delivered after %d attempts", attempt)
+			return
+		}
+
+		if attempt >= attempts {
+			webhookDeliveries.WithLabelValues(dlv.rule.Name, resultFailed).Inc()
+			ctxLog.Errorf("webhook delivery failed after %d attempts: %s", attempt, err.Error())
+			d.deadLetter(dlv, attempt, err)
+			return
+		}
+
+		webhookRetries.WithLabelValues(dlv.rule.Name).Inc()
+		ctxLog.Warnf("webhook delivery failed, retrying in %s: %s", backoff, err.Error())
+
+		select {
+		case <-time.After(backoff):
+		case <-d.done:
+			d.deadLetter(dlv, attempt, fmt.Errorf("dispatcher closed before delivery: %s", err.Error()))
+			return
+		}
+		backoff *= 2
+	}
+}
+
+func render(tmpl *template.Template, evt events.Event) ([]byte, error) {
+	if tmpl == nil {
+		return json.Marshal(evt)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, evt); err != nil {
+		return nil, fmt.Errorf("error rendering body: %s", err.Error())
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *Dispatcher) post(dlv delivery, payload []byte) error {
+	timeout, _ := dlv.rule.timeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, dlv.rule.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	contentType := dlv.rule.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(EventHeader, dlv.evt.Topic)
+	req.Header.Set(DeliveryHeader, dlv.id)
+	if dlv.evt.RequestID != "" {
+		req.Header.Set(request.IDHeader, dlv.evt.RequestID)
+	}
+	for name, val := range dlv.rule.Headers {
+		req.Header.Set(name, val)
+	}
+	if dlv.rule.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(dlv.rule.Secret, payload))
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) deadLetter(dlv delivery, attempts int, cause error) {
+	letter := DeadLetter{
+		Time:     time.Now().UTC(),
+		Delivery: dlv.id,
+		Webhook:  dlv.rule.Name,
+		URL:      dlv.rule.URL,
+		Event:    dlv.evt,
+		Attempts: attempts,
+		Error:    cause.Error(),
+	}
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return
+	}
+
+	d.deadLetterMu.Lock()
+	defer d.deadLetterMu.Unlock()
+
+	f, err := os.OpenFile(d.deadLetterFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		logrus.WithField("webhook", dlv.rule.Name).Errorf("error writing dead letter: %s", err.Error())
+		return
+	}
+	defer f.Close()
+
+	f.Write(append(data, '\n'))
+}
@@ -0,0 +1,225 @@
+package webhook_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dalloriam/orc/events"
+	"github.com/dalloriam/orc/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logrus.SetLevel(logrus.PanicLevel)
+}
+
+func TestRule_Validate(t *testing.T) {
+	type testCase struct {
+		name string
+		rule webhook.Rule
+
+		wantErr bool
+	}
+
+	valid := webhook.Rule{Name: "alerts", URL: "https://hooks.example.com/orc", Topics: []string{"task/failed"}}
+
+	cases := []testCase{
+		{"valid", valid, false},
+		{"no name", webhook.Rule{URL: valid.URL, Topics: valid.Topics}, true},
+		{"bad url", webhook.Rule{Name: "alerts", URL: "hooks.example.com", Topics: valid.Topics}, true},
+		{"no topics", webhook.Rule{Name: "alerts", URL: valid.URL}, true},
+		{"bad topic", webhook.Rule{Name: "alerts", URL: valid.URL, Topics: []string{"task/["}}, true},
+		{"bad body", webhook.Rule{Name: "alerts", URL: valid.URL, Topics: valid.Topics, Body: "{{.Topic"}, true},
+		{"bad timeout", webhook.Rule{Name: "alerts", URL: valid.URL, Topics: valid.Topics, Timeout: "soon"}, true},
+		{"negative attempts", webhook.Rule{Name: "alerts", URL: valid.URL, Topics: valid.Topics, MaxAttempts: -1}, true},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if err := tCase.rule.Validate(); (err != nil) != tCase.wantErr {
+				t.Errorf("expected err: %v, got err=%v", tCase.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	type testCase struct {
+		name   string
+		topics []string
+		filter map[string]interface{}
+
+		expected bool
+	}
+
+	evt := events.Event{
+		Topic: events.TopicTaskFailed,
+		Data:  map[string]interface{}{"task": "backup", "exit_code": 2},
+	}
+
+	cases := []testCase{
+		{"topic", []string{"task/failed"}, nil, true},
+		{"wildcard", []string{"task/*"}, nil, true},
+		{"other topic", []string{"task/started"}, nil, false},
+		{"filter", []string{"task"}, map[string]interface{}{"task": "backup"}, true},
+		{"numeric filter", []string{"task"}, map[string]interface{}{"exit_code": float64(2)}, true},
+		{"other task", []string{"task"}, map[string]interface{}{"task": "web"}, false},
+		{"missing key", []string{"task"}, map[string]interface{}{"reason": "start"}, false},
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			rule := webhook.Rule{Topics: tCase.topics, Filter: tCase.filter}
+			if actual := rule.Matches(evt); actual != tCase.expected {
+				t.Errorf("expected %v, got %v", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+type received struct {
+	body    string
+	headers http.Header
+}
+
+func newDispatcher(t *testing.T, bus *events.Bus, rule webhook.Rule) (*webhook.Dispatcher, string, func()) {
+	dir, err := ioutil.TempDir("", "orc_webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadLetters := path.Join(dir, "dead-letters.jsonl")
+	d := webhook.NewDispatcher(bus, deadLetters)
+	d.RetryBackoff = time.Millisecond
+	if err := d.SetRules([]webhook.Rule{rule}); err != nil {
+		t.Fatal(err)
+	}
+
+	return d, deadLetters, func() {
+		d.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	deliveries := make(chan received, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		fail := attempts < 2
+		mu.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		deliveries <- received{body: string(body), headers: r.Header}
+	}))
+	defer srv.Close()
+
+	bus := events.NewBus()
+	_, _, cleanup := newDispatcher(t, bus, webhook.Rule{
+		Name:   "alerts",
+		URL:    srv.URL,
+		Topics: []string{events.TopicTaskFailed},
+		Filter: map[string]interface{}{"task": "backup"},
+		Body:   `{"text": "{{.Data.task}} failed with exit code {{.Data.exit_code}}"}`,
+		Secret: "hunter2",
+	})
+	defer cleanup()
+
+	bus.Publish(context.Background(), events.TopicTaskFailed, map[string]interface{}{"task": "web", "exit_code": 1})
+	bus.Publish(context.Background(), events.TopicTaskFailed, map[string]interface{}{"task": "backup", "exit_code": 2})
+
+	select {
+	case dlv := <-deliveries:
+		expected := `{"text": "backup failed with exit code 2"}`
+		if dlv.body != expected {
+			t.Errorf("expected body %s, got %s", expected, dlv.body)
+		}
+		if sig := dlv.headers.Get(webhook.SignatureHeader); sig != webhook.Sign("hunter2", []byte(expected)) {
+			t.Errorf("unexpected signature: %s", sig)
+		}
+		if topic := dlv.headers.Get(webhook.EventHeader); topic != events.TopicTaskFailed {
+			t.Errorf("expected event header %s, got %s", events.TopicTaskFailed, topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	var mu sync.Mutex
+	deliveryIDs := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		deliveryIDs[r.Header.Get(webhook.DeliveryHeader)] = true
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	bus := events.NewBus()
+	_, deadLetters, cleanup := newDispatcher(t, bus, webhook.Rule{
+		Name:        "alerts",
+		URL:         srv.URL,
+		Topics:      []string{"task"},
+		MaxAttempts: 3,
+	})
+	defer cleanup()
+
+	bus.Publish(context.Background(), events.TopicTaskFailed, map[string]interface{}{"task": "backup"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f, err := os.Open(deadLetters)
+		if err == nil {
+			scanner := bufio.NewScanner(f)
+			if scanner.Scan() {
+				var letter webhook.DeadLetter
+				if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+					t.Fatalf("invalid dead letter: %s", scanner.Text())
+				}
+				f.Close()
+
+				if letter.Webhook != "alerts" || letter.Attempts != 3 || letter.Event.Topic != events.TopicTaskFailed {
+					t.Errorf("unexpected dead letter: %+v", letter)
+				}
+
+				// Retries are sent as the same delivery.
+				mu.Lock()
+				if len(deliveryIDs) != 1 || !deliveryIDs[letter.Delivery] || letter.Delivery == "" {
+					t.Errorf("expected the attempts to share the delivery ID %q, got %v", letter.Delivery, deliveryIDs)
+				}
+				mu.Unlock()
+				return
+			}
+			f.Close()
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("delivery was not dead-lettered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}